@@ -0,0 +1,369 @@
+const { BagItFile } = require('./bagit_file');
+const { FileStat } = require('../util/file/filestat');
+const fs = require('fs');
+const { ManifestParser } = require('./manifest_parser');
+const path = require('path');
+const { Validator } = require('./validator');
+
+/**
+ * BagRepairer rewrites a bag's payload manifests, and optionally its tag
+ * manifests, so they match the files that are actually in the bag. This
+ * is for bags whose manifests went stale when someone edited, added, or
+ * removed a payload file. Unlike the {@link Validator}, which reports
+ * those problems, BagRepairer fixes them, so use it only on bags whose
+ * payload you trust.
+ *
+ * BagRepairer reads the bag with a {@link Validator}, which calculates
+ * the checksums of every file. It then compares each manifest with those
+ * checksums and records the differences in changes. Unless dryRun is
+ * true, it rewrites each manifest that has changes. It repairs only the
+ * manifests that are already in the bag, and leaves entries for files
+ * listed in fetch.txt alone.
+ *
+ * BagRepairer won't modify a tarred or zipped bag. To repair one, set
+ * destination. BagRepairer then extracts the bag into that directory and
+ * repairs the extracted copy. A dry run doesn't write anything, so it
+ * doesn't need a destination.
+ *
+ * @example
+ *
+ * let repairer = new BagRepairer('/bags/photos', profile, { dryRun: true });
+ * repairer.repair().then(function(changes) {
+ *     for (let c of changes) {
+ *         console.log(`${c.manifest}: ${c.change} ${c.relPath}`);
+ *     }
+ * });
+ *
+ */
+class BagRepairer {
+
+    /**
+     * Constructs a new BagRepairer.
+     *
+     * @param {string} pathToBag - The absolute path to the bag. This
+     * may be a directory, or a tar or zip file.
+     *
+     * @param {BagItProfile} profile - The BagItProfile the bag should
+     * conform to. The validator uses this to read the bag.
+     *
+     * @param {object} [opts] - Options.
+     *
+     * @param {boolean} [opts.dryRun=false] - If true, record the changes
+     * without writing them.
+     *
+     * @param {boolean} [opts.repairTagManifests=false] - If true, repair
+     * tag manifests as well as payload manifests.
+     *
+     * @param {string} [opts.destination] - The directory into which to
+     * extract a serialized bag before repairing it.
+     *
+     */
+    constructor(pathToBag, profile, opts = {}) {
+        /**
+         * pathToBag is the path to the bag to repair.
+         *
+         * @type {string}
+         */
+        this.pathToBag = pathToBag;
+        /**
+         * profile is the BagItProfile the validator uses to read the bag.
+         *
+         * @type {BagItProfile}
+         */
+        this.profile = profile;
+        /**
+         * dryRun describes whether to record changes without writing
+         * them to the bag.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.dryRun = opts.dryRun === true;
+        /**
+         * repairTagManifests describes whether to repair tag manifests
+         * as well as payload manifests. Tag manifests list the payload
+         * manifests, so their digests change when a payload manifest
+         * is repaired.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.repairTagManifests = opts.repairTagManifests === true;
+        /**
+         * destination is the directory into which BagRepairer extracts
+         * a tarred or zipped bag before repairing it. The repaired bag
+         * is in destination/<bag name>. This is required for serialized
+         * bags, unless dryRun is true.
+         *
+         * @type {string}
+         * @default null
+         */
+        this.destination = opts.destination || null;
+        /**
+         * validator is the {@link Validator} that read the bag. This is
+         * set by repair(). Its errors describe the bag as it was before
+         * the repair.
+         *
+         * @type {Validator}
+         * @default null
+         */
+        this.validator = null;
+        /**
+         * changes describes the manifest entries that repair() changed,
+         * or would change in a dry run. Each is an object with these
+         * properties:
+         *
+         * * manifest - The name of the manifest. E.g. manifest-sha256.txt.
+         * * relPath - The path of the file the entry describes.
+         * * change - 'added', 'removed', or 'changed'.
+         * * oldDigest - The digest in the manifest, or null if the
+         *   manifest did not list the file.
+         * * newDigest - The digest of the file, or null if the file is
+         *   not in the bag.
+         *
+         * @type {Array<object>}
+         */
+        this.changes = [];
+        /**
+         * errors is a list of problems that kept BagRepairer from
+         * repairing a manifest.
+         *
+         * @type {Array<string>}
+         */
+        this.errors = [];
+    }
+
+    /**
+     * repair reads the bag, compares its manifests with the files in the
+     * bag, and rewrites the manifests that don't match, unless dryRun is
+     * true. The returned Promise resolves to the list of changes. It
+     * rejects if the bag is serialized and there is no destination, if
+     * the bag can't be read, or if a manifest can't be written.
+     *
+     * @returns {Promise<Array<object>>}
+     */
+    repair() {
+        let repairer = this;
+        this.changes = [];
+        this.errors = [];
+        this.validator = new Validator(this.pathToBag, this.profile);
+        // The bag may be a directory extracted from a tar file, or one
+        // that hasn't been serialized yet, so don't let a profile that
+        // requires serialization stop the repair.
+        this.validator.disableSerializationCheck = true;
+        let serialized = this.validator.readingFromArchive();
+        if (serialized && !this.dryRun && !this.destination) {
+            return Promise.reject(new Error(`Cannot repair ${this.pathToBag} in place, because it is serialized. Set destination to the directory where the repaired bag should go.`));
+        }
+        let bagDir = this.pathToBag;
+        if (serialized && this.destination) {
+            bagDir = path.join(path.resolve(this.destination), this.validator.bagName);
+        }
+        return this._readBag(serialized).then(function() {
+            return repairer._repairManifests(bagDir);
+        }).then(function() {
+            return repairer.changes;
+        });
+    }
+
+    /**
+     * _readBag runs the validator, which calculates the checksums of
+     * every file in the bag and parses the manifests. A serialized bag
+     * is extracted into destination as it's read, unless this is a dry
+     * run. The returned Promise resolves whether or not the bag is
+     * valid, since BagRepairer expects bags with bad manifests.
+     *
+     * @param {boolean} serialized - True if the bag is a tar or zip file.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _readBag(serialized) {
+        let repairer = this;
+        let validator = this.validator;
+        return new Promise(function(resolve, reject) {
+            validator.once('error', function(err) {
+                reject(err instanceof Error ? err : new Error(err));
+            });
+            if (serialized && !repairer.dryRun) {
+                validator.validateAndExtract(repairer.destination).then(resolve, reject);
+            } else {
+                validator.once('end', resolve);
+                validator.validate();
+            }
+        });
+    }
+
+    /**
+     * _repairManifests repairs the payload manifests, then the tag
+     * manifests if repairTagManifests is true. It writes each manifest
+     * that changed into bagDir, unless this is a dry run.
+     *
+     * @param {string} bagDir - The directory that contains the bag.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _repairManifests(bagDir) {
+        let validator = this.validator;
+        let rewritten = {};
+        let fetched = validator._fetchFilenames();
+        let payloadFiles = validator.payloadFiles();
+        for (let manifest of this._wholeManifests(validator.payloadManifests())) {
+            let algorithm = this._algorithm(manifest);
+            let digests = {};
+            for (let bagItFile of payloadFiles) {
+                digests[bagItFile.relDestPath] = bagItFile.checksums[algorithm];
+            }
+            for (let relPath of manifest.keyValueCollection.keys()) {
+                if (digests[relPath] === undefined && fetched.has(relPath)) {
+                    digests[relPath] = manifest.keyValueCollection.first(relPath);
+                }
+            }
+            this._repairManifest(manifest, digests, bagDir, rewritten);
+        }
+        if (!this.repairTagManifests) {
+            return Promise.resolve();
+        }
+        let repairer = this;
+        let tagFiles = Object.values(validator.files).filter(f => !f.isPayloadFile() && !f.isTagManifest());
+        let chain = Promise.resolve();
+        for (let manifest of this._wholeManifests(validator.tagManifests())) {
+            let algorithm = this._algorithm(manifest);
+            chain = chain.then(function() {
+                return repairer._tagFileDigests(tagFiles, algorithm, rewritten);
+            }).then(function(digests) {
+                repairer._repairManifest(manifest, digests, bagDir, rewritten);
+            });
+        }
+        return chain;
+    }
+
+    /**
+     * _wholeManifests returns the manifests in the list that BagRepairer
+     * can rewrite. It can't rewrite manifests that the bag splits into
+     * parts, so this records an error for each of those.
+     *
+     * @param {Array<BagItFile>} manifests - Manifests from the validator.
+     *
+     * @returns {Array<BagItFile>}
+     *
+     * @private
+     */
+    _wholeManifests(manifests) {
+        let whole = [];
+        for (let manifest of manifests) {
+            if (this.validator.files[manifest.relDestPath] !== manifest) {
+                this.errors.push(`Cannot repair ${manifest.relDestPath}, because the bag splits it into parts.`);
+            } else if (manifest.keyValueCollection != null) {
+                whole.push(manifest);
+            }
+        }
+        return whole.sort((a, b) => a.relDestPath < b.relDestPath ? -1 : 1);
+    }
+
+    /**
+     * _algorithm returns the digest algorithm of a manifest, based on
+     * its name. E.g. 'sha256' for tagmanifest-sha256.txt.
+     *
+     * @param {BagItFile} manifest - A manifest or tag manifest.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _algorithm(manifest) {
+        return manifest.relDestPath.split('-')[1].split('.')[0];
+    }
+
+    /**
+     * _tagFileDigests returns a Promise that resolves to an object whose
+     * keys are the paths of the tag files and whose values are their
+     * digests. For manifests that BagRepairer has rewritten, this
+     * calculates the digest of the new contents.
+     *
+     * @param {Array<BagItFile>} tagFiles - The tag files and manifests.
+     *
+     * @param {string} algorithm - The digest algorithm.
+     *
+     * @param {Object<string, string>} rewritten - The new contents of
+     * each rewritten manifest, keyed by path.
+     *
+     * @returns {Promise<Object<string, string>>}
+     *
+     * @private
+     */
+    _tagFileDigests(tagFiles, algorithm, rewritten) {
+        let digests = {};
+        let pending = [];
+        for (let bagItFile of tagFiles) {
+            let relPath = bagItFile.relDestPath;
+            if (rewritten[relPath] === undefined) {
+                digests[relPath] = bagItFile.checksums[algorithm];
+                continue;
+            }
+            let copy = new BagItFile('', relPath, new FileStat({ size: Buffer.byteLength(rewritten[relPath]), type: 'file' }));
+            pending.push(new Promise(function(resolve) {
+                let hash = copy.getCryptoHash(algorithm, function(data) {
+                    digests[relPath] = data.digest;
+                    resolve();
+                });
+                hash.end(rewritten[relPath]);
+            }));
+        }
+        return Promise.all(pending).then(() => digests);
+    }
+
+    /**
+     * _repairManifest compares manifest with digests, records the
+     * differences in changes, and rewrites the manifest if there are
+     * any differences and this isn't a dry run. The new manifest lists
+     * the files in digests, sorted by path.
+     *
+     * @param {BagItFile} manifest - The manifest to repair.
+     *
+     * @param {Object<string, string>} digests - The digest of each file
+     * the manifest should list, keyed by path.
+     *
+     * @param {string} bagDir - The directory that contains the bag.
+     *
+     * @param {Object<string, string>} rewritten - This adds the new
+     * contents of the manifest here, keyed by path, if it changed.
+     *
+     * @private
+     */
+    _repairManifest(manifest, digests, bagDir, rewritten) {
+        let entries = manifest.keyValueCollection;
+        let changes = [];
+        let relPaths = Object.keys(digests).sort();
+        for (let relPath of relPaths) {
+            let oldDigest = entries.first(relPath);
+            let newDigest = digests[relPath];
+            if (oldDigest == null) {
+                changes.push({ relPath: relPath, change: 'added', oldDigest: null, newDigest: newDigest });
+            } else if (oldDigest.toLowerCase() != String(newDigest).toLowerCase()) {
+                changes.push({ relPath: relPath, change: 'changed', oldDigest: oldDigest, newDigest: newDigest });
+            }
+        }
+        for (let relPath of entries.keys().sort()) {
+            if (digests[relPath] === undefined) {
+                changes.push({ relPath: relPath, change: 'removed', oldDigest: entries.first(relPath), newDigest: null });
+            }
+        }
+        if (changes.length == 0) {
+            return;
+        }
+        for (let change of changes) {
+            this.changes.push(Object.assign({ manifest: manifest.relDestPath }, change));
+        }
+        let contents = relPaths.map(relPath => `${digests[relPath]} ${ManifestParser.encodePath(relPath)}\n`).join('');
+        rewritten[manifest.relDestPath] = contents;
+        if (!this.dryRun) {
+            fs.writeFileSync(path.join(bagDir, manifest.relDestPath), contents);
+        }
+    }
+}
+
+module.exports.BagRepairer = BagRepairer;
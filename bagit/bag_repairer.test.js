@@ -0,0 +1,160 @@
+const { BagItProfile } = require('./bagit_profile');
+const { BagRepairer } = require('./bag_repairer');
+const fs = require('fs');
+const os = require('os');
+const path = require('path');
+const { Util } = require('../core/util');
+const { Validator } = require('./validator');
+
+function bagPath(bagName) {
+    return path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+}
+
+// copyBag copies a test bag into a temp directory, so the tests can
+// change it, and returns the path to the copy.
+function copyBag(bagName) {
+    let tmpDir = fs.mkdtempSync(path.join(os.tmpdir(), 'bag-repairer-'));
+    let copy = path.join(tmpDir, bagName);
+    copyDir(bagPath(bagName), copy);
+    return copy;
+}
+
+function copyDir(src, dest) {
+    fs.mkdirSync(dest, { recursive: true });
+    for (let entry of fs.readdirSync(src, { withFileTypes: true })) {
+        if (entry.isDirectory()) {
+            copyDir(path.join(src, entry.name), path.join(dest, entry.name));
+        } else {
+            fs.copyFileSync(path.join(src, entry.name), path.join(dest, entry.name));
+        }
+    }
+}
+
+// makeStale edits, adds and removes payload files without updating
+// the manifests. The payload keeps its size and file count, so
+// Payload-Oxum still matches.
+function makeStale(bag) {
+    fs.writeFileSync(path.join(bag, 'data', 'first.txt'), 'First payload FILE.\n');
+    fs.unlinkSync(path.join(bag, 'data', 'docs', 'second.txt'));
+    fs.writeFileSync(path.join(bag, 'data', 'docs', 'fourth.txt'), 'Second payload file.\n');
+}
+
+function validate(bag) {
+    let validator = new Validator(bag, new BagItProfile());
+    return new Promise(function(resolve) {
+        validator.on('end', () => resolve(validator));
+        validator.validate();
+    });
+}
+
+test('Constructor sets expected properties', () => {
+    let profile = new BagItProfile();
+    let repairer = new BagRepairer(bagPath("valid_bag"), profile);
+    expect(repairer.pathToBag).toEqual(bagPath("valid_bag"));
+    expect(repairer.profile).toBe(profile);
+    expect(repairer.dryRun).toBe(false);
+    expect(repairer.repairTagManifests).toBe(false);
+    expect(repairer.destination).toBeNull();
+    expect(repairer.validator).toBeNull();
+    expect(repairer.changes).toEqual([]);
+    expect(repairer.errors).toEqual([]);
+
+    repairer = new BagRepairer(bagPath("valid_bag"), profile, { dryRun: true, repairTagManifests: true, destination: '/tmp' });
+    expect(repairer.dryRun).toBe(true);
+    expect(repairer.repairTagManifests).toBe(true);
+    expect(repairer.destination).toEqual('/tmp');
+});
+
+test('repair() finds no changes in a valid bag', () => {
+    let repairer = new BagRepairer(bagPath("valid_bag"), new BagItProfile(), { dryRun: true, repairTagManifests: true });
+    return repairer.repair().then(function(changes) {
+        expect(changes).toEqual([]);
+        expect(repairer.errors).toEqual([]);
+    });
+});
+
+test('repair() lists changes without writing them in a dry run', () => {
+    let bag = copyBag("md5_tag_manifest");
+    makeStale(bag);
+    let before = fs.readFileSync(path.join(bag, 'manifest-sha256.txt'), 'utf8');
+    let repairer = new BagRepairer(bag, new BagItProfile(), { dryRun: true, repairTagManifests: true });
+    return repairer.repair().then(function(changes) {
+        expect(changes.map(c => [c.manifest, c.change, c.relPath])).toEqual([
+            ['manifest-sha256.txt', 'added', 'data/docs/fourth.txt'],
+            ['manifest-sha256.txt', 'changed', 'data/first.txt'],
+            ['manifest-sha256.txt', 'removed', 'data/docs/second.txt'],
+            ['tagmanifest-md5.txt', 'changed', 'manifest-sha256.txt'],
+        ]);
+        let removed = changes.find(c => c.change == 'removed');
+        expect(removed.oldDigest).toEqual('2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5');
+        expect(removed.newDigest).toBeNull();
+        let added = changes.find(c => c.relPath == 'data/docs/fourth.txt');
+        expect(added.oldDigest).toBeNull();
+        expect(added.newDigest).toEqual('2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5');
+        expect(fs.readFileSync(path.join(bag, 'manifest-sha256.txt'), 'utf8')).toEqual(before);
+        Util.deleteRecursive(path.dirname(bag));
+    });
+});
+
+test('repair() repairs a directory with a profile that requires serialization', () => {
+    let bag = copyBag("md5_tag_manifest");
+    makeStale(bag);
+    let profile = new BagItProfile();
+    profile.serialization = 'required';
+    profile.acceptSerialization = ['application/x-tar'];
+    let repairer = new BagRepairer(bag, profile);
+    return repairer.repair().then(function(changes) {
+        expect(changes.map(c => c.relPath)).toEqual(['data/docs/fourth.txt', 'data/first.txt', 'data/docs/second.txt']);
+        expect(repairer.validator.errors.join(' ')).not.toMatch(/serializ/i);
+        Util.deleteRecursive(path.dirname(bag));
+    });
+});
+
+test('repair() rewrites stale manifests', () => {
+    let bag = copyBag("md5_tag_manifest");
+    makeStale(bag);
+    fs.appendFileSync(path.join(bag, 'bag-info.txt'), 'Bag-Count: 1 of 1\n');
+    let repairer = new BagRepairer(bag, new BagItProfile(), { repairTagManifests: true });
+    return repairer.repair().then(function(changes) {
+        expect(changes.filter(c => c.manifest == 'tagmanifest-md5.txt').map(c => c.relPath)).toEqual(['bag-info.txt', 'manifest-sha256.txt']);
+        expect(repairer.validator.errors.length).toBeGreaterThan(0);
+        return validate(bag);
+    }).then(function(validator) {
+        expect(validator.errors).toEqual([]);
+        Util.deleteRecursive(path.dirname(bag));
+    });
+});
+
+test('repair() leaves tag manifests alone unless asked', () => {
+    let bag = copyBag("md5_tag_manifest");
+    makeStale(bag);
+    let repairer = new BagRepairer(bag, new BagItProfile());
+    return repairer.repair().then(function(changes) {
+        expect(changes.every(c => c.manifest == 'manifest-sha256.txt')).toBe(true);
+        return validate(bag);
+    }).then(function(validator) {
+        expect(validator.errors).toEqual([
+            "Bad md5 digest for 'manifest-sha256.txt': manifest says '1abcd0632cf5d335c0539fbf18dbb4fc', file digest is '" + validator.files['manifest-sha256.txt'].checksums['md5'] + "'."
+        ]);
+        Util.deleteRecursive(path.dirname(bag));
+    });
+});
+
+test('repair() will not modify a serialized bag', () => {
+    let repairer = new BagRepairer(bagPath("payload_first.tar"), new BagItProfile());
+    return repairer.repair().then(function() {
+        throw new Error('repair() should have rejected');
+    }, function(err) {
+        expect(err.message).toEqual(`Cannot repair ${bagPath("payload_first.tar")} in place, because it is serialized. Set destination to the directory where the repaired bag should go.`);
+    });
+});
+
+test('repair() extracts a serialized bag into destination', () => {
+    let destination = fs.mkdtempSync(path.join(os.tmpdir(), 'bag-repairer-'));
+    let repairer = new BagRepairer(bagPath("payload_first.tar"), new BagItProfile(), { destination: destination });
+    return repairer.repair().then(function(changes) {
+        expect(changes).toEqual([]);
+        expect(fs.existsSync(path.join(destination, 'payload_first', 'manifest-sha256.txt'))).toBe(true);
+        Util.deleteRecursive(destination);
+    });
+});
@@ -1,13 +1,17 @@
 const { BagItFile } = require('./bagit_file');
+const { BagItProfile } = require('./bagit_profile');
 const { Constants } = require('../core/constants');
 const { Context } = require('../core/context');
 const dateFormat = require('dateformat');
 const EventEmitter = require('events');
 const fs = require('fs');
+const { Job } = require('../core/job');
 const { KeyValueCollection } = require('./key_value_collection');
+const { ManifestParser } = require('./manifest_parser');
 const mkdirp = require('mkdirp');
 const { OperationResult } = require('../core/operation_result');
 const os = require('os');
+const { PackageOperation } = require('../core/package_operation');
 const path = require('path');
 const { PluginManager } = require('../plugins/plugin_manager');
 const { TagDefinition } = require('./tag_definition');
@@ -93,6 +97,91 @@ class Bagger extends EventEmitter {
         this._pathToTrim = null;
     }
 
+    /**
+     * fromDirectory returns a Bagger that will bag the contents of
+     * sourceDir according to profile, without requiring you to set up
+     * a {@link Job} yourself. Call create() on the returned Bagger to
+     * build the bag.
+     *
+     * The contents of sourceDir go into the bag's data directory.
+     * The bagger writes bagit.txt and the other tag files from the
+     * profile's tag definitions, and writes the manifests and tag
+     * manifests the profile requires.
+     *
+     * If outputPath has no extension, the bag is written as a directory.
+     * Otherwise, the extension determines the serialization format, and
+     * it must be one of the profile's acceptSerialization formats. See
+     * {@link Constants.SERIALIZATION_FORMATS}. This throws an Error if
+     * the output format doesn't meet the profile's serialization rules,
+     * since the validator would reject the bag anyway.
+     *
+     * @param {string} sourceDir - The directory whose contents should
+     * become the bag's payload.
+     *
+     * @param {BagItProfile} profile - The profile that describes how to
+     * build the bag. The bagger works on a copy, so this doesn't change
+     * the profile's tag values.
+     *
+     * @param {string} outputPath - The path of the bag to create.
+     *
+     * @param {Object<string, string>} tags - Tag values, keyed by tag
+     * name. If the profile defines a tag with the same name, this sets
+     * that tag's value. Otherwise, this adds the tag to bag-info.txt.
+     *
+     * @returns {Bagger}
+     */
+    static fromDirectory(sourceDir, profile, outputPath, tags = {}) {
+        Bagger._checkSerialization(profile, outputPath);
+        let job = new Job();
+        let extension = path.extname(outputPath);
+        let packageName = path.basename(outputPath, extension);
+        if (packageName.endsWith('.tar')) {
+            packageName = path.basename(packageName, '.tar');
+        }
+        job.packageOp = new PackageOperation(packageName, outputPath);
+        job.packageOp.sourceFiles = fs.readdirSync(sourceDir).sort().map(name => path.join(sourceDir, name));
+        job.bagItProfile = BagItProfile.inflateFrom(JSON.parse(JSON.stringify(profile)));
+        for (let [tagName, value] of Object.entries(tags)) {
+            let tagDef = job.bagItProfile.firstMatchingTag('tagName', tagName);
+            if (tagDef == null) {
+                tagDef = new TagDefinition({
+                    tagFile: 'bag-info.txt',
+                    tagName: tagName
+                });
+                job.bagItProfile.tags.push(tagDef);
+            }
+            tagDef.userValue = value;
+        }
+        return new Bagger(job);
+    }
+
+    /**
+     * _checkSerialization throws an Error if writing a bag to outputPath
+     * would break the profile's serialization rules.
+     *
+     * @param {BagItProfile} profile - The profile.
+     *
+     * @param {string} outputPath - The path of the bag to create.
+     *
+     * @private
+     */
+    static _checkSerialization(profile, outputPath) {
+        let serialized = path.extname(outputPath) != '';
+        if (profile.serialization == 'required' && !serialized) {
+            throw new Error(`Profile says bag must be serialized, but ${outputPath} has no file extension.`);
+        }
+        if (profile.serialization == 'forbidden' && serialized) {
+            throw new Error(`Profile says bag must not be serialized, but ${outputPath} has a file extension.`);
+        }
+        if (serialized && profile.acceptSerialization.length > 0) {
+            let accepted = profile.acceptSerialization.some(mimeType =>
+                Constants.SERIALIZATION_FORMATS[mimeType] && Constants.SERIALIZATION_FORMATS[mimeType].test(outputPath));
+            if (!accepted) {
+                throw new Error(`Profile does not accept the serialization format of ${outputPath}. Accepted formats: ${profile.acceptSerialization.join(', ')}.`);
+            }
+        }
+    }
+
     /**
      * This ensures the packaging operation is valid before the bagger
      * tries to run it.
@@ -545,7 +634,7 @@ class Bagger extends EventEmitter {
                     continue;
                 }
                 let digest = bagItFile.checksums[algorithm];
-                fs.writeSync(fd, `${digest} ${bagItFile.relDestPath}\n`);
+                fs.writeSync(fd, `${digest} ${ManifestParser.encodePath(bagItFile.relDestPath)}\n`);
             }
             fs.closeSync(fd);
             var stats = fs.statSync(tmpFile);
@@ -229,3 +229,42 @@ function getSourceFiles() {
     }
     return files;
 }
+
+test('fromDirectory() creates a valid tarred bag', done => {
+    let sourceDir = path.join(__dirname, '..', 'test', 'bags', 'bagit', 'valid_bag', 'data');
+    let profile = BagItProfile.load(path.join(__dirname, '..', 'test', 'profiles', 'multi_manifest.json'));
+    let bagger = Bagger.fromDirectory(sourceDir, profile, tmpFile, {
+        'Access': 'Institution',
+        'Title': 'Test Bag',
+        'Source-Organization': 'School of Hard Knocks',
+        'Project-Code': 'XYZ'
+    });
+    // The bagger works on a copy of the profile.
+    expect(profile.firstMatchingTag('tagName', 'Title').userValue).toEqual('');
+    expect(bagger.job.bagItProfile.getTagsFromFile('bag-info.txt', 'Project-Code')[0].userValue).toEqual('XYZ');
+    bagger.on('finish', function() {
+        expect(bagger.job.packageOp.result.errors).toEqual([]);
+        expect(bagger.bagItFiles.filter(f => f.isPayloadFile()).map(f => f.relDestPath).sort()).toEqual([
+            'data/docs/second.txt',
+            'data/first.txt'
+        ]);
+        let validator = new Validator(tmpFile, profile);
+        validator.on('end', function() {
+            expect(validator.errors).toEqual([]);
+            done();
+        });
+        validator.validate();
+    });
+    bagger.create();
+});
+
+test('fromDirectory() enforces profile serialization rules', () => {
+    let sourceDir = path.join(__dirname, '..', 'test', 'bags', 'bagit', 'valid_bag', 'data');
+    let profile = BagItProfile.load(path.join(__dirname, '..', 'test', 'profiles', 'multi_manifest.json'));
+    expect(() => { Bagger.fromDirectory(sourceDir, profile, tmpOutputDir) }).toThrow("Profile says bag must be serialized");
+    expect(() => { Bagger.fromDirectory(sourceDir, profile, tmpFile + '.zip') }).toThrow("Profile does not accept the serialization format");
+    profile.serialization = 'forbidden';
+    expect(() => { Bagger.fromDirectory(sourceDir, profile, tmpFile) }).toThrow("Profile says bag must not be serialized");
+    profile.serialization = 'optional';
+    expect(Bagger.fromDirectory(sourceDir, profile, tmpOutputDir)).toBeInstanceOf(Bagger);
+});
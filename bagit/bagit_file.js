@@ -1,6 +1,23 @@
 const { Constants } = require('../core/constants');
 const crypto = require('crypto');
 const { KeyValueCollection } = require('./key_value_collection');
+const { ManifestParser } = require('./manifest_parser');
+
+/**
+ * Detached signature files have these extensions.
+ *
+ * @type {RegExp}
+ */
+const signatureExtension = /\.(asc|sig)$/;
+
+/**
+ * This is the registry of digest algorithms, keyed by the name that
+ * appears in manifest file names. Each value is a function that returns
+ * a new hash stream. See {@link BagItFile.registerDigestAlgorithm}.
+ *
+ * @type {Object<string, function>}
+ */
+const digestAlgorithms = {};
 
 /**
  * BagItFile contains metadata about a file that the bagger
@@ -130,14 +147,16 @@ class BagItFile {
      * @param {string} algorithm - The algorithm of the digest to retrieve.
      *
      * @returns {string} - A manifest entry for this file, in the format
-     * <digest> <relDestPath>.
+     * <digest> <relDestPath>. Line feeds, carriage returns, and percent
+     * signs in relDestPath are percent-encoded, as the BagIt spec
+     * requires.
      */
     getManifestEntry(algorithm) {
         var checksum = this.checksums[algorithm];
         if (checksum === undefined || checksum == null) {
             throw new Error(`No ${algorithm} digest for ${this.absSourcePath}`);
         }
-        return `${checksum} ${this.relDestPath}`;
+        return `${checksum} ${ManifestParser.encodePath(this.relDestPath)}`;
     }
 
     /**
@@ -187,6 +206,9 @@ class BagItFile {
       * @param {string} algorithm - The hash digest algorithm to calculate.
       * For example, 'md5', 'sha256', 'sha512', etc.
       *
+      * This throws an Error if algorithm is not registered. See
+      * {@link BagItFile.registerDigestAlgorithm}.
+      *
       * @param {function} done - A callback to call when hasing is complete.
       * The callback will be given data with the format:
       *
@@ -201,7 +223,11 @@ class BagItFile {
       */
     getCryptoHash(algorithm, done) {
         let bagItFile = this;
-        let hash = crypto.createHash(algorithm);
+        let createHash = digestAlgorithms[algorithm];
+        if (createHash === undefined) {
+            throw new Error(`Unknown digest algorithm '${algorithm}'. Supported algorithms: ${BagItFile.digestAlgorithms().join(', ')}.`);
+        }
+        let hash = createHash();
         let cbData = {
             absSourcePath: bagItFile.absSourcePath,
             relDestPath: bagItFile.relDestPath,
@@ -222,6 +248,8 @@ class BagItFile {
       * getFileType returns the type of BagIt file based on relDestPath.
       * File types are defined in Constants.FILE_TYPES and include
       * 'manifest', 'tagmanifest', 'payload', and 'tagfile'.
+      * Detached signatures outside the payload directory, such as
+      * tagmanifest-sha256.txt.asc, are tag files.
       *
       * @param {string} relDestPath - The relative path, within the bag,
       * of the file. For example, 'data/images/photo.jpg' or 'manifest-sha256.txt'.
@@ -231,6 +259,8 @@ class BagItFile {
     static getFileType(relDestPath) {
         if (relDestPath.startsWith('data/')) {
             return Constants.PAYLOAD_FILE;
+        } else if (BagItFile.isSignature(relDestPath)) {
+            return Constants.TAG_FILE;
         } else if (relDestPath.startsWith('manifest-')) {
             return Constants.PAYLOAD_MANIFEST;
         } else if (relDestPath.startsWith('tagmanifest-')) {
@@ -238,6 +268,54 @@ class BagItFile {
         }
         return Constants.TAG_FILE;
     }
+
+    /**
+      * isSignature returns true if relDestPath is a detached signature
+      * outside the payload directory. Signatures end with .asc or .sig.
+      * The file they sign has the same name, minus that extension.
+      *
+      * @param {string} relDestPath - The relative path, within the bag,
+      * of the file. For example, 'tagmanifest-sha256.txt.asc'.
+      *
+      * @returns {boolean}
+      */
+    static isSignature(relDestPath) {
+        return !relDestPath.startsWith('data/') && signatureExtension.test(relDestPath);
+    }
+
+    /**
+      * registerDigestAlgorithm adds a digest algorithm to the registry
+      * that {@link BagItFile#getCryptoHash} uses, or replaces the one
+      * already registered under the same name. Every algorithm in
+      * {@link Constants.DIGEST_ALGORITHMS} is registered by default.
+      *
+      * @example
+      *
+      * BagItFile.registerDigestAlgorithm('sha3', () => crypto.createHash('sha3-256'));
+      *
+      * @param {string} name - The algorithm name, as it appears in
+      * manifest file names. For example, 'sha512' for manifest-sha512.txt.
+      *
+      * @param {function} createHash - A function that returns a new
+      * crypto.Hash, or a stream that behaves like one.
+      */
+    static registerDigestAlgorithm(name, createHash) {
+        digestAlgorithms[name] = createHash;
+    }
+
+    /**
+      * digestAlgorithms returns the names of all registered digest
+      * algorithms.
+      *
+      * @returns {string[]}
+      */
+    static digestAlgorithms() {
+        return Object.keys(digestAlgorithms);
+    }
+}
+
+for (let algorithm of Constants.DIGEST_ALGORITHMS) {
+    BagItFile.registerDigestAlgorithm(algorithm, () => crypto.createHash(algorithm));
 }
 
 
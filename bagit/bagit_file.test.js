@@ -30,6 +30,8 @@ test('getManifestEntry()', () => {
     expect(f.getManifestEntry('md5')).toEqual("1234 data/bagit_file.test.js")
     expect(f.getManifestEntry('sha256')).toEqual("5678 data/bagit_file.test.js")
     expect(() => { f.getManifestEntry('md4') }).toThrow(Error);
+    f.relDestPath = "data/100%\n.txt";
+    expect(f.getManifestEntry('md5')).toEqual("1234 data/100%25%0A.txt")
 });
 
 test('getFileType', () => {
@@ -37,6 +39,16 @@ test('getFileType', () => {
     expect(BagItFile.getFileType('manifest-md5.txt')).toEqual(Constants.PAYLOAD_MANIFEST);
     expect(BagItFile.getFileType('tagmanifest-sha256.txt')).toEqual(Constants.TAG_MANIFEST);
     expect(BagItFile.getFileType('dpn-tags/file.txt')).toEqual(Constants.TAG_FILE);
+    expect(BagItFile.getFileType('tagmanifest-sha256.txt.asc')).toEqual(Constants.TAG_FILE);
+    expect(BagItFile.getFileType('manifest-md5.txt.sig')).toEqual(Constants.TAG_FILE);
+    expect(BagItFile.getFileType('data/manifest-md5.txt.sig')).toEqual(Constants.PAYLOAD_FILE);
+});
+
+test('isSignature', () => {
+    expect(BagItFile.isSignature('tagmanifest-sha256.txt.asc')).toEqual(true);
+    expect(BagItFile.isSignature('manifest-sha256.txt.sig')).toEqual(true);
+    expect(BagItFile.isSignature('tagmanifest-sha256.txt')).toEqual(false);
+    expect(BagItFile.isSignature('data/photo.jpg.sig')).toEqual(false);
 });
 
 test('isPayloadFile', () => {
@@ -99,6 +111,25 @@ test('isTagManifest', () => {
     expect(f.isTagManifest()).toEqual(false);
 });
 
+test('getCryptoHash() rejects unknown algorithms', () => {
+    let stats = fs.statSync(__filename);
+    let f = new BagItFile(__filename, 'data/bagit_file.test.js', stats);
+    expect(() => { f.getCryptoHash('rot13') }).toThrow("Unknown digest algorithm 'rot13'. Supported algorithms: md5, sha1, sha224, sha256, sha384, sha512");
+});
+
+test('registerDigestAlgorithm()', done => {
+    expect(BagItFile.digestAlgorithms()).toEqual(Constants.DIGEST_ALGORITHMS);
+    BagItFile.registerDigestAlgorithm('sha512t256', () => crypto.createHash('sha512-256'));
+    expect(BagItFile.digestAlgorithms()).toContain('sha512t256');
+    let stats = fs.statSync(__filename);
+    let f = new BagItFile(__filename, 'data/bagit_file.test.js', stats);
+    let hash = f.getCryptoHash('sha512t256', function(data) {
+        expect(data.digest).toEqual(crypto.createHash('sha512-256').update(fs.readFileSync(__filename)).digest('hex'));
+        done();
+    });
+    fs.createReadStream(__filename).pipe(hash);
+});
+
 test('getCryptoHash()', done => {
     let stats = fs.statSync(path.join(__dirname, '..', 'test', 'fixtures', 'tagmanifest-sha256.txt'));
     let expectedDigest = 'd4ff2da092d09cbc0ef62428b78d13b1';
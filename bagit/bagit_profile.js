@@ -127,6 +127,18 @@ class BagItProfile extends PersistentObject {
          * @default ['*']
          */
         this.tagFilesAllowed = opts.tagManifestsAllowed || ['*'];
+        /**
+         * List of tag files that must be present in the bag, such as
+         * ['dpn-tags/dpn-info.txt']. These are in addition to the tag
+         * files that contain tags from the tags list, which must
+         * always be present. This corresponds to Tag-Files-Required in
+         * the BagItProfile spec, and lets you require a tag file
+         * without saying anything about its contents.
+         *
+         * @type {string[]}
+         * @default []
+         */
+        this.tagFilesRequired = opts.tagFilesRequired || [];
         /**
           * A list of tags that you expect to be present or expect
           * to parse when creating or validating bags that conform to
@@ -179,6 +191,176 @@ class BagItProfile extends PersistentObject {
           * @default false
           */
         this.tarDirMustMatchName = opts.tarDirMustMatchName === true ? true : false;
+        /**
+          * Describes whether the entries in each payload and tag manifest
+          * MUST be sorted in ascending order by path. Some profiles
+          * require this so that the same bag always produces identical
+          * manifests.
+          *
+          * @type {boolean}
+          * @default false
+          */
+        this.requireSortedManifests = opts.requireSortedManifests === true ? true : false;
+        /**
+          * Describes whether bags may include only the manifests and tag
+          * manifests this profile requires. When this is true, a bag
+          * conforming to a profile that requires only sha256 manifests
+          * is invalid if it also has a manifest-md5.txt, even though
+          * manifestsAllowed includes md5. Leave this false to allow any
+          * algorithm in manifestsAllowed and tagManifestsAllowed.
+          *
+          * @type {boolean}
+          * @default false
+          */
+        this.allowOnlyRequiredManifests = opts.allowOnlyRequiredManifests === true ? true : false;
+        /**
+          * Describes whether the Source-Organization tag in the bag's
+          * bag-info.txt MUST match the sourceOrganization in this
+          * profile's {@link BagItProfileInfo}. Profiles that belong to a
+          * single organization can use this to reject bags that were
+          * built for someone else.
+          *
+          * @type {boolean}
+          * @default false
+          */
+        this.requireSourceOrganizationMatch = opts.requireSourceOrganizationMatch === true ? true : false;
+        /**
+          * Describes the order in which certain tags must appear within
+          * a tag file. The key is the name of the tag file, and the value
+          * is a list of tag names in the required order. For example,
+          * { 'bag-info.txt': ['Source-Organization', 'Bagging-Date'] }
+          * means Source-Organization must come before Bagging-Date in
+          * bag-info.txt.
+          *
+          * Tags not in the list may appear anywhere, and tags in the
+          * list that are not present in the file are ignored. Use the
+          * tag definition's required property to ensure a tag is present.
+          *
+          * @type {Object<string, string[]>}
+          * @default {}
+          */
+        this.requiredTagOrder = opts.requiredTagOrder || {};
+        /**
+          * Describes which tag manifest algorithm must cover certain tag
+          * files. The key is the name of the tag file, and the value is
+          * the name of the algorithm. For example,
+          * { 'aptrust-info.txt': 'sha256' } means aptrust-info.txt must
+          * be listed in tagmanifest-sha256.txt. Listing the file only in
+          * a tag manifest that uses some other algorithm, such as md5,
+          * is not enough.
+          *
+          * @type {Object<string, string>}
+          * @default {}
+          */
+        this.tagFileAlgorithms = opts.tagFileAlgorithms || {};
+        /**
+          * The maximum number of bytes allowed in the bag's payload
+          * directory. The validator will reject bags whose payload is
+          * larger than this. Zero means there is no limit.
+          *
+          * @type {number}
+          * @default 0
+          */
+        this.maxPayloadSize = opts.maxPayloadSize || 0;
+        /**
+          * The minimum number of bytes required in the bag's payload
+          * directory. The validator will reject bags whose payload is
+          * smaller than this. Zero means there is no minimum.
+          *
+          * @type {number}
+          * @default 0
+          */
+        this.minPayloadSize = opts.minPayloadSize || 0;
+        /**
+          * The maximum number of bytes allowed in the whole bag,
+          * including payload files, tag files, and manifests. This is
+          * the total size of the files, not the size of a tar or zip
+          * file that contains them. Zero means there is no limit.
+          *
+          * @type {number}
+          * @default 0
+          */
+        this.maxBagSize = opts.maxBagSize || 0;
+        /**
+          * The maximum number of bytes allowed in each tag file for
+          * which this profile defines tags, such as bag-info.txt. The
+          * validator will reject bags with larger tag files. Zero means
+          * there is no limit.
+          *
+          * @type {number}
+          * @default 0
+          */
+        this.maxTagFileSize = opts.maxTagFileSize || 0;
+        /**
+          * The maximum number of bytes allowed in all of the bag's tag
+          * files combined. Manifests and tag manifests don't count
+          * toward this. Zero means there is no limit.
+          *
+          * @type {number}
+          * @default 0
+          */
+        this.maxTotalMetadataSize = opts.maxTotalMetadataSize || 0;
+        /**
+          * The format of the payload and tag manifests in bags that
+          * conform to this profile. This is the name of a format
+          * registered with {@link ManifestParser.register}. The
+          * default, 'bagit', is the standard BagIt manifest format.
+          *
+          * @type {string}
+          * @default 'bagit'
+          */
+        this.manifestFormat = opts.manifestFormat || 'bagit';
+        /**
+          * The character that separates tag names from values in the
+          * tag files of bags that conform to this profile. The BagIt
+          * spec says this is a colon, but some bags use other
+          * delimiters, such as '='. This does not apply to bagit.txt,
+          * which must always use a colon.
+          *
+          * @type {string}
+          * @default ':'
+          */
+        this.tagDelimiter = opts.tagDelimiter || ':';
+        /**
+          * The one serialization format that serialized bags conforming
+          * to this profile must use, such as 'application/zip'. This
+          * must be one of the formats in acceptSerialization. A bag in
+          * any other format is invalid, even if acceptSerialization
+          * lists that format. An empty string means any format in
+          * acceptSerialization is fine.
+          *
+          * @type {string}
+          * @default ''
+          */
+        this.requiredSerialization = opts.requiredSerialization || '';
+        /**
+          * A list of conformance levels, from least to most strict. For
+          * example, 'minimal', 'recommended' and 'complete'. A bag that is
+          * valid according to this profile achieves a level if it meets
+          * the requirements of that level and of every level before it.
+          * See {@link Validator#conformanceLevel}.
+          *
+          * Each level is an object with the following properties. All
+          * but name are optional.
+          *
+          * * name - The name of the level.
+          * * manifestsRequired - A list of algorithms of required manifests.
+          * * tagManifestsRequired - A list of algorithms of required tag
+          *   manifests.
+          * * tags - A list of objects with tagFile and tagName properties,
+          *   describing tags that must be present and non-empty.
+          *
+          * @example
+          * [
+          *     { name: 'minimal' },
+          *     { name: 'complete', manifestsRequired: ['sha512'],
+          *       tags: [{ tagFile: 'bag-info.txt', tagName: 'Contact-Email' }] }
+          * ]
+          *
+          * @type {Array<object>}
+          * @default []
+          */
+        this.conformanceLevels = opts.conformanceLevels || [];
         /**
          * Contains information describing validation errors. Key is the
          * name of the invalid field. Value is a description of why the
@@ -276,9 +458,36 @@ class BagItProfile extends PersistentObject {
         if (!Util.listContains(Constants.REQUIREMENT_OPTIONS, this.serialization)) {
             this.errors["serialization"] = Context.y18n.__("Serialization must be one of: %s.", Constants.REQUIREMENT_OPTIONS.join(', '));
         }
-        if ((this.serialization == 'required' || this.serialzation == 'optional') &&
-            Util.isEmptyStringArray(this.acceptSerialization)) {
-            this.errors["acceptSerialization"] = Context.y18n.__("When serialization is allowed, you must specify at least one serialization format.");
+        if (!Number.isInteger(this.maxPayloadSize) || this.maxPayloadSize < 0) {
+            this.errors["maxPayloadSize"] = Context.y18n.__("Max payload size must be a whole number of bytes, or zero for no limit.");
+        }
+        if (!Number.isInteger(this.minPayloadSize) || this.minPayloadSize < 0) {
+            this.errors["minPayloadSize"] = Context.y18n.__("Min payload size must be a whole number of bytes, or zero for no minimum.");
+        } else if (this.maxPayloadSize > 0 && this.minPayloadSize > this.maxPayloadSize) {
+            this.errors["minPayloadSize"] = Context.y18n.__("Min payload size cannot be larger than max payload size.");
+        }
+        if (!Number.isInteger(this.maxBagSize) || this.maxBagSize < 0) {
+            this.errors["maxBagSize"] = Context.y18n.__("Max bag size must be a whole number of bytes, or zero for no limit.");
+        }
+        if (!Number.isInteger(this.maxTagFileSize) || this.maxTagFileSize < 0) {
+            this.errors["maxTagFileSize"] = Context.y18n.__("Max tag file size must be a whole number of bytes, or zero for no limit.");
+        }
+        if (!Number.isInteger(this.maxTotalMetadataSize) || this.maxTotalMetadataSize < 0) {
+            this.errors["maxTotalMetadataSize"] = Context.y18n.__("Max total metadata size must be a whole number of bytes, or zero for no limit.");
+        }
+        if (typeof this.tagDelimiter !== 'string' || this.tagDelimiter.length != 1 || /\s/.test(this.tagDelimiter)) {
+            this.errors["tagDelimiter"] = Context.y18n.__("Tag delimiter must be a single character other than whitespace.");
+        }
+        if (this.requiredSerialization && !Util.listContains(this.acceptSerialization, this.requiredSerialization)) {
+            this.errors["requiredSerialization"] = Context.y18n.__("Required serialization %s must be one of the accepted serialization formats.", this.requiredSerialization);
+        }
+        let badPatterns = this.tags.filter(t => !Util.isEmpty(t.pattern) && t.patternRegExp() == null);
+        if (badPatterns.length > 0) {
+            this.errors["tagPatterns"] = badPatterns.map(t =>
+                Context.y18n.__("Tag %s in %s has a pattern that is not a valid regular expression: %s", t.tagName, t.tagFile, t.pattern)).join("\n");
+        }
+        if (this.serialization == 'required' && Util.isEmptyStringArray(this.acceptSerialization)) {
+            this.errors["acceptSerialization"] = Context.y18n.__("When serialization is required, you must specify at least one serialization format.");
         }
         return Object.keys(this.errors).length == 0;
     }
@@ -318,7 +527,7 @@ class BagItProfile extends PersistentObject {
     /**
      * getTagsFromFile returns all tags with the specified tagname
      * from the specified file. This usually returns zero or one results,
-     * but because the {@link https://tools.ietf.org/html/draft-kunze-bagit-14|BagIt spec}
+     * but because the {@link https://tools.ietf.org/html/rfc8493|BagIt spec}
      * says a tag can appear multiple times in a file, this may return a list.
      *
      * @see also {@link findMatchingTags}
@@ -33,11 +33,26 @@ test('Constructor sets initial properties', () => {
     expect(profile.tagManifestsRequired).toEqual([]);
     expect(profile.tagManifestsAllowed).toEqual(Constants.DIGEST_ALGORITHMS);
     expect(profile.tagFilesAllowed).toEqual(['*']);
+    expect(profile.tagFilesRequired).toEqual([]);
     expect(profile.tags.length).toEqual(17);
     expect(profile.serialization).toEqual('optional');
     expect(profile.baseProfileId).toEqual(null);
     expect(profile.isBuiltIn).toEqual(false);
     expect(profile.tarDirMustMatchName).toEqual(false);
+    expect(profile.requireSortedManifests).toEqual(false);
+    expect(profile.allowOnlyRequiredManifests).toEqual(false);
+    expect(profile.requireSourceOrganizationMatch).toEqual(false);
+    expect(profile.requiredTagOrder).toEqual({});
+    expect(profile.tagFileAlgorithms).toEqual({});
+    expect(profile.maxPayloadSize).toEqual(0);
+    expect(profile.minPayloadSize).toEqual(0);
+    expect(profile.maxBagSize).toEqual(0);
+    expect(profile.maxTagFileSize).toEqual(0);
+    expect(profile.maxTotalMetadataSize).toEqual(0);
+    expect(profile.manifestFormat).toEqual('bagit');
+    expect(profile.tagDelimiter).toEqual(':');
+    expect(profile.requiredSerialization).toEqual('');
+    expect(profile.conformanceLevels).toEqual([]);
 });
 
 test('validate() catches invalid properties', () => {
@@ -48,6 +63,13 @@ test('validate() catches invalid properties', () => {
     profile.manifestsAllowed = [];
     profile.tags = [];
     profile.serialization = "Cap'n Crunch";
+    profile.maxPayloadSize = -1;
+    profile.minPayloadSize = -1;
+    profile.maxBagSize = 2.5;
+    profile.maxTagFileSize = 1.5;
+    profile.maxTotalMetadataSize = -10;
+    profile.tagDelimiter = ' ';
+    profile.requiredSerialization = 'application/zip';
     let result = profile.validate();
     expect(result).toEqual(false);
     expect(profile.errors['id']).toEqual('Id cannot be empty.');
@@ -56,6 +78,37 @@ test('validate() catches invalid properties', () => {
     expect(profile.errors['manifestsAllowed']).toEqual("Profile must allow at least one payload manifest algorithm.");
     expect(profile.errors['tags']).toEqual("Profile lacks requirements for bagit.txt tag file.\nProfile lacks requirements for bag-info.txt tag file.");
     expect(profile.errors['serialization']).toEqual("Serialization must be one of: required, optional, forbidden.");
+    expect(profile.errors['maxPayloadSize']).toEqual("Max payload size must be a whole number of bytes, or zero for no limit.");
+    expect(profile.errors['minPayloadSize']).toEqual("Min payload size must be a whole number of bytes, or zero for no minimum.");
+    expect(profile.errors['maxBagSize']).toEqual("Max bag size must be a whole number of bytes, or zero for no limit.");
+    expect(profile.errors['maxTagFileSize']).toEqual("Max tag file size must be a whole number of bytes, or zero for no limit.");
+    expect(profile.errors['maxTotalMetadataSize']).toEqual("Max total metadata size must be a whole number of bytes, or zero for no limit.");
+    expect(profile.errors['tagDelimiter']).toEqual("Tag delimiter must be a single character other than whitespace.");
+    expect(profile.errors['requiredSerialization']).toEqual("Required serialization application/zip must be one of the accepted serialization formats.");
+});
+
+test('validate() requires serialization formats only when serialization is required', () => {
+    let profile = new BagItProfile();
+    profile.acceptSerialization = [];
+    profile.serialization = 'optional';
+    expect(profile.validate()).toEqual(true);
+    expect(profile.errors['acceptSerialization']).toBeUndefined();
+
+    profile.serialization = 'forbidden';
+    expect(profile.validate()).toEqual(true);
+
+    profile.serialization = 'required';
+    expect(profile.validate()).toEqual(false);
+    expect(profile.errors['acceptSerialization']).toEqual("When serialization is required, you must specify at least one serialization format.");
+});
+
+test('validate() catches invalid tag patterns', () => {
+    let profile = new BagItProfile();
+    profile.getTagsFromFile('bag-info.txt', 'Bag-Count')[0].pattern = '\\d+ of (\\d+';
+    expect(profile.validate()).toEqual(false);
+    expect(profile.errors['tagPatterns']).toEqual("Tag Bag-Count in bag-info.txt has a pattern that is not a valid regular expression: \\d+ of (\\d+");
+    profile.getTagsFromFile('bag-info.txt', 'Bag-Count')[0].pattern = '\\d+ of \\d+';
+    expect(profile.validate()).toEqual(true);
 });
 
 test('findMatchingTags()', () => {
@@ -625,3 +678,14 @@ function makeObjects(howMany) {
     }
     return list;
 }
+
+test('validate() rejects minPayloadSize larger than maxPayloadSize', () => {
+    let profile = new BagItProfile();
+    profile.maxPayloadSize = 100;
+    profile.minPayloadSize = 101;
+    profile.validate();
+    expect(profile.errors['minPayloadSize']).toEqual("Min payload size cannot be larger than max payload size.");
+    profile.minPayloadSize = 100;
+    profile.validate();
+    expect(profile.errors['minPayloadSize']).toBeUndefined();
+});
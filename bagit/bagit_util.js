@@ -4,6 +4,40 @@ const { Constants } = require('../core/constants');
 const { Context } = require('../core/context');
 const { TagDefinition } = require('./tag_definition');
 
+/**
+ * These are the versions of the BagIt Profiles spec at
+ * https://github.com/bagit-profiles/bagit-profiles that
+ * profileFromStandardObject knows how to read. Profiles that declare
+ * another 1.x version are imported with a warning. Profiles that
+ * declare any other major version are rejected.
+ *
+ * @type {string[]}
+ */
+const SupportedProfileVersions = ['1.1.0', '1.2.0', '1.3.0'];
+
+/**
+ * These are the top-level fields of a standard BagIt profile that
+ * profileFromStandardObject copies into the DART profile. It ignores
+ * all others, including Payload-Files-Required, Payload-Files-Allowed,
+ * Fetch.txt-Required, and Data-Empty.
+ *
+ * @type {string[]}
+ */
+const ImportedProfileFields = [
+    'BagIt-Profile-Info',
+    'Bag-Info',
+    'Accept-BagIt-Version',
+    'Accept-Serialization',
+    'Allow-Fetch.txt',
+    'Serialization',
+    'Manifests-Required',
+    'Manifests-Allowed',
+    'Tag-Manifests-Required',
+    'Tag-Manifests-Allowed',
+    'Tag-Files-Allowed',
+    'Tag-Files-Required'
+];
+
 /**
  * BagItUtil contains some static utility functions to help with BagIt profiles.
  */
@@ -36,6 +70,10 @@ class BagItUtil {
      * @see {@link profileFromStandardJson}
      * @see {@link https://github.com/bagit-profiles/bagit-profiles|Standard BagIt Profiles}
      *
+     * This throws an error if the profile declares a BagIt-Profile-Version
+     * with a major version other than 1. It logs a warning for each
+     * problem {@link profileVersionWarnings} finds.
+     *
      * @param {Object} obj - The BagIt profile to convert.
      * @returns {BagItProfile} - A DART BagItProfile object.
      *
@@ -44,6 +82,13 @@ class BagItUtil {
         if (BagItUtil.guessProfileType(obj) != 'bagit_profiles') {
             throw Context.y18n.__("Object does not look like a BagIt profile");
         }
+        let specVersion = obj["BagIt-Profile-Info"]["BagIt-Profile-Version"];
+        if (specVersion && !String(specVersion).startsWith('1.')) {
+            throw Context.y18n.__("Profile declares BagIt-Profile-Version %s, which DART cannot read. Supported versions: %s", specVersion, SupportedProfileVersions.join(', '));
+        }
+        for (let warning of BagItUtil.profileVersionWarnings(obj)) {
+            Context.logger.warn(warning);
+        }
         var p = new BagItProfile();
         p.name = obj["BagIt-Profile-Info"]["External-Description"];
         p.description = Context.y18n.__("Imported from %s", obj["BagIt-Profile-Info"]["BagIt-Profile-Identifier"]);
@@ -56,6 +101,7 @@ class BagItUtil {
         p.tagManifestsRequired = obj["Tag-Manifests-Required"] || [];
         p.tagManifestsAllowed = obj["Tag-Manifests-Allowed"] || Constants.DIGEST_ALGORITHMS;
         p.tagFilesAllowed = obj["Tag-Files-Allowed"] || ["*"];
+        p.tagFilesRequired = obj["Tag-Files-Required"] || [];
 
         p.bagItProfileInfo = new BagItProfileInfo();
         p.bagItProfileInfo.bagItProfileIdentifier = obj["BagIt-Profile-Info"]["BagIt-Profile-Identifier"];
@@ -75,7 +121,7 @@ class BagItUtil {
         for (var tagName of Object.keys(obj["Bag-Info"])) {
             var tagDef;
             var tag = obj["Bag-Info"][tagName]
-            let tagsFromProfile = p.findMatchingTags("tagName", tagName).filter(t => t.tagFile = "bag-info.txt");
+            let tagsFromProfile = p.findMatchingTags("tagName", tagName).filter(t => t.tagFile == "bag-info.txt");
             if (tagsFromProfile.length > 0) {
                 tagDef = tagsFromProfile[0];
             } else {
@@ -86,6 +132,7 @@ class BagItUtil {
                 p.tags.push(tagDef);
             }
             tagDef.required = tag["required"] || false;
+            tagDef.repeatable = tag["repeatable"] === false ? false : true;
             tagDef.values = tag["values"] || [];
             tagDef.defaultValue = tag["defaultValue"] || null;
             if (Array.isArray(tag["values"]) && tag["values"].length == 1) {
@@ -95,6 +142,33 @@ class BagItUtil {
         return p;
     }
 
+    /**
+     * This function returns a list of warnings about parts of a standard
+     * BagIt profile that {@link profileFromStandardObject} won't import.
+     * That includes a BagIt-Profile-Version that DART doesn't fully
+     * support, and top-level fields that DART ignores. Without these
+     * warnings, the ignored settings would silently fall back to
+     * DART's defaults.
+     *
+     * @param {Object} obj - The standard BagIt profile.
+     * @returns {string[]}
+     *
+     */
+    static profileVersionWarnings(obj) {
+        let warnings = [];
+        let specVersion = (obj["BagIt-Profile-Info"] || {})["BagIt-Profile-Version"];
+        if (!specVersion) {
+            warnings.push(Context.y18n.__("Profile does not declare a BagIt-Profile-Version. DART will read it as version %s.", SupportedProfileVersions[SupportedProfileVersions.length - 1]));
+        } else if (!SupportedProfileVersions.includes(String(specVersion))) {
+            warnings.push(Context.y18n.__("Profile declares BagIt-Profile-Version %s, which DART does not fully support. Supported versions: %s", specVersion, SupportedProfileVersions.join(', ')));
+        }
+        let ignored = Object.keys(obj).filter(key => !ImportedProfileFields.includes(key));
+        if (ignored.length > 0) {
+            warnings.push(Context.y18n.__("DART will ignore these profile fields: %s", ignored.join(', ')));
+        }
+        return warnings;
+    }
+
     /**
      * This function tries to guess the type of BagIt Profile based on
      * the keys in the profile object. Returns one of the following:
@@ -271,7 +345,7 @@ class BagItUtil {
 
         // Tags
         obj["Bag-Info"] = {};
-        obj["Tag-Files-Required"] = [];
+        obj["Tag-Files-Required"] = (p.tagFilesRequired || []).slice();
         for (let tagDef of p.tags) {
             if (tagDef.tagFile == "bagit.txt") {
                 continue;
@@ -279,6 +353,9 @@ class BagItUtil {
             if (tagDef.tagFile == "bag-info.txt") {
                 obj["Bag-Info"][tagDef.tagName] = {};
                 obj["Bag-Info"][tagDef.tagName]["required"] = tagDef.required;
+                if (tagDef.repeatable === false) {
+                    obj["Bag-Info"][tagDef.tagName]["repeatable"] = false;
+                }
                 if (Array.isArray(tagDef.values) && tagDef.values.length) {
                     obj["Bag-Info"][tagDef.tagName]["values"] = tagDef.values;
                 }
@@ -8,6 +8,7 @@ const { TestUtil } = require('../core/test_util');
 const BASE_PATH = path.join(__dirname, '..', 'test', 'profiles', 'bagit_profiles_github');
 const FOO_PATH = path.join(BASE_PATH, 'bagProfileFoo.json');
 const BAR_PATH = path.join(BASE_PATH, 'bagProfileBar.json');
+const BAZ_PATH = path.join(BASE_PATH, 'bagProfileBaz.json');
 
 const LOC_PATH = path.join(__dirname, '..', 'test', 'profiles', 'loc');
 const LOC_ORDERED_PATH = path.join(LOC_PATH, 'SANC-state-profile.json');
@@ -64,16 +65,73 @@ test('profileFromStandardJson with tag files', () => {
 
     expect(convertedProfile.tagFilesAllowed.length).toEqual(1);
     expect(convertedProfile.tagFilesAllowed).toEqual(origProfile["Tag-Files-Allowed"]);
+    expect(convertedProfile.tagFilesRequired.length).toEqual(2);
+    expect(convertedProfile.tagFilesRequired).toEqual(origProfile["Tag-Files-Required"]);
+})
+
+test('Tag-Files-Required survives import and export', () => {
+    let origProfile = JSON.parse(fs.readFileSync(BAR_PATH).toString());
+    let convertedProfile = BagItUtil.profileFromStandardObject(origProfile);
+    let exported = BagItUtil.profileToStandardObject(convertedProfile);
+    expect(exported["Tag-Files-Required"]).toEqual(origProfile["Tag-Files-Required"]);
 
-    // A.D. - 2019-09-05
-    //
-    // We're not currently supporting tagFilesRequired because we want
-    // a change to the spec. See https://trello.com/c/SBLvoiwK
-    //
-    // expect(convertedProfile.tagFilesRequired.length).toEqual(2);
-    // expect(convertedProfile.tagFilesRequired).toEqual(origProfile["Tag-Files-Required"]);
+    let reimported = BagItUtil.profileFromStandardObject(exported);
+    expect(reimported.tagFilesRequired).toEqual(origProfile["Tag-Files-Required"]);
 })
 
+test('profileFromStandardObject() imports repeatable', () => {
+    let obj = JSON.parse(fs.readFileSync(FOO_PATH).toString());
+    obj["Bag-Info"]["Contact-Phone"]["repeatable"] = false;
+    obj["Bag-Info"]["BagIt-Version"] = { "required": false };
+    let profile = BagItUtil.profileFromStandardObject(obj);
+    expect(profile.firstMatchingTag("tagName", "Contact-Phone").repeatable).toBe(false);
+    expect(profile.firstMatchingTag("tagName", "Source-Organization").repeatable).toBe(true);
+
+    // Bag-Info tags don't change tags with the same name in other files.
+    expect(profile.getTagsFromFile("bagit.txt", "BagIt-Version")[0].required).toBe(true);
+    expect(profile.getTagsFromFile("bag-info.txt", "BagIt-Version").length).toEqual(1);
+
+    let exported = BagItUtil.profileToStandardObject(profile);
+    expect(exported["Bag-Info"]["Contact-Phone"]).toEqual({ "required": true, "repeatable": false });
+    expect(exported["Bag-Info"]["Source-Organization"]["repeatable"]).toBeUndefined();
+});
+
+test('profileVersionWarnings() accepts supported version', () => {
+    let obj = JSON.parse(fs.readFileSync(FOO_PATH).toString());
+    expect(BagItUtil.profileVersionWarnings(obj)).toEqual([]);
+
+    obj = JSON.parse(fs.readFileSync(BAR_PATH).toString());
+    expect(BagItUtil.profileVersionWarnings(obj)).toEqual([]);
+});
+
+test('profileVersionWarnings() flags unsupported version and ignored fields', () => {
+    let obj = JSON.parse(fs.readFileSync(BAZ_PATH).toString());
+    expect(BagItUtil.profileVersionWarnings(obj)).toEqual([
+        Context.y18n.__("Profile declares BagIt-Profile-Version %s, which DART does not fully support. Supported versions: %s", "1.4.0", "1.1.0, 1.2.0, 1.3.0"),
+        Context.y18n.__("DART will ignore these profile fields: %s", "Fetch.txt-Required, Data-Empty, Payload-Files-Required")
+    ]);
+
+    // Profile still loads, but without the ignored settings.
+    let profile = BagItUtil.profileFromStandardObject(obj);
+    expect(profile.bagItProfileInfo.bagItProfileVersion).toEqual("1.4.0");
+    expect(profile.manifestsRequired).toEqual(["sha256"]);
+});
+
+test('profileVersionWarnings() flags missing version', () => {
+    let obj = JSON.parse(fs.readFileSync(FOO_PATH).toString());
+    delete obj["BagIt-Profile-Info"]["BagIt-Profile-Version"];
+    expect(BagItUtil.profileVersionWarnings(obj)).toEqual([
+        Context.y18n.__("Profile does not declare a BagIt-Profile-Version. DART will read it as version %s.", "1.3.0")
+    ]);
+});
+
+test('profileFromStandardObject() rejects unsupported major version', () => {
+    let obj = JSON.parse(fs.readFileSync(FOO_PATH).toString());
+    obj["BagIt-Profile-Info"]["BagIt-Profile-Version"] = "2.0.0";
+    expect(() => { BagItUtil.profileFromStandardObject(obj) }).toThrow(
+        Context.y18n.__("Profile declares BagIt-Profile-Version %s, which DART cannot read. Supported versions: %s", "2.0.0", "1.1.0, 1.2.0, 1.3.0"));
+});
+
 test('profileToStandardObject', () => {
     let profile = TestUtil.loadProfile('multi_manifest.json');
     let obj = BagItUtil.profileToStandardObject(profile);
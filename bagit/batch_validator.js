@@ -0,0 +1,154 @@
+const { Validator } = require('./validator');
+
+/**
+ * BatchValidator validates a set of bags against a single BagIt profile,
+ * then runs checks that span the whole batch, such as making sure no two
+ * bags declare the same Internal-Sender-Identifier.
+ *
+ * Each bag is validated by its own {@link Validator}, one at a time.
+ * Problems with individual bags are in each validator's errors and
+ * warnings. Problems that involve more than one bag are in the
+ * BatchValidator's own errors.
+ *
+ * @example
+ *
+ * let batch = new BatchValidator(['/bags/one.tar', '/bags/two.tar'], profile);
+ * batch.validate().then(function() {
+ *     for (let validator of batch.validators) {
+ *         console.log(validator.pathToBag, validator.errors);
+ *     }
+ *     console.log(batch.errors);
+ * });
+ *
+ */
+class BatchValidator {
+
+    /**
+     * Constructs a new BatchValidator.
+     *
+     * @param {Array<string>} pathsToBags - The absolute paths to the bags
+     * to validate. Each may be a directory or a tar file.
+     *
+     * @param {BagItProfile} profile - The BagItProfile to validate each
+     * bag against.
+     *
+     */
+    constructor(pathsToBags, profile) {
+        /**
+         * pathsToBags is the list of paths to the bags in the batch.
+         *
+         * @type {Array<string>}
+         */
+        this.pathsToBags = pathsToBags;
+        /**
+         * profile is the BagItProfile against which we will validate
+         * each bag in the batch.
+         *
+         * @type {BagItProfile}
+         */
+        this.profile = profile;
+        /**
+         * validators contains one {@link Validator} for each bag in the
+         * batch, in the same order as pathsToBags. This is populated
+         * during validation.
+         *
+         * @type {Array<Validator>}
+         */
+        this.validators = [];
+        /**
+         * errors is a list of problems that involve more than one bag
+         * in the batch.
+         *
+         * @type {Array<string>}
+         */
+        this.errors = [];
+    }
+
+    /**
+     * validate validates each bag in the batch, then runs the cross-bag
+     * checks. It returns a Promise that resolves when all of that is
+     * done. Check the errors of this object and of each validator in
+     * validators to see the results.
+     *
+     * @returns {Promise}
+     */
+    validate() {
+        var batchValidator = this;
+        this.validators = [];
+        this.errors = [];
+        let chain = Promise.resolve();
+        for (let pathToBag of this.pathsToBags) {
+            chain = chain.then(function() {
+                return batchValidator._validateBag(pathToBag);
+            });
+        }
+        return chain.then(function() {
+            batchValidator._validateBatch();
+        });
+    }
+
+    /**
+     * _validateBatch runs the checks that span the whole batch, after
+     * every bag has been validated. Subclasses that need different
+     * cross-bag checks can override this.
+     *
+     * @private
+     */
+    _validateBatch() {
+        this._validateUniqueSenderIdentifiers();
+    }
+
+    /**
+     * _validateBag validates a single bag and adds its validator to
+     * this.validators. The returned Promise resolves when the validator
+     * finishes or emits an error. It does not reject, because a bad bag
+     * should not stop validation of the rest of the batch.
+     *
+     * @param {string} pathToBag - The path to the bag.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _validateBag(pathToBag) {
+        let validator = new Validator(pathToBag, this.profile);
+        this.validators.push(validator);
+        return new Promise(function(resolve, reject) {
+            validator.on('error', function(err) {
+                resolve();
+            });
+            validator.on('end', function() {
+                resolve();
+            });
+            validator.validate();
+        });
+    }
+
+    /**
+     * _validateUniqueSenderIdentifiers adds an error for each
+     * Internal-Sender-Identifier in bag-info.txt that appears in more
+     * than one bag in the batch. This usually means the same bag was
+     * submitted twice by accident.
+     *
+     * @private
+     */
+    _validateUniqueSenderIdentifiers() {
+        let bagsById = {};
+        for (let validator of this.validators) {
+            let ids = new Set(validator.tagValues('bag-info.txt', 'Internal-Sender-Identifier'));
+            for (let id of ids) {
+                if (!bagsById[id]) {
+                    bagsById[id] = [];
+                }
+                bagsById[id].push(validator.pathToBag);
+            }
+        }
+        for (let [id, paths] of Object.entries(bagsById)) {
+            if (paths.length > 1) {
+                this.errors.push(`Internal-Sender-Identifier '${id}' appears in more than one bag: ${paths.join(', ')}`);
+            }
+        }
+    }
+}
+
+module.exports.BatchValidator = BatchValidator;
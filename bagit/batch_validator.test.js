@@ -0,0 +1,53 @@
+const { BagItProfile } = require('./bagit_profile');
+const { BatchValidator } = require('./batch_validator');
+const path = require('path');
+
+function bagPath(bagName) {
+    return path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+}
+
+test('Constructor sets expected properties', () => {
+    let profile = new BagItProfile();
+    let batch = new BatchValidator([bagPath("valid_bag")], profile);
+    expect(batch.pathsToBags).toEqual([bagPath("valid_bag")]);
+    expect(batch.profile).toBe(profile);
+    expect(batch.validators).toEqual([]);
+    expect(batch.errors).toEqual([]);
+});
+
+test('validate() reports duplicate Internal-Sender-Identifier', () => {
+    let paths = [
+        bagPath("sender_id_1"),
+        bagPath("valid_bag"),
+        bagPath("sender_id_2"),
+        bagPath("sender_id_3")
+    ];
+    let batch = new BatchValidator(paths, new BagItProfile());
+    return batch.validate().then(function() {
+        expect(batch.validators.map(v => v.pathToBag)).toEqual(paths);
+        for (let validator of batch.validators) {
+            expect(validator.errors).toEqual([]);
+        }
+        expect(batch.errors).toEqual([
+            `Internal-Sender-Identifier 'ACC-2021-001' appears in more than one bag: ${paths[0]}, ${paths[2]}`
+        ]);
+    });
+});
+
+test('validate() accepts unique Internal-Sender-Identifiers', () => {
+    let paths = [bagPath("sender_id_1"), bagPath("sender_id_3")];
+    let batch = new BatchValidator(paths, new BagItProfile());
+    return batch.validate().then(function() {
+        expect(batch.errors).toEqual([]);
+    });
+});
+
+test('validate() keeps going after a bag fails', () => {
+    let paths = [bagPath("does_not_exist"), bagPath("sender_id_1")];
+    let batch = new BatchValidator(paths, new BagItProfile());
+    return batch.validate().then(function() {
+        expect(batch.validators.length).toEqual(2);
+        expect(batch.validators[0].errors.length).toEqual(1);
+        expect(batch.validators[1].errors).toEqual([]);
+    });
+});
@@ -0,0 +1,50 @@
+/**
+ * FixityVerifier is the base class for objects that look up a file's
+ * expected checksum in a trusted, external fixity registry, such as a
+ * preservation service's API. This provides a check that's independent
+ * of the bag's own manifests, which may have been altered along with
+ * the payload.
+ *
+ * When the {@link Validator} has a fixityVerifier, it asks it for the
+ * expected digest of each payload file, for each algorithm it computes.
+ * It reports an error if the registry's digest does not match the
+ * digest it computed.
+ *
+ * Subclasses must implement expectedChecksum().
+ *
+ * @example
+ *
+ * class RegistryVerifier extends FixityVerifier {
+ *     expectedChecksum(relPath, algorithm) {
+ *         return registryClient.lookup(bagName, relPath, algorithm); // Promise<string|null>
+ *     }
+ * }
+ * validator.fixityVerifier = new RegistryVerifier();
+ *
+ */
+class FixityVerifier {
+    constructor() {
+
+    }
+
+    /**
+     * expectedChecksum returns a Promise that resolves to the digest the
+     * registry has on record for the specified file and algorithm, or to
+     * null if the registry has no record of it. The promise should reject
+     * with an Error if the registry could not be queried.
+     *
+     * Subclasses MUST override this method and must not call super().
+     *
+     * @param {string} relPath - The relative path of the file within the
+     * bag. E.g. 'data/photos/img.jpg'.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @returns {Promise<string|null>}
+     */
+    expectedChecksum(relPath, algorithm) {
+        throw new Error('This method must be implemented in the subclass.');
+    }
+}
+
+module.exports.FixityVerifier = FixityVerifier;
@@ -0,0 +1,6 @@
+const { FixityVerifier } = require('./fixity_verifier');
+
+test('expectedChecksum() must be implemented in subclass', () => {
+    let verifier = new FixityVerifier();
+    expect(() => { verifier.expectedChecksum('data/file.txt', 'sha256') }).toThrow('This method must be implemented in the subclass.');
+});
@@ -1,23 +1,43 @@
 const { Bagger } = require('./bagger');
+const { BagRepairer } = require('./bag_repairer');
 const { BagItFile } = require('./bagit_file');
 const { BagItProfile } = require('./bagit_profile');
 const { BagItProfileInfo } = require('./bagit_profile_info');
 const { BagItUtil } = require('./bagit_util');
+const { BatchValidator } = require('./batch_validator');
+const { FixityVerifier } = require('./fixity_verifier');
 const { KeyValueCollection } = require('./key_value_collection');
 const { ManifestParser } = require('./manifest_parser');
+const { MultipartValidator } = require('./multipart_validator');
+const { OpenBagValidator } = require('./open_bag_validator');
+const { SignatureVerifier } = require('./signature_verifier');
 const { TagDefinition } = require('./tag_definition');
 const { TagFileParser } = require('./tag_file_parser');
 const { TaskDescription } = require('./task_description');
+const { ValidationCache } = require('./validation_cache');
+const { ValidationCheckpoint } = require('./validation_checkpoint');
+const { ValidationError } = require('./validation_error');
 const { Validator } = require('./validator');
+const { ValueResolver } = require('./value_resolver');
 
 module.exports.Bagger = Bagger;
+module.exports.BagRepairer = BagRepairer;
 module.exports.BagItFile = BagItFile;
 module.exports.BagItProfile = BagItProfile;
 module.exports.BagItProfileInfo = BagItProfileInfo;
 module.exports.BagItUtil = BagItUtil;
+module.exports.BatchValidator = BatchValidator;
+module.exports.FixityVerifier = FixityVerifier;
 module.exports.KeyValueCollection = KeyValueCollection;
 module.exports.ManifestParser = ManifestParser;
+module.exports.MultipartValidator = MultipartValidator;
+module.exports.OpenBagValidator = OpenBagValidator;
+module.exports.SignatureVerifier = SignatureVerifier;
 module.exports.TagDefinition = TagDefinition;
 module.exports.TagFileParser = TagFileParser;
 module.exports.TaskDescription = TaskDescription;
+module.exports.ValidationCache = ValidationCache;
+module.exports.ValidationCheckpoint = ValidationCheckpoint;
+module.exports.ValidationError = ValidationError;
 module.exports.Validator = Validator;
+module.exports.ValueResolver = ValueResolver;
@@ -7,11 +7,13 @@
   * Tag file data should use the tag name as the key and the tag
   * value as the value. Each tag may appear multiple times in a tag
   * file, so tag names may have multiple values. This collection preserves
-  * the order of those values.
+  * the order of those values, as well as the order in which each key
+  * first appeared.
 */
 class KeyValueCollection {
     constructor() {
         this.items = {};
+        this.keyOrder = [];
     }
     /**
      * Adds a key with the specified value to the collection.
@@ -24,6 +26,7 @@ class KeyValueCollection {
     add(key, value) {
         if (!this.items.hasOwnProperty(key)) {
             this.items[key] = [];
+            this.keyOrder.push(key);
         }
         this.items[key].push(value);
     }
@@ -61,12 +64,13 @@ class KeyValueCollection {
         return null;
     }
     /**
-      * keys returns all keys in the collection
+      * keys returns all keys in the collection, in the order
+      * in which they were first added.
       *
       * @returns {Array}
       */
     keys() {
-        return Object.keys(this.items);
+        return this.keyOrder.slice();
     }
     /**
       * Returns all the keys in sorted order.
@@ -51,6 +51,16 @@ test('keys() returns all keys in the collection', () => {
     expect(keys).toContain('cherry');
 });
 
+test('keys() preserves the order in which keys were added', () => {
+    let collection = new KeyValueCollection();
+    collection.add('orange', 'orange');
+    collection.add('2', 'two');
+    collection.add('apple', 'red');
+    collection.add('orange', 'also orange');
+    collection.add('1', 'one');
+    expect(collection.keys()).toEqual(['orange', '2', 'apple', '1']);
+});
+
 test('sortedKeys() returns all keys in the collection in order', () => {
     let collection = new KeyValueCollection();
     collection.add('apple', 'red');
@@ -5,6 +5,22 @@ const { PassThrough } = require('stream');
 const spaces = /\s+/;
 const newline = "\n";
 
+/**
+ * These are the characters the BagIt spec requires to be percent-encoded
+ * in file names in manifests and fetch.txt, and their encodings.
+ *
+ * @type {Object<string, string>}
+ */
+const pathEncodings = { "\n": "%0A", "\r": "%0D", "%": "%25" };
+
+/**
+ * This is the registry of manifest parsers, keyed by format name.
+ * See {@link ManifestParser.register}.
+ *
+ * @type {Object<string, ManifestParser>}
+ */
+const parsers = {};
+
 /**
  * ManifestParser parses text-based payload and tag manifests that
  * conform to the BagIt spec. These files have the following format:
@@ -15,8 +31,8 @@ const newline = "\n";
  * relative path (within the bag) of the file it was calculated from.
  * The two items are separated by one or more spaces.
  *
- * This class has no methods. It simply responds to events on the stream
- * you pipe into it. After parsing the stream, it stores the data it
+ * This class responds to events on the stream you pipe into it, passing
+ * each line to parseLine(). After parsing the stream, it stores the data it
  * has parsed in bagItFile.keyValueCollection. Within that collection,
  * you can use the first() method to look up the checksum for a file.
  *
@@ -28,8 +44,13 @@ const newline = "\n";
  * does not already have a keyValueCollection, the parser will create
  * one.
  *
+ * To parse manifests in a non-standard format, extend this class,
+ * override parseLine(), and register your class with
+ * {@link ManifestParser.register}. The validator uses the parser
+ * registered under the name in {@link BagItProfile#manifestFormat}.
+ *
  * For more on the BagIt spec,
- * see {@link https://tools.ietf.org/html/draft-kunze-bagit-17|BagIt Spec}
+ * see {@link https://tools.ietf.org/html/rfc8493|BagIt Spec}
  *
  * For info about how to read the parsed data from the file, see {@link KeyValueCollection}
  *
@@ -87,6 +108,24 @@ class ManifestParser {
          */
         this.lastFragment = '';
 
+        /**
+         * lineNumber is the number of the last line parsed.
+         *
+         * @private
+         * @type {number}
+         */
+        this.lineNumber = 0;
+
+        /**
+         * entries lists the entries the parser found, in the order
+         * they appear in the manifest. Each entry has the lineNumber,
+         * filename, and digest. Unlike bagItFile.keyValueCollection,
+         * this preserves the manifest's order and line numbers.
+         *
+         * @type {Array<{lineNumber: number, filename: string, digest: string}>}
+         */
+        this.entries = [];
+
         var parser = this;
         if (bagItFile.keyValueCollection == null) {
             bagItFile.keyValueCollection = new KeyValueCollection();
@@ -110,11 +149,16 @@ class ManifestParser {
                 // First item on line is the fixity value.
                 // Second item is file name, which may contain multiple spaces.
                 if (i < lastIndex) {
-                    var fixityValue = line.split(spaces, 1)[0];
-                    var filename = line.replace(fixityValue, '').trim();
-                    //Context.logger.debug(`"${filename}" = "${fixityValue}"`);
-                    if (filename != '' && fixityValue != '') {
-                        parser.bagItFile.keyValueCollection.add(filename, fixityValue);
+                    parser.lineNumber += 1;
+                    var entry = parser.parseLine(line);
+                    if (entry != null) {
+                        //Context.logger.debug(`"${entry.filename}" = "${entry.digest}"`);
+                        parser.bagItFile.keyValueCollection.add(entry.filename, entry.digest);
+                        parser.entries.push({
+                            lineNumber: parser.lineNumber,
+                            filename: entry.filename,
+                            digest: entry.digest
+                        });
                     }
                 }
             }
@@ -125,6 +169,106 @@ class ManifestParser {
             //Context.logger.debug(`Finished parsing manifest ${parser.bagItFile.absPath}`);
         });
     }
+
+    /**
+     * parseLine parses a single line of a manifest and returns an object
+     * with the relative path of the file and its digest, or null if the
+     * line does not contain both. Subclasses that parse non-standard
+     * manifest formats should override this.
+     *
+     * @param {string} line - One line from the manifest, without the
+     * trailing newline.
+     *
+     * @returns {{filename: string, digest: string}|null}
+     */
+    parseLine(line) {
+        var fixityValue = line.split(spaces, 1)[0];
+        var filename = line.replace(fixityValue, '').trim();
+        if (filename == '' || fixityValue == '') {
+            return null;
+        }
+        return { filename: ManifestParser.decodePath(filename), digest: fixityValue };
+    }
+
+    /**
+     * decodePath decodes the percent-encoded line feeds (%0A), carriage
+     * returns (%0D), and percent signs (%25) in a file name from a
+     * manifest or fetch.txt. The BagIt spec requires only these three
+     * characters to be encoded, so this leaves any other percent
+     * sequences alone. Hex digits may be upper or lower case.
+     *
+     * @param {string} filename - A file name as it appears in a manifest.
+     *
+     * @returns {string}
+     */
+    static decodePath(filename) {
+        return filename.replace(/%(0A|0D|25)/gi, (match) => decodeURIComponent(match));
+    }
+
+    /**
+     * encodePath percent-encodes the line feeds, carriage returns, and
+     * percent signs in a file name, so that it can be written to a
+     * manifest or fetch.txt. This is the inverse of
+     * {@link ManifestParser.decodePath}.
+     *
+     * @param {string} filename - A file name as it appears in the bag.
+     *
+     * @returns {string}
+     */
+    static encodePath(filename) {
+        return filename.replace(/[\n\r%]/g, (match) => pathEncodings[match]);
+    }
+
+    /**
+     * register adds a manifest parser class to the registry under the
+     * specified format name. The format name 'bagit' is reserved for the
+     * standard BagIt manifest format. Registering a class under an
+     * existing name replaces the old class.
+     *
+     * @example
+     *
+     * class TsvManifestParser extends ManifestParser {
+     *     parseLine(line) {
+     *         let [digest, filename] = line.split("\t");
+     *         return filename ? { filename: filename, digest: digest } : null;
+     *     }
+     * }
+     * ManifestParser.register('tsv', TsvManifestParser);
+     *
+     * @param {string} format - The name of the manifest format.
+     *
+     * @param {ManifestParser} parserClass - A class that extends
+     * ManifestParser.
+     */
+    static register(format, parserClass) {
+        if (format == 'bagit') {
+            throw new Error("The 'bagit' manifest format is reserved.");
+        }
+        parsers[format] = parserClass;
+    }
+
+    /**
+     * getParser returns the manifest parser class registered under the
+     * specified format name, or undefined if there is none.
+     *
+     * @param {string} format - The name of the manifest format.
+     *
+     * @returns {ManifestParser}
+     */
+    static getParser(format) {
+        return parsers[format];
+    }
+
+    /**
+     * formats returns the names of all registered manifest formats.
+     *
+     * @returns {string[]}
+     */
+    static formats() {
+        return Object.keys(parsers);
+    }
 }
 
+parsers['bagit'] = ManifestParser;
+
 module.exports.ManifestParser = ManifestParser;
@@ -31,6 +31,12 @@ test('ManifestParser', done => {
             .toEqual("d277af754c362c65ffc96b8b4393651187f8a5e17ca96d1aef18e9738fa5be23");
         expect(bagItFile.keyValueCollection.first("data/object.properties"))
             .toEqual("8d4b18a74df88c24ab17e67fac4b26b6c8e44a145cc39f93bb7b7a35b622b6f3");
+        expect(manifestParser.entries.map(e => [e.lineNumber, e.filename])).toEqual([
+            [1, "data/ORIGINAL/1"],
+            [2, "data/ORIGINAL/1-metadata.xml"],
+            [3, "data/metadata.xml"],
+            [4, "data/object.properties"]
+        ]);
         done();
     }
 
@@ -39,3 +45,49 @@ test('ManifestParser', done => {
     manifestParser.stream.on('end', testParseResults);
     stream.pipe(manifestParser.stream);
 });
+
+test('parseLine()', () => {
+    let parser = new ManifestParser({});
+    expect(parser.parseLine("1234abcd  data/file with spaces.txt")).toEqual({
+        filename: "data/file with spaces.txt",
+        digest: "1234abcd"
+    });
+    expect(parser.parseLine("1234abcd  data/100%25%0Adone.txt")).toEqual({
+        filename: "data/100%\ndone.txt",
+        digest: "1234abcd"
+    });
+    expect(parser.parseLine("")).toBeNull();
+    expect(parser.parseLine("1234abcd")).toBeNull();
+});
+
+test('decodePath() and encodePath()', () => {
+    expect(ManifestParser.decodePath("data/a%0Ab.txt")).toEqual("data/a\nb.txt");
+    expect(ManifestParser.decodePath("data/a%0db.txt")).toEqual("data/a\rb.txt");
+    expect(ManifestParser.decodePath("data/100%25.txt")).toEqual("data/100%.txt");
+    expect(ManifestParser.decodePath("data/100%2525.txt")).toEqual("data/100%25.txt");
+    expect(ManifestParser.decodePath("data/a%20b.txt")).toEqual("data/a%20b.txt");
+
+    expect(ManifestParser.encodePath("data/a\nb.txt")).toEqual("data/a%0Ab.txt");
+    expect(ManifestParser.encodePath("data/a\rb.txt")).toEqual("data/a%0Db.txt");
+    expect(ManifestParser.encodePath("data/100%.txt")).toEqual("data/100%25.txt");
+
+    for (let name of ["data/\r\n%0A%25.txt", "data/plain.txt", "data/%%\n\n"]) {
+        expect(ManifestParser.decodePath(ManifestParser.encodePath(name))).toEqual(name);
+    }
+});
+
+test('register() and getParser()', () => {
+    class TsvManifestParser extends ManifestParser {
+        parseLine(line) {
+            let [digest, filename] = line.split("\t");
+            return filename ? { filename: filename, digest: digest } : null;
+        }
+    }
+    expect(ManifestParser.getParser('bagit')).toEqual(ManifestParser);
+    expect(ManifestParser.getParser('tsv')).toBeUndefined();
+    ManifestParser.register('tsv', TsvManifestParser);
+    expect(ManifestParser.getParser('tsv')).toEqual(TsvManifestParser);
+    expect(ManifestParser.formats()).toContain('bagit');
+    expect(ManifestParser.formats()).toContain('tsv');
+    expect(() => { ManifestParser.register('bagit', TsvManifestParser) }).toThrow("The 'bagit' manifest format is reserved.");
+});
@@ -0,0 +1,104 @@
+const { BatchValidator } = require('./batch_validator');
+const { Util } = require('../core/util');
+
+/**
+ * MultipartValidator validates the parts of a multipart bag against a
+ * size-based chunking policy. Each part is validated as a bag in its own
+ * right, then the validator checks that:
+ *
+ * * no part's payload is larger than the Bag-Size it declares in
+ *   bag-info.txt, and
+ * * no payload file is split across parts. Each file must be whole
+ *   within a single part, so no relative path may appear in more than
+ *   one part.
+ *
+ * Problems with individual parts are in each validator's errors.
+ * Problems with the chunking policy are in the MultipartValidator's own
+ * errors.
+ *
+ * @example
+ *
+ * let parts = ['/bags/photos.b01.of02.tar', '/bags/photos.b02.of02.tar'];
+ * let multipart = new MultipartValidator(parts, profile);
+ * multipart.validate().then(function() {
+ *     console.log(multipart.errors);
+ * });
+ *
+ */
+class MultipartValidator extends BatchValidator {
+
+    /**
+     * Constructs a new MultipartValidator.
+     *
+     * @param {Array<string>} pathsToParts - The absolute paths to the
+     * parts of the bag. Each may be a directory or a tar file.
+     *
+     * @param {BagItProfile} profile - The BagItProfile to validate each
+     * part against.
+     *
+     */
+    constructor(pathsToParts, profile) {
+        super(pathsToParts, profile);
+    }
+
+    /**
+     * _validateBatch checks each part's size against its declared
+     * Bag-Size, and makes sure no file is split across parts.
+     *
+     * @private
+     */
+    _validateBatch() {
+        this._validatePartSizes();
+        this._validateFileBoundaries();
+    }
+
+    /**
+     * _validatePartSizes adds an error for each part that doesn't
+     * declare a Bag-Size, or whose payload is larger than its Bag-Size.
+     * Since Bag-Size is a human-readable value rounded to two decimal
+     * places, the payload is rounded the same way before comparing.
+     *
+     * @private
+     */
+    _validatePartSizes() {
+        for (let validator of this.validators) {
+            let declared = validator.tagValues('bag-info.txt', 'Bag-Size')[0];
+            let cap = Util.fromHumanSize(declared);
+            if (isNaN(cap)) {
+                this.errors.push(`Part ${validator.pathToBag} does not declare a valid Bag-Size.`);
+                continue;
+            }
+            let byteCount = validator.payloadByteCount();
+            if (Util.fromHumanSize(Util.toHumanSize(byteCount)) > cap) {
+                this.errors.push(`Part ${validator.pathToBag} has ${byteCount} bytes of payload, which exceeds its declared Bag-Size of ${declared}.`);
+            }
+        }
+    }
+
+    /**
+     * _validateFileBoundaries adds an error for each payload file that
+     * appears in more than one part, which means the file was split
+     * across parts instead of being kept whole.
+     *
+     * @private
+     */
+    _validateFileBoundaries() {
+        let partsByFile = {};
+        for (let validator of this.validators) {
+            for (let f of validator.payloadFiles()) {
+                if (!partsByFile[f.relDestPath]) {
+                    partsByFile[f.relDestPath] = [];
+                }
+                partsByFile[f.relDestPath].push(validator.pathToBag);
+            }
+        }
+        for (let relPath of Object.keys(partsByFile).sort()) {
+            let paths = partsByFile[relPath];
+            if (paths.length > 1) {
+                this.errors.push(`Payload file ${relPath} is split across more than one part: ${paths.join(', ')}`);
+            }
+        }
+    }
+}
+
+module.exports.MultipartValidator = MultipartValidator;
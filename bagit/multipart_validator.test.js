@@ -0,0 +1,56 @@
+const { BagItProfile } = require('./bagit_profile');
+const { MultipartValidator } = require('./multipart_validator');
+const path = require('path');
+
+function bagPath(bagName) {
+    return path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+}
+
+test('validate() accepts parts that follow the chunking policy', () => {
+    let paths = [bagPath("multipart_part_1"), bagPath("multipart_part_2")];
+    let multipart = new MultipartValidator(paths, new BagItProfile());
+    return multipart.validate().then(function() {
+        expect(multipart.validators.map(v => v.pathToBag)).toEqual(paths);
+        for (let validator of multipart.validators) {
+            expect(validator.errors).toEqual([]);
+        }
+        expect(multipart.errors).toEqual([]);
+    });
+});
+
+test('validate() reports payload files split across parts', () => {
+    let paths = [
+        bagPath("multipart_part_1"),
+        bagPath("multipart_part_2"),
+        bagPath("multipart_part_3")
+    ];
+    let multipart = new MultipartValidator(paths, new BagItProfile());
+    return multipart.validate().then(function() {
+        for (let validator of multipart.validators) {
+            expect(validator.errors).toEqual([]);
+        }
+        expect(multipart.errors).toEqual([
+            `Payload file data/photo1.txt is split across more than one part: ${paths[0]}, ${paths[2]}`
+        ]);
+    });
+});
+
+test('validate() reports parts larger than their declared Bag-Size', () => {
+    let paths = [bagPath("multipart_part_1"), bagPath("multipart_part_4")];
+    let multipart = new MultipartValidator(paths, new BagItProfile());
+    return multipart.validate().then(function() {
+        expect(multipart.errors).toEqual([
+            `Part ${paths[1]} has 53 bytes of payload, which exceeds its declared Bag-Size of 20.00 Bytes.`
+        ]);
+    });
+});
+
+test('validate() reports parts without a Bag-Size', () => {
+    let paths = [bagPath("valid_bag")];
+    let multipart = new MultipartValidator(paths, new BagItProfile());
+    return multipart.validate().then(function() {
+        expect(multipart.errors).toEqual([
+            `Part ${paths[0]} does not declare a valid Bag-Size.`
+        ]);
+    });
+});
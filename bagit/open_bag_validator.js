@@ -0,0 +1,119 @@
+const { ValidationError } = require('./validation_error');
+const { Validator } = require('./validator');
+
+/**
+ * OpenBagValidator validates a bag that is still being written, so the
+ * user can see whether the bag is valid so far. It treats required
+ * manifests and tag manifests that don't exist yet as pending rather
+ * than as errors, and it doesn't check serialization, since a bag being
+ * built is always a directory. Everything else is validated as usual,
+ * including tag values, file presence, and any manifests that have
+ * already been written.
+ *
+ * A bag with no errors is "draft valid". It may still be invalid once
+ * its manifests are written. When the bag is finished, validate it
+ * with a regular {@link Validator}.
+ *
+ * @example
+ *
+ * let validator = new OpenBagValidator('/path/to/bag', profile);
+ * validator.on('end', function() {
+ *     console.log(validator.status());   // 'draftValid'
+ *     console.log(validator.pending);    // ['Bag is missing required manifest manifest-sha256.txt']
+ * });
+ * validator.validate();
+ *
+ */
+class OpenBagValidator extends Validator {
+
+    /**
+     * Constructs a new OpenBagValidator.
+     *
+     * @param {string} pathToBag is the absolute path the the bag
+     * directory.
+     *
+     * @param {BagItProfile} profile is the BagItProfile that describes
+     * what consititutes a valid bag.
+     *
+     */
+    constructor(pathToBag, profile) {
+        super(pathToBag, profile);
+        this.disableSerializationCheck = true;
+        /**
+         * pending is a list of messages describing things the bag
+         * doesn't have yet, but will need before it's complete. For
+         * example, required manifests that haven't been written.
+         *
+         * @type {string[]}
+         */
+        this.pending = [];
+    }
+
+    /**
+     * status returns 'invalid' if the bag has errors, 'draftValid' if
+     * it has no errors but is missing required manifests, or 'valid'
+     * if it has neither. Call this after validation completes.
+     *
+     * @returns {string}
+     */
+    status() {
+        if (this.errors.length > 0) {
+            return 'invalid';
+        }
+        if (this.pending.length > 0) {
+            return 'draftValid';
+        }
+        return 'valid';
+    }
+
+    /**
+     * _addError records missing required manifests as pending, and
+     * everything else as an error.
+     *
+     * @param {string} check - The name of the check that found the problem.
+     *
+     * @param {string} message - A description of the problem.
+     *
+     * @param {string} [filePath] - The relative path of the file to which
+     * the problem applies, if any.
+     *
+     * @private
+     */
+    _addError(check, message, filePath) {
+        if (check == 'requiredManifests') {
+            this._addPending(check, message, filePath);
+            return;
+        }
+        super._addError(check, message, filePath);
+    }
+
+    /**
+     * _addPending records something the bag will need before it's
+     * complete. The message goes into this.pending, and a
+     * {@link ValidationError} with severity 'pending' goes into
+     * this.results.
+     *
+     * @param {string} check - The name of the check.
+     *
+     * @param {string} message - A description of what's missing.
+     *
+     * @param {string} [filePath] - The relative path of the missing
+     * file, if any.
+     *
+     * @private
+     */
+    _addPending(check, message, filePath) {
+        if (this._isSkipped(check)) {
+            return;
+        }
+        this.pending.push(message);
+        this.results.push(new ValidationError({
+            severity: 'pending',
+            check: check,
+            filePath: filePath,
+            message: message
+        }));
+    }
+}
+
+module.exports.OpenBagValidator = OpenBagValidator;
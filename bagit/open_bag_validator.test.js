@@ -0,0 +1,71 @@
+const { BagItProfile } = require('./bagit_profile');
+const { OpenBagValidator } = require('./open_bag_validator');
+const path = require('path');
+const { TestUtil } = require('../core/test_util');
+
+function getOpenBagValidator(bagName, profile) {
+    let bagPath = path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+    return new OpenBagValidator(bagPath, profile || new BagItProfile());
+}
+
+test('Constructor sets expected properties', () => {
+    let validator = getOpenBagValidator("open_bag");
+    expect(validator.pending).toEqual([]);
+    expect(validator.disableSerializationCheck).toBe(true);
+});
+
+test('Bag with no manifests yet is draft valid', done => {
+    let validator = getOpenBagValidator("open_bag");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.pending).toEqual([
+            "Bag is missing required manifest manifest-sha256.txt"
+        ]);
+        expect(validator.results.map(r => r.severity)).toEqual(['pending']);
+        expect(validator.results[0].filePath).toEqual('manifest-sha256.txt');
+        expect(validator.status()).toEqual('draftValid');
+        done();
+    });
+    validator.validate();
+});
+
+test('Open bag with bad tag values is invalid', done => {
+    let profile = new BagItProfile();
+    profile.getTagsFromFile("bag-info.txt", "Contact-Email")[0].required = true;
+    let validator = getOpenBagValidator("open_bag", profile);
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Required tag Contact-Email is missing from bag-info.txt"
+        ]);
+        expect(validator.pending.length).toEqual(1);
+        expect(validator.status()).toEqual('invalid');
+        done();
+    });
+    validator.validate();
+});
+
+test('Open bag ignores serialization requirement', done => {
+    let profile = TestUtil.loadFromProfilesDir("aptrust_2.2.json");
+    let validator = getOpenBagValidator("open_bag", profile);
+    validator.on('end', function() {
+        expect(validator.results.filter(r => r.check == 'serialization')).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Finished bag is valid', done => {
+    let validator = getOpenBagValidator("valid_bag");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.pending).toEqual([]);
+        expect(validator.status()).toEqual('valid');
+        done();
+    });
+    validator.validate();
+});
@@ -0,0 +1,57 @@
+/**
+ * SignatureVerifier is the base class for objects that verify detached
+ * signatures over a bag's manifests. Checksums prove a bag hasn't been
+ * damaged. A signature over a manifest proves who created it, which
+ * checksums alone can't do.
+ *
+ * A detached signature is stored next to the manifest it signs, with
+ * the extension .asc or .sig. For example, tagmanifest-sha256.txt.asc
+ * signs tagmanifest-sha256.txt. When the {@link Validator} has a
+ * signatureVerifier, it passes it the bytes of each signed manifest
+ * along with the bytes of its signature, and reports an error if the
+ * signature does not verify.
+ *
+ * Subclasses must implement verify(). This is where you check the
+ * signature against your own keyring, using GPG, PKCS#7, or whatever
+ * your organization uses.
+ *
+ * @example
+ *
+ * class KeyringVerifier extends SignatureVerifier {
+ *     verify(manifestPath, manifestBytes, signatureBytes) {
+ *         return keyring.verifyDetached(manifestBytes, signatureBytes); // Promise<boolean>
+ *     }
+ * }
+ * validator.signatureVerifier = new KeyringVerifier();
+ *
+ */
+class SignatureVerifier {
+    constructor() {
+
+    }
+
+    /**
+     * verify returns a Promise that resolves to true if signatureBytes
+     * is a valid signature over manifestBytes, or to false if it is
+     * not. The promise should reject with an Error if the signature
+     * could not be checked at all, for example because the signer's
+     * key is not in the keyring.
+     *
+     * Subclasses MUST override this method and must not call super().
+     *
+     * @param {string} manifestPath - The relative path of the signed
+     * manifest within the bag. E.g. 'tagmanifest-sha256.txt'.
+     *
+     * @param {Buffer} manifestBytes - The contents of the manifest.
+     *
+     * @param {Buffer} signatureBytes - The contents of the detached
+     * signature file.
+     *
+     * @returns {Promise<boolean>}
+     */
+    verify(manifestPath, manifestBytes, signatureBytes) {
+        throw new Error('This method must be implemented in the subclass.');
+    }
+}
+
+module.exports.SignatureVerifier = SignatureVerifier;
@@ -0,0 +1,6 @@
+const { SignatureVerifier } = require('./signature_verifier');
+
+test('verify() must be implemented in subclass', () => {
+    let verifier = new SignatureVerifier();
+    expect(() => { verifier.verify('tagmanifest-sha256.txt', Buffer.from(''), Buffer.from('')) }).toThrow('This method must be implemented in the subclass.');
+});
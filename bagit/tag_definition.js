@@ -12,6 +12,46 @@ const tagsSetBySystem = ['Bagging-Date', 'Bagging-Software',
                          'First-Version-Object-ID', 'Bag-Size',
                          'BagIt-Profile-Identifier'];
 
+/**
+ * These are the value formats a TagDefinition can require. The key is
+ * the name of the format, as it appears in {@link TagDefinition#format}.
+ * Each entry has a description for use in error messages and an isValid
+ * function that returns true if a value has the format.
+ *
+ * * email - An email address, such as 'curator@example.edu'. This is a
+ *   reasonable syntax check, not a full implementation of RFC 5322. The
+ *   local part may contain the characters RFC 5322 allows in a dot-atom,
+ *   and the domain must have at least two labels.
+ *
+ * @type {Object<string, object>}
+ */
+const valueFormats = {
+    email: {
+        description: 'email address',
+        isValid: function(value) {
+            let match = value.match(/^([^@\s]+)@([^@\s]+)$/);
+            if (!match || match[1].length > 64 || match[2].length > 255) {
+                return false;
+            }
+            let atom = "[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+";
+            let label = "[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?";
+            let localPart = new RegExp(`^${atom}(?:\\.${atom})*$`);
+            let domain = new RegExp(`^${label}(?:\\.${label})+$`);
+            return localPart.test(match[1]) && domain.test(match[2]);
+        }
+    }
+};
+
+/**
+ * This caches the regular expressions compiled from TagDefinition
+ * patterns, so each pattern is compiled only once. The key is the
+ * pattern, and the value is the compiled RegExp, or null if the pattern
+ * is not a valid regular expression.
+ *
+ * @type {Object<string, RegExp>}
+ */
+const compiledPatterns = {};
+
 /**
  * TagDefinition describes the name of a tag, which tag file it should
  * appear in, what its allowed values are, and more.
@@ -54,6 +94,29 @@ class TagDefinition {
           * @default false
           */
         this.required = opts.required === true ? true : false;
+        /**
+          * Conditions under which this tag is required, even if
+          * required is false. The keys are tag names, and the values
+          * are tag values. The tag is required when every one of those
+          * tags has the specified value in one of the bag's tag files.
+          *
+          * @example
+          * // Rights-Statement is required when Access is Restricted.
+          * tagDef.requiredWhen = { 'Access': 'Restricted' };
+          *
+          * @type {Object<string, string>}
+          * @default {}
+          */
+        this.requiredWhen = opts.requiredWhen || {};
+        /**
+          * True if this tag may appear more than once in its tag file.
+          * This corresponds to the repeatable property of tags in
+          * standard BagIt profiles.
+          *
+          * @type {boolean}
+          * @default true
+          */
+        this.repeatable = opts.repeatable === false ? false : true;
         /**
           * A list of valid values for this tag. If this list
           * is empty, then any values are valid. If it is not
@@ -64,6 +127,50 @@ class TagDefinition {
           * @type {string[]}
           */
         this.values = opts.values || [];
+        /**
+          * True if values of this tag should match the allowed values
+          * without regard to case, so that 'yes' matches 'Yes'. The
+          * BagIt spec calls for exact matches, so this is off by
+          * default.
+          *
+          * @type {boolean}
+          * @default false
+          */
+        this.caseInsensitive = opts.caseInsensitive === true ? true : false;
+        /**
+          * True if this tag's allowed values come from an external
+          * authority, such as a controlled vocabulary service. When
+          * this is true, the {@link Validator} asks its
+          * {@link ValueResolver} for the allowed values, and uses
+          * the static values list only if the resolver can't supply
+          * them.
+          *
+          * @type {boolean}
+          * @default false
+          */
+        this.vocabularyBacked = opts.vocabularyBacked === true ? true : false;
+        /**
+          * The format that values of this tag must have, such as
+          * 'email'. If this is empty, the tag may have values in any
+          * format. See {@link TagDefinition.formats} for a list of
+          * supported formats.
+          *
+          * @type {string}
+          * @default ""
+          */
+        this.format = opts.format || "";
+        /**
+          * A regular expression that values of this tag must match,
+          * such as '\\d+ of \\d+' for Bag-Count. The value must match
+          * the whole pattern, as if it began with ^ and ended with $.
+          * The validator applies the pattern only when the tag has no
+          * list of allowed values. If this is empty, the tag may have
+          * any value.
+          *
+          * @type {string}
+          * @default ""
+          */
+        this.pattern = opts.pattern || "";
         /**
           * The default value for this tag. This is the value
           * that will be assigned to the tag when you create a bag
@@ -164,13 +271,19 @@ class TagDefinition {
             this.errors['tagName'] = "You must specify a tag name.";
         }
         if (!Util.isEmptyStringArray(this.values)) {
-            if (!Util.isEmpty(this.defaultValue) && !Util.listContains(this.values, this.defaultValue)) {
+            if (!Util.isEmpty(this.defaultValue) && !this.isAllowedValue(this.defaultValue)) {
             this.errors['defaultValue'] = "The default value must be one of the allowed values.";
             }
-            if (!Util.isEmpty(this.userValue) && !Util.listContains(this.values, this.userValue)) {
+            if (!Util.isEmpty(this.userValue) && !this.isAllowedValue(this.userValue)) {
                 this.errors['userValue'] = "The value must be one of the allowed values.";
             }
         }
+        if (!Util.isEmpty(this.format) && valueFormats[this.format] === undefined) {
+            this.errors['format'] = Context.y18n.__("Format must be one of: %s.", TagDefinition.formats().join(', '));
+        }
+        if (!Util.isEmpty(this.pattern) && this.patternRegExp() == null) {
+            this.errors['pattern'] = Context.y18n.__("Pattern is not a valid regular expression.");
+        }
         return Object.keys(this.errors).length === 0;
     }
 
@@ -193,12 +306,101 @@ class TagDefinition {
         var value = this.getValue();
         if (this.required && Util.isEmpty(value)) {
             this.errors['userValue'] = Context.y18n.__("This tag requires a value.");
-        } else if (this.values.length > 0 && !Util.listContains(this.values, value)) {
+        } else if (this.values.length > 0 && !this.isAllowedValue(value)) {
             this.errors['userValue'] = Context.y18n.__("The value is not in the list of allowed values.");
+        } else if (!Util.isEmpty(value) && !this.hasValidFormat(value)) {
+            this.errors['userValue'] = Context.y18n.__("The value is not a valid %s.", this.formatDescription());
+        } else if (!Util.isEmpty(value) && this.values.length == 0 && !this.hasValidPattern(value)) {
+            this.errors['userValue'] = Context.y18n.__("The value does not match the required pattern.");
         }
         return Object.keys(this.errors).length === 0;
     }
 
+    /**
+     * isAllowedValue returns true if value is in allowedValues. If
+     * caseInsensitive is true, this ignores case when comparing them.
+     *
+     * @param {string} value - The tag value to check.
+     *
+     * @param {string[]} [allowedValues] - The list of allowed values.
+     * This defaults to the tag's values list. The {@link Validator}
+     * passes a different list for tags whose values come from a
+     * {@link ValueResolver}.
+     *
+     * @returns {boolean}
+     */
+    isAllowedValue(value, allowedValues = this.values) {
+        if (!this.caseInsensitive) {
+            return Util.listContains(allowedValues, value);
+        }
+        let lowerCaseValue = String(value).toLowerCase();
+        return allowedValues.some(allowed => String(allowed).toLowerCase() === lowerCaseValue);
+    }
+
+    /**
+     * hasValidFormat returns true if value has the format this tag
+     * requires. It returns true for any value if the tag has no
+     * format, or if its format is not one of the supported formats.
+     * (Use validate() to catch unsupported formats.)
+     *
+     * @param {string} value - The tag value to check.
+     *
+     * @returns {boolean}
+     */
+    hasValidFormat(value) {
+        let valueFormat = valueFormats[this.format];
+        return valueFormat === undefined || valueFormat.isValid(value);
+    }
+
+    /**
+     * patternRegExp returns the compiled regular expression for this
+     * tag's pattern, anchored so that it must match the whole value.
+     * This returns null if the tag has no pattern, or if the pattern is
+     * not a valid regular expression.
+     *
+     * @returns {RegExp}
+     */
+    patternRegExp() {
+        if (Util.isEmpty(this.pattern)) {
+            return null;
+        }
+        if (compiledPatterns[this.pattern] === undefined) {
+            try {
+                compiledPatterns[this.pattern] = new RegExp(`^(?:${this.pattern})$`);
+            } catch (err) {
+                compiledPatterns[this.pattern] = null;
+            }
+        }
+        return compiledPatterns[this.pattern];
+    }
+
+    /**
+     * hasValidPattern returns true if value matches this tag's pattern.
+     * It returns true for any value if the tag has no pattern, or if
+     * the pattern is not a valid regular expression. (Use validate() to
+     * catch invalid patterns.)
+     *
+     * @param {string} value - The tag value to check.
+     *
+     * @returns {boolean}
+     */
+    hasValidPattern(value) {
+        let re = this.patternRegExp();
+        return re == null || re.test(value);
+    }
+
+    /**
+     * formatDescription returns a description of this tag's required
+     * format, such as 'email address', for use in error messages. This
+     * returns an empty string if the tag has no supported format.
+     *
+     * @returns {string}
+     */
+    formatDescription() {
+        let valueFormat = valueFormats[this.format];
+        return valueFormat === undefined ? '' : valueFormat.description;
+    }
+
     /**
       * Returns true if the system, and not the user, must set this value.
       * The system sets certain values, such as Bagging-Date, internally
@@ -274,6 +476,16 @@ class TagDefinition {
             throw `Invalid format for command-line tag string. '${str}' -> sould be in format 'filename/tagname: value'`
         }
     }
+
+    /**
+     * Returns the names of the value formats a TagDefinition can
+     * require. See {@link TagDefinition#format}.
+     *
+     * @returns {string[]}
+     */
+    static formats() {
+        return Object.keys(valueFormats);
+    }
 }
 
 module.exports.TagDefinition = TagDefinition;
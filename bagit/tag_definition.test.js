@@ -16,6 +16,12 @@ test('Constructor sets initial properties', () => {
     expect(tagDef.isBuiltIn).toEqual(false);
     expect(tagDef.isUserAddedFile).toEqual(false);
     expect(tagDef.isUserAddedTag).toEqual(false);
+    expect(tagDef.vocabularyBacked).toEqual(false);
+    expect(tagDef.format).toEqual('');
+    expect(tagDef.pattern).toEqual('');
+    expect(tagDef.caseInsensitive).toEqual(false);
+    expect(tagDef.requiredWhen).toEqual({});
+    expect(tagDef.repeatable).toEqual(true);
 });
 
 test('validate()', () => {
@@ -51,6 +57,111 @@ test('validate()', () => {
     expect(result).toEqual(true);
 });
 
+test('validate() rejects unknown format', () => {
+    let tagDef = new TagDefinition({
+        tagFile: 'bag-info.txt',
+        tagName: 'Contact-Email',
+        format: 'phone'
+    });
+    expect(tagDef.validate()).toEqual(false);
+    expect(tagDef.errors['format']).toEqual('Format must be one of: email.');
+    tagDef.format = 'email';
+    expect(tagDef.validate()).toEqual(true);
+});
+
+test('hasValidFormat() accepts valid email addresses', () => {
+    let tagDef = new TagDefinition({ format: 'email' });
+    expect(tagDef.hasValidFormat('curator@example.edu')).toBe(true);
+    expect(tagDef.hasValidFormat('first.last+bags@lib.example.ac.uk')).toBe(true);
+    expect(tagDef.hasValidFormat("o'brien@example-university.org")).toBe(true);
+});
+
+test('hasValidFormat() rejects invalid email addresses', () => {
+    let tagDef = new TagDefinition({ format: 'email' });
+    expect(tagDef.hasValidFormat('curator@example')).toBe(false);
+    expect(tagDef.hasValidFormat('curator.example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('curator@@example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('cu rator@example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('.curator@example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('cur..ator@example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('curator@-example.edu')).toBe(false);
+    expect(tagDef.hasValidFormat('curator@example..edu')).toBe(false);
+});
+
+test('hasValidFormat() accepts any value when there is no format', () => {
+    let tagDef = new TagDefinition();
+    expect(tagDef.hasValidFormat('curator@example')).toBe(true);
+});
+
+test('validateForJob() catches values in the wrong format', () => {
+    let tagDef = new TagDefinition({
+        tagFile: 'bag-info.txt',
+        tagName: 'Contact-Email',
+        format: 'email',
+        userValue: 'curator@example'
+    });
+    expect(tagDef.validateForJob()).toBe(false);
+    expect(tagDef.errors['userValue']).toEqual('The value is not a valid email address.');
+    tagDef.userValue = 'curator@example.edu';
+    expect(tagDef.validateForJob()).toBe(true);
+});
+
+test('validate() rejects invalid pattern', () => {
+    let tagDef = new TagDefinition({
+        tagFile: 'bag-info.txt',
+        tagName: 'Bag-Count',
+        pattern: '\\d+ of (\\d+'
+    });
+    expect(tagDef.validate()).toEqual(false);
+    expect(tagDef.errors['pattern']).toEqual('Pattern is not a valid regular expression.');
+    tagDef.pattern = '\\d+ of \\d+';
+    expect(tagDef.validate()).toEqual(true);
+});
+
+test('hasValidPattern() matches the whole value', () => {
+    let tagDef = new TagDefinition({ pattern: '\\d+ of \\d+' });
+    expect(tagDef.hasValidPattern('1 of 3')).toBe(true);
+    expect(tagDef.hasValidPattern('12 of 300')).toBe(true);
+    expect(tagDef.hasValidPattern('1 of 3 bags')).toBe(false);
+    expect(tagDef.hasValidPattern('bag 1 of 3')).toBe(false);
+    expect(tagDef.hasValidPattern('one of three')).toBe(false);
+});
+
+test('hasValidPattern() accepts any value when there is no pattern', () => {
+    let tagDef = new TagDefinition();
+    expect(tagDef.patternRegExp()).toBeNull();
+    expect(tagDef.hasValidPattern('anything')).toBe(true);
+});
+
+test('validateForJob() catches values that do not match the pattern', () => {
+    let tagDef = new TagDefinition({
+        tagFile: 'bag-info.txt',
+        tagName: 'Bag-Count',
+        pattern: '\\d+ of \\d+',
+        userValue: 'first of three'
+    });
+    expect(tagDef.validateForJob()).toBe(false);
+    expect(tagDef.errors['userValue']).toEqual('The value does not match the required pattern.');
+    tagDef.userValue = '1 of 3';
+    expect(tagDef.validateForJob()).toBe(true);
+});
+
+test('isAllowedValue() is case-sensitive by default', () => {
+    let tagDef = new TagDefinition({ values: ['Consortia', 'Institution', 'Restricted'] });
+    expect(tagDef.isAllowedValue('Consortia')).toBe(true);
+    expect(tagDef.isAllowedValue('consortia')).toBe(false);
+    expect(tagDef.isAllowedValue('INSTITUTION', ['institution'])).toBe(false);
+});
+
+test('isAllowedValue() ignores case when caseInsensitive is true', () => {
+    let tagDef = new TagDefinition({ values: ['Consortia', 'Institution', 'Restricted'], caseInsensitive: true });
+    expect(tagDef.isAllowedValue('Consortia')).toBe(true);
+    expect(tagDef.isAllowedValue('consortia')).toBe(true);
+    expect(tagDef.isAllowedValue('RESTRICTED')).toBe(true);
+    expect(tagDef.isAllowedValue('Public')).toBe(false);
+    expect(tagDef.isAllowedValue('INSTITUTION', ['institution'])).toBe(true);
+});
+
 test('validateForJob() permits legal empty tag value', () => {
     let tagDef = new TagDefinition({
         tagFile: 'bag-info.txt',
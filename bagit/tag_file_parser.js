@@ -2,6 +2,7 @@ const { Context } = require('../core/context');
 const { KeyValueCollection } = require('./key_value_collection');
 const { PassThrough } = require('stream');
 
+const byteOrderMark = /^\uFEFF/;
 const leadingSpaces = /^\s+/;
 const newline = "\n";
 const tagStart = /^\w+/;
@@ -13,9 +14,9 @@ const tagStart = /^\w+/;
  * memory before parsing tags and values. That is, it accumulates data
  * in the stream.data event and parses it in the stream.end event.
  *
- * This class has no methods. It simply responds to events on the stream
- * you pipe into it. After parsing the stream, it stores the data it
- * has parsed in bagItFile.keyValueCollection.
+ * This class simply responds to events on the stream you pipe into it.
+ * After parsing the stream, it stores the data it has parsed in
+ * bagItFile.keyValueCollection.
  *
  * You can attach your own callback to the TagFileParser.stream end
  * event, if you want to do something with the BagItFile or (more likely)
@@ -25,8 +26,12 @@ const tagStart = /^\w+/;
  * does not already have a keyValueCollection, the parser will create
  * one.
  *
+ * @param {string} [delimiter=':'] - The character that separates tag
+ * names from values. The BagIt spec says this is a colon, but some
+ * bags use other delimiters, such as '='.
+ *
  * For more on the BagIt spec, see
- * {@link https://tools.ietf.org/html/draft-kunze-bagit-17|BagIt Spec}
+ * {@link https://tools.ietf.org/html/rfc8493|BagIt Spec}
  *
  * For info about how to read the parsed data from the file, see {@link KeyValueCollection}
  *
@@ -52,7 +57,7 @@ const tagStart = /^\w+/;
  *
  */
 class TagFileParser {
-    constructor(bagItFile) {
+    constructor(bagItFile, delimiter = ':') {
         /**
           * bagItFile is the file that will be parsed.
           * When parsing is complete, bagItFile.keyValueCollection
@@ -61,6 +66,14 @@ class TagFileParser {
           * @type {BagItFile}
           */
         this.bagItFile = bagItFile;
+        /**
+          * delimiter is the character that separates tag names
+          * from values.
+          *
+          * @type {string}
+          * @default ':'
+          */
+        this.delimiter = delimiter;
         /**
           * stream is a PassThrough stream that allows
           * for data to be piped from a ReadStream into
@@ -82,6 +95,16 @@ class TagFileParser {
           */
         this.content = '';
 
+        /**
+          * errors is a list of problems the parser found in the tag
+          * file. Each is an object with a line property (the line
+          * number, starting at 1) and a message property describing
+          * the problem. The parser skips lines that it can't parse.
+          *
+          * @type {Array<object>}
+          */
+        this.errors = [];
+
         var parser = this;
         if (bagItFile.keyValueCollection == null) {
             bagItFile.keyValueCollection = new KeyValueCollection();
@@ -96,42 +119,70 @@ class TagFileParser {
         });
 
         parser.stream.on('end', function() {
-            // Parse the accumulated data into key-value pairs.
-            var tag = '';
-            var value = '';
-            for (var line of parser.content.split(newline)) {
-                var cleanLine = line.trim();
-                if (cleanLine.length == 0) {
-                    continue;
-                }
-                if (tag && line.match(leadingSpaces)) {
-                    // This line is a continuation of a value that
-                    // started on the previous line.
-                    value += ` ${cleanLine}`;
-                    continue;
-                }
-                if (line.match(tagStart) && line.includes(':')) {
-                    // We're on to a new tag, which means we've collected
-                    // the full value of the old tag. Add the old tag to
-                    // the collection.
-                    if (tag) {
-                        parser.bagItFile.keyValueCollection.add(tag, value);
-                    }
-                    // Unfortunately, JavaScript's split isn't as well
-                    // thought out as Golang's split, so we have to do
-                    // this Java style. :(
-                    var index = line.indexOf(":");
-                    tag = line.slice(0, index).trim();
-                    value = line.slice(index + 1).trim();
-                    //Context.logger.debug(`"${tag}" = "${value}"`);
-                }
+            parser.parse(parser.content);
+        });
+    }
+
+    /**
+     * parse parses content into key-value pairs, adding them to
+     * bagItFile.keyValueCollection and recording any lines it can't
+     * parse in errors. The stream's end event calls this on the UTF-8
+     * text piped into the parser. The {@link Validator} also calls it
+     * directly to re-parse tag files in other character encodings,
+     * once it knows the Tag-File-Character-Encoding declared in
+     * bagit.txt.
+     *
+     * @param {string} content - The decoded text of the tag file.
+     *
+     */
+    parse(content) {
+        // Parse the content into key-value pairs.
+        var tag = '';
+        var value = '';
+        // Ignore the byte order mark, if there is one, so it
+        // doesn't become part of the first tag name. The validator
+        // decides whether the BOM is legal.
+        content = content.replace(byteOrderMark, '');
+        var lineNumber = 0;
+        for (var line of content.split(newline)) {
+            lineNumber++;
+            var cleanLine = line.trim();
+            if (cleanLine.length == 0) {
+                continue;
+            }
+            if (tag && line.match(leadingSpaces)) {
+                // This line is a continuation of a value that
+                // started on the previous line.
+                value += ` ${cleanLine}`;
+                continue;
+            }
+            if (line.match(leadingSpaces)) {
+                this.errors.push({ line: lineNumber, message: "continuation line does not follow a tag" });
+                continue;
             }
-            // Add the tag from the last line of the file, if there was one.
+            if (!line.match(tagStart) || !line.includes(this.delimiter)) {
+                this.errors.push({ line: lineNumber, message: `expected a tag name followed by '${this.delimiter}' and a value` });
+                continue;
+            }
+            // We're on to a new tag, which means we've collected
+            // the full value of the old tag. Add the old tag to
+            // the collection.
             if (tag) {
-                parser.bagItFile.keyValueCollection.add(tag, value);
+                this.bagItFile.keyValueCollection.add(tag, value);
             }
-            //Context.logger.debug(`Finished parsing tag file ${parser.bagItFile.absPath}`);
-        });
+            // Unfortunately, JavaScript's split isn't as well
+            // thought out as Golang's split, so we have to do
+            // this Java style. :(
+            var index = line.indexOf(this.delimiter);
+            tag = line.slice(0, index).trim();
+            value = line.slice(index + 1).trim();
+            //Context.logger.debug(`"${tag}" = "${value}"`);
+        }
+        // Add the tag from the last line of the file, if there was one.
+        if (tag) {
+            this.bagItFile.keyValueCollection.add(tag, value);
+        }
+        //Context.logger.debug(`Finished parsing tag file ${this.bagItFile.absPath}`);
     }
 }
 
@@ -48,3 +48,39 @@ test('TagFileParser', done => {
     tagFileParser.stream.on('end', testParseResults);
     stream.pipe(tagFileParser.stream);
 });
+
+test('TagFileParser uses custom delimiter', done => {
+    let bagItFile = { relDestPath: "bag-info.txt", keyValueCollection: null };
+    let tagFileParser = new TagFileParser(bagItFile, '=');
+    expect(tagFileParser.delimiter).toEqual('=');
+    tagFileParser.stream.on('end', function() {
+        expect(bagItFile.keyValueCollection.keys()).toEqual(["Source-Organization", "Contact-Email"]);
+        expect(bagItFile.keyValueCollection.first("Source-Organization")).toEqual("Example University: Special Collections");
+        expect(bagItFile.keyValueCollection.first("Contact-Email")).toEqual("curator@example.edu");
+        done();
+    });
+    tagFileParser.stream.end("Source-Organization = Example University: Special Collections\nContact-Email=curator@example.edu\n");
+});
+
+test('TagFileParser records lines it cannot parse', done => {
+    let bagItFile = { relDestPath: "bag-info.txt", keyValueCollection: null };
+    let tagFileParser = new TagFileParser(bagItFile);
+    tagFileParser.stream.on('end', function() {
+        expect(tagFileParser.errors).toEqual([
+            { line: 1, message: "continuation line does not follow a tag" },
+            { line: 3, message: "expected a tag name followed by ':' and a value" }
+        ]);
+        expect(bagItFile.keyValueCollection.keys()).toEqual(["Source-Organization", "Contact-Name"]);
+        done();
+    });
+    tagFileParser.stream.end("  stray continuation\nSource-Organization: Example University\nBagging-Date 2021-07-13\nContact-Name: Jane Curator\n");
+});
+
+test('TagFileParser parses decoded content', () => {
+    let bagItFile = { relDestPath: "bag-info.txt", keyValueCollection: null };
+    let tagFileParser = new TagFileParser(bagItFile);
+    let latin1 = Buffer.from("Source-Organization: Universit\xe9 Example\n", 'latin1');
+    tagFileParser.parse(new TextDecoder('iso-8859-1').decode(latin1));
+    expect(bagItFile.keyValueCollection.first("Source-Organization")).toEqual("Université Example");
+    expect(tagFileParser.errors).toEqual([]);
+});
@@ -0,0 +1,136 @@
+const crypto = require('crypto');
+const fs = require('fs');
+const path = require('path');
+
+/**
+ * ValidationCache holds the results of earlier validations, so callers
+ * can skip validating a bag that hasn't changed since the last time.
+ * Each cached result is tied to a fingerprint of the bag, which
+ * includes the size and modification time of every file in it. If any
+ * file is added, removed, resized, or touched, the fingerprint changes,
+ * and the cached result no longer applies.
+ *
+ * Results are also tied to the id of the {@link BagItProfile}, since a
+ * bag that's valid under one profile may not be valid under another.
+ *
+ * @example
+ *
+ * let cache = new ValidationCache();
+ * let result = cache.get(pathToBag, profile);
+ * if (result == null) {
+ *     let validator = new Validator(pathToBag, profile);
+ *     validator.on('end', function() {
+ *         cache.set(validator);
+ *     });
+ *     validator.validate();
+ * }
+ *
+ */
+class ValidationCache {
+    constructor() {
+        /**
+         * entries holds the cached results. The key is the path to the
+         * bag. Each value has the bag's fingerprint, the profile id, and
+         * the result from {@link Validator#protoResult}.
+         *
+         * @type {Object<string, object>}
+         */
+        this.entries = {};
+    }
+
+    /**
+     * Returns the cached result for the bag at pathToBag, or null if
+     * there isn't one. This also returns null if the bag has changed,
+     * or was validated against a different profile, since the result
+     * was cached.
+     *
+     * @param {string} pathToBag - The path to the bag.
+     *
+     * @param {BagItProfile} profile - The profile the bag is being
+     * validated against.
+     *
+     * @returns {object|null}
+     */
+    get(pathToBag, profile) {
+        let entry = this.entries[pathToBag];
+        if (!entry || entry.profileId != profile.id) {
+            return null;
+        }
+        if (!fs.existsSync(pathToBag) || entry.fingerprint != ValidationCache.bagFingerprint(pathToBag)) {
+            delete this.entries[pathToBag];
+            return null;
+        }
+        return entry.result;
+    }
+
+    /**
+     * Caches the result of a validator that has finished validating.
+     *
+     * @param {Validator} validator - The validator.
+     */
+    set(validator) {
+        this.entries[validator.pathToBag] = {
+            fingerprint: ValidationCache.bagFingerprint(validator.pathToBag),
+            profileId: validator.profile.id,
+            result: validator.protoResult()
+        };
+    }
+
+    /**
+     * Returns a fingerprint of the bag at pathToBag. For a directory,
+     * this is a sha256 digest of the relative path, size, and
+     * modification time of every file and directory inside it. For a
+     * serialized bag, it's a digest of the file's size and modification
+     * time. The fingerprint changes whenever a file is added, removed,
+     * resized, or modified, but doesn't require reading any file's
+     * contents.
+     *
+     * This throws an error if pathToBag does not exist or can't be read.
+     *
+     * @param {string} pathToBag - The path to the bag.
+     *
+     * @returns {string}
+     */
+    static bagFingerprint(pathToBag) {
+        let hash = crypto.createHash('sha256');
+        let stats = fs.statSync(pathToBag);
+        if (!stats.isDirectory()) {
+            hash.update(`${stats.size}\t${stats.mtimeMs}\n`);
+            return hash.digest('hex');
+        }
+        for (let line of ValidationCache._fingerprintLines(pathToBag, '')) {
+            hash.update(line);
+        }
+        return hash.digest('hex');
+    }
+
+    /**
+     * Returns one line for each file and directory beneath dir, sorted
+     * by relative path, with the path, type, size, and modification time.
+     *
+     * @param {string} baseDir - The top-level directory of the bag.
+     *
+     * @param {string} relDir - The directory to list, relative to
+     * baseDir.
+     *
+     * @returns {string[]}
+     *
+     * @private
+     */
+    static _fingerprintLines(baseDir, relDir) {
+        let lines = [];
+        for (let name of fs.readdirSync(path.join(baseDir, relDir)).sort()) {
+            let relPath = relDir ? `${relDir}/${name}` : name;
+            let stats = fs.statSync(path.join(baseDir, relPath));
+            if (stats.isDirectory()) {
+                lines.push(`${relPath}/\td\n`);
+                lines = lines.concat(ValidationCache._fingerprintLines(baseDir, relPath));
+            } else {
+                lines.push(`${relPath}\t${stats.size}\t${stats.mtimeMs}\n`);
+            }
+        }
+        return lines;
+    }
+}
+
+module.exports.ValidationCache = ValidationCache;
@@ -0,0 +1,92 @@
+const { BagItProfile } = require('./bagit_profile');
+const fs = require('fs');
+const path = require('path');
+const { Util } = require('../core/util');
+const { ValidationCache } = require('./validation_cache');
+const { Validator } = require('./validator');
+
+const VALID_BAG = path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag");
+
+// Copies valid_bag into a temp directory that the tests can modify.
+function copyOfValidBag() {
+    let dir = Util.tmpFilePath();
+    fs.mkdirSync(path.join(dir, "data", "docs"), { recursive: true });
+    for (let relPath of ["bagit.txt", "bag-info.txt", "manifest-sha256.txt", "data/first.txt", "data/docs/second.txt"]) {
+        fs.copyFileSync(path.join(VALID_BAG, relPath), path.join(dir, relPath));
+    }
+    return dir;
+}
+
+function validate(pathToBag, profile) {
+    return new Promise(function(resolve) {
+        let validator = new Validator(pathToBag, profile);
+        validator.on('end', function() {
+            resolve(validator);
+        });
+        validator.validate();
+    });
+}
+
+test('bagFingerprint() is stable when nothing changes', () => {
+    let dir = copyOfValidBag();
+    try {
+        let fingerprint = ValidationCache.bagFingerprint(dir);
+        expect(fingerprint).toMatch(/^[0-9a-f]{64}$/);
+        expect(ValidationCache.bagFingerprint(dir)).toEqual(fingerprint);
+    } finally {
+        Util.deleteRecursive(dir);
+    }
+});
+
+test('bagFingerprint() changes when file size changes', () => {
+    let dir = copyOfValidBag();
+    try {
+        let filePath = path.join(dir, "data", "first.txt");
+        let stats = fs.statSync(filePath);
+        let fingerprint = ValidationCache.bagFingerprint(dir);
+        fs.appendFileSync(filePath, "more");
+        // Restore the mtime, so only the size differs.
+        fs.utimesSync(filePath, stats.atime, stats.mtime);
+        expect(ValidationCache.bagFingerprint(dir)).not.toEqual(fingerprint);
+    } finally {
+        Util.deleteRecursive(dir);
+    }
+});
+
+test('bagFingerprint() changes when file mtime changes', () => {
+    let dir = copyOfValidBag();
+    try {
+        let filePath = path.join(dir, "data", "docs", "second.txt");
+        let fingerprint = ValidationCache.bagFingerprint(dir);
+        fs.utimesSync(filePath, new Date(2020, 0, 1), new Date(2020, 0, 1));
+        expect(ValidationCache.bagFingerprint(dir)).not.toEqual(fingerprint);
+    } finally {
+        Util.deleteRecursive(dir);
+    }
+});
+
+test('bagFingerprint() throws if bag does not exist', () => {
+    expect(() => { ValidationCache.bagFingerprint("/no/such/bag") }).toThrow();
+});
+
+test('get() returns cached result until bag changes', () => {
+    let dir = copyOfValidBag();
+    let profile = new BagItProfile();
+    let cache = new ValidationCache();
+    expect(cache.get(dir, profile)).toBeNull();
+    return validate(dir, profile).then(function(validator) {
+        cache.set(validator);
+        let result = cache.get(dir, profile);
+        expect(result.valid).toBe(true);
+        expect(result.bagName).toEqual(path.basename(dir));
+
+        // Different profile, so cached result doesn't apply.
+        expect(cache.get(dir, new BagItProfile())).toBeNull();
+
+        fs.appendFileSync(path.join(dir, "data", "first.txt"), "changed");
+        expect(cache.get(dir, profile)).toBeNull();
+        expect(cache.entries).toEqual({});
+    }).finally(function() {
+        Util.deleteRecursive(dir);
+    });
+});
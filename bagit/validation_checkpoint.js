@@ -0,0 +1,147 @@
+const fs = require('fs');
+
+/**
+ * ValidationCheckpoint records which files in a bag the {@link Validator}
+ * has already checksummed, so an interrupted validation of a very large
+ * bag can pick up where it left off instead of starting over.
+ *
+ * Each entry holds a file's size, modification time, and computed
+ * checksums. When the validator resumes, it trusts the recorded
+ * checksums of any file whose size and modification time haven't
+ * changed, and only hashes the rest.
+ *
+ * If pathToCheckpointFile is set, the checkpoint is written to that file
+ * as JSON each time a file is recorded. Use {@link
+ * ValidationCheckpoint.load} to read it back after a crash or restart.
+ *
+ * @example
+ *
+ * let checkpoint = new ValidationCheckpoint(pathToBag, '/tmp/bag.checkpoint');
+ * let validator = new Validator(pathToBag, profile);
+ * validator.checkpoint = checkpoint;
+ * validator.validate();
+ *
+ * // Later, after the process was interrupted...
+ * let checkpoint = ValidationCheckpoint.load('/tmp/bag.checkpoint');
+ * let validator = new Validator(pathToBag, profile);
+ * validator.resumeValidate(checkpoint);
+ *
+ */
+class ValidationCheckpoint {
+
+    /**
+     * Constructs a new ValidationCheckpoint.
+     *
+     * @param {string} pathToBag - The path to the bag being validated.
+     *
+     * @param {string} [pathToCheckpointFile] - The path to the file in
+     * which to save the checkpoint. If this is omitted, the checkpoint
+     * is kept in memory only.
+     *
+     */
+    constructor(pathToBag, pathToCheckpointFile) {
+        /**
+         * pathToBag is the path to the bag this checkpoint describes.
+         *
+         * @type {string}
+         */
+        this.pathToBag = pathToBag;
+        /**
+         * pathToCheckpointFile is where the checkpoint is saved. If
+         * this is empty, the checkpoint is not saved.
+         *
+         * @type {string}
+         */
+        this.pathToCheckpointFile = pathToCheckpointFile || '';
+        /**
+         * files holds one entry for each file that has been verified.
+         * The key is the file's relative path within the bag. The value
+         * has the file's size, mtime (in milliseconds), and checksums.
+         *
+         * @type {Object<string, object>}
+         */
+        this.files = {};
+    }
+
+    /**
+     * Records the checksums of a file whose digests have all been
+     * computed, and saves the checkpoint if it has a
+     * pathToCheckpointFile.
+     *
+     * @param {BagItFile} bagItFile - The file that was verified.
+     */
+    record(bagItFile) {
+        this.files[bagItFile.relDestPath] = {
+            size: bagItFile.size,
+            mtime: ValidationCheckpoint._mtimeMs(bagItFile),
+            checksums: Object.assign({}, bagItFile.checksums)
+        };
+        if (this.pathToCheckpointFile) {
+            this.save();
+        }
+    }
+
+    /**
+     * Returns the recorded checksums for bagItFile, or null if the file
+     * isn't in the checkpoint, or if its size or modification time has
+     * changed since it was recorded.
+     *
+     * @param {BagItFile} bagItFile - The file to look up.
+     *
+     * @returns {Object<string, string>|null}
+     */
+    checksumsFor(bagItFile) {
+        let entry = this.files[bagItFile.relDestPath];
+        if (!entry || entry.size != bagItFile.size || entry.mtime != ValidationCheckpoint._mtimeMs(bagItFile)) {
+            return null;
+        }
+        return entry.checksums;
+    }
+
+    /**
+     * Writes the checkpoint to pathToCheckpointFile as JSON.
+     *
+     */
+    save() {
+        let data = {
+            pathToBag: this.pathToBag,
+            files: this.files
+        };
+        fs.writeFileSync(this.pathToCheckpointFile, JSON.stringify(data));
+    }
+
+    /**
+     * Loads a checkpoint that was saved to pathToCheckpointFile. This
+     * throws an error if the file does not exist or is not valid JSON.
+     *
+     * @param {string} pathToCheckpointFile - The path to the saved
+     * checkpoint.
+     *
+     * @returns {ValidationCheckpoint}
+     */
+    static load(pathToCheckpointFile) {
+        let data = JSON.parse(fs.readFileSync(pathToCheckpointFile, 'utf8'));
+        let checkpoint = new ValidationCheckpoint(data.pathToBag, pathToCheckpointFile);
+        checkpoint.files = data.files || {};
+        return checkpoint;
+    }
+
+    /**
+     * Returns the file's modification time in milliseconds, or null if
+     * it doesn't have one.
+     *
+     * @param {BagItFile} bagItFile - The file.
+     *
+     * @returns {number|null}
+     *
+     * @private
+     */
+    static _mtimeMs(bagItFile) {
+        if (!bagItFile.mtime) {
+            return null;
+        }
+        return new Date(bagItFile.mtime).getTime();
+    }
+}
+
+module.exports.ValidationCheckpoint = ValidationCheckpoint;
@@ -0,0 +1,113 @@
+const { BagItFile } = require('./bagit_file');
+const { BagItProfile } = require('./bagit_profile');
+const fs = require('fs');
+const path = require('path');
+const { Util } = require('../core/util');
+const { ValidationCheckpoint } = require('./validation_checkpoint');
+const { Validator } = require('./validator');
+
+const VALID_BAG = path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag");
+
+function validate(validator, checkpoint) {
+    return new Promise(function(resolve) {
+        validator.on('end', function() {
+            resolve(validator);
+        });
+        if (checkpoint) {
+            validator.resumeValidate(checkpoint);
+        } else {
+            validator.validate();
+        }
+    });
+}
+
+// Counts the files the validator hashes, by relative path.
+function countHashes() {
+    let hashed = [];
+    let getCryptoHash = BagItFile.prototype.getCryptoHash;
+    BagItFile.prototype.getCryptoHash = function(algorithm, done) {
+        hashed.push(this.relDestPath);
+        return getCryptoHash.call(this, algorithm, done);
+    }
+    hashed.restore = function() {
+        BagItFile.prototype.getCryptoHash = getCryptoHash;
+    }
+    return hashed;
+}
+
+test('Validator records each verified file in its checkpoint', () => {
+    let checkpoint = new ValidationCheckpoint(VALID_BAG);
+    let validator = new Validator(VALID_BAG, new BagItProfile());
+    validator.checkpoint = checkpoint;
+    return validate(validator).then(function() {
+        expect(validator.errors).toEqual([]);
+        expect(Object.keys(checkpoint.files).sort()).toEqual([
+            "bag-info.txt",
+            "bagit.txt",
+            "data/docs/second.txt",
+            "data/first.txt",
+            "manifest-sha256.txt"
+        ]);
+        let entry = checkpoint.files["data/first.txt"];
+        expect(entry.size).toEqual(validator.files["data/first.txt"].size);
+        expect(entry.checksums.sha256).toEqual(validator.files["data/first.txt"].checksums.sha256);
+    });
+});
+
+test('resumeValidate() does not re-hash files in the checkpoint', () => {
+    let checkpointFile = Util.tmpFilePath();
+    let checkpoint = new ValidationCheckpoint(VALID_BAG, checkpointFile);
+    let first = new Validator(VALID_BAG, new BagItProfile());
+    first.checkpoint = checkpoint;
+    let hashed = null;
+    return validate(first).then(function() {
+        // Simulate an interruption before the last file was verified.
+        let saved = ValidationCheckpoint.load(checkpointFile);
+        delete saved.files["data/docs/second.txt"];
+        saved.save();
+        hashed = countHashes();
+        let resumed = new Validator(VALID_BAG, new BagItProfile());
+        return validate(resumed, ValidationCheckpoint.load(checkpointFile));
+    }).then(function(resumed) {
+        expect(hashed).toEqual(["data/docs/second.txt"]);
+        expect(resumed.errors).toEqual([]);
+        expect(resumed.files["data/first.txt"].checksums.sha256).toEqual(first.files["data/first.txt"].checksums.sha256);
+        expect(Object.keys(ValidationCheckpoint.load(checkpointFile).files).length).toEqual(5);
+    }).finally(function() {
+        if (hashed) {
+            hashed.restore();
+        }
+        fs.unlinkSync(checkpointFile);
+    });
+});
+
+test('resumeValidate() re-hashes files that changed since the checkpoint', () => {
+    let checkpoint = new ValidationCheckpoint(VALID_BAG);
+    let first = new Validator(VALID_BAG, new BagItProfile());
+    first.checkpoint = checkpoint;
+    let hashed = null;
+    return validate(first).then(function() {
+        checkpoint.files["data/first.txt"].mtime -= 1000;
+        checkpoint.files["data/first.txt"].checksums.sha256 = "0000";
+        hashed = countHashes();
+        return validate(new Validator(VALID_BAG, new BagItProfile()), checkpoint);
+    }).then(function(resumed) {
+        expect(hashed).toEqual(["data/first.txt"]);
+        expect(resumed.errors).toEqual([]);
+    }).finally(function() {
+        if (hashed) {
+            hashed.restore();
+        }
+    });
+});
+
+test('resumeValidate() rejects a checkpoint for a different bag', () => {
+    let checkpoint = new ValidationCheckpoint("/path/to/other/bag");
+    let validator = new Validator(VALID_BAG, new BagItProfile());
+    validator.on('error', function() {});
+    return validate(validator, checkpoint).then(function() {
+        expect(validator.errors).toEqual([
+            `Checkpoint is for /path/to/other/bag, not ${VALID_BAG}.`
+        ]);
+    });
+});
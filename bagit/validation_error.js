@@ -0,0 +1,134 @@
+/**
+ * These are the column names for the CSV output of
+ * {@link ValidationError#toCsvRow}.
+ *
+ * @type {string[]}
+ */
+const csvHeaders = ['severity', 'check', 'filePath', 'message'];
+
+/**
+ * ValidationError describes a single problem the {@link Validator} found
+ * in a bag. The validator still records the message of each
+ * ValidationError in its errors or warnings list, so callers who only
+ * want strings can keep using those.
+ *
+ * @param {object} opts - Values to copy into ValidationError properties.
+ * The properties of this object match the properties of the
+ * ValidationError class, which are described below.
+ *
+ */
+class ValidationError {
+    constructor(opts = {}) {
+        /**
+          * severity is 'error', 'warning', 'skipped', or 'pending'.
+          * Errors make a bag invalid. Warnings describe things a curator
+          * may want to review, but they do not make a bag invalid.
+          * Skipped means the validator did not apply the check at all,
+          * because it was in {@link Validator#skipChecks}. Pending means
+          * an {@link OpenBagValidator} found something the bag will need
+          * before it's complete, such as a manifest.
+          *
+          * @type {string}
+          * @default 'error'
+          */
+        this.severity = opts.severity || 'error';
+        /**
+          * check is the name of the validation check that found this
+          * problem. For example, 'manifestEntries' or 'tags'.
+          *
+          * @type {string}
+          */
+        this.check = opts.check || '';
+        /**
+          * filePath is the relative path within the bag of the file
+          * to which this problem applies. For example,
+          * 'data/photos/img.jpg' or 'bag-info.txt'. This will be empty
+          * for problems that don't apply to a specific file.
+          *
+          * @type {string}
+          */
+        this.filePath = opts.filePath || '';
+        /**
+          * message is a human-readable description of the problem.
+          *
+          * @type {string}
+          */
+        this.message = opts.message || '';
+        /**
+          * expected is the value the validator expected to find, for
+          * problems that come down to a mismatch, such as the digest in
+          * a manifest or the byte count in Payload-Oxum. This is empty
+          * for other problems.
+          *
+          * @type {string}
+          */
+        this.expected = opts.expected || '';
+        /**
+          * actual is the value the validator actually found, for
+          * problems that come down to a mismatch, such as a file's
+          * calculated digest. This is empty for other problems.
+          *
+          * @type {string}
+          */
+        this.actual = opts.actual || '';
+        /**
+          * actualSize is the size, in bytes, of the file whose digest
+          * did not match its manifest, for checksum mismatches. A size
+          * of zero, or one far from what the depositor expected, usually
+          * means the file was truncated rather than altered. This is
+          * null for other problems.
+          *
+          * @type {number}
+          * @default null
+          */
+        this.actualSize = opts.actualSize === undefined ? null : opts.actualSize;
+    }
+
+    /**
+     * Returns the message describing this problem.
+     *
+     * @returns {string}
+     */
+    toString() {
+        return this.message;
+    }
+
+    /**
+     * toCsvRow returns this ValidationError as a single line of CSV,
+     * with no trailing newline. The columns are in the same order as
+     * {@link ValidationError.csvHeader}.
+     *
+     * @returns {string}
+     */
+    toCsvRow() {
+        return csvHeaders.map(h => ValidationError.csvField(this[h])).join(',');
+    }
+
+    /**
+     * Returns the CSV header line, with no trailing newline.
+     *
+     * @returns {string}
+     */
+    static csvHeader() {
+        return csvHeaders.join(',');
+    }
+
+    /**
+     * csvField returns value formatted as a CSV field. Values containing
+     * commas, quotes, or line breaks are wrapped in double quotes, and
+     * embedded double quotes are doubled, as described in RFC 4180.
+     *
+     * @param {string} value - The value to format.
+     *
+     * @returns {string}
+     */
+    static csvField(value) {
+        var str = (value === null || value === undefined) ? '' : String(value);
+        if (/[",\r\n]/.test(str)) {
+            str = '"' + str.replace(/"/g, '""') + '"';
+        }
+        return str;
+    }
+}
+
+module.exports.ValidationError = ValidationError;
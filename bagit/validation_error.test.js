@@ -0,0 +1,52 @@
+const { ValidationError } = require('./validation_error');
+
+test('Constructor sets initial properties', () => {
+    let err = new ValidationError();
+    expect(err.severity).toEqual('error');
+    expect(err.check).toEqual('');
+    expect(err.filePath).toEqual('');
+    expect(err.message).toEqual('');
+    expect(err.expected).toEqual('');
+    expect(err.actual).toEqual('');
+    expect(err.actualSize).toBeNull();
+
+    err = new ValidationError({
+        severity: 'warning',
+        check: 'tags',
+        filePath: 'bag-info.txt',
+        message: 'Tag file bag-info.txt has no data',
+        expected: 'abc',
+        actual: 'def',
+        actualSize: 0
+    });
+    expect(err.severity).toEqual('warning');
+    expect(err.check).toEqual('tags');
+    expect(err.filePath).toEqual('bag-info.txt');
+    expect(err.message).toEqual('Tag file bag-info.txt has no data');
+    expect(err.toString()).toEqual('Tag file bag-info.txt has no data');
+    expect(err.expected).toEqual('abc');
+    expect(err.actual).toEqual('def');
+    expect(err.actualSize).toEqual(0);
+});
+
+test('csvHeader()', () => {
+    expect(ValidationError.csvHeader()).toEqual('severity,check,filePath,message');
+});
+
+test('csvField()', () => {
+    expect(ValidationError.csvField('plain')).toEqual('plain');
+    expect(ValidationError.csvField('one, two')).toEqual('"one, two"');
+    expect(ValidationError.csvField('say "hi"')).toEqual('"say ""hi"""');
+    expect(ValidationError.csvField('line\nbreak')).toEqual('"line\nbreak"');
+    expect(ValidationError.csvField(null)).toEqual('');
+    expect(ValidationError.csvField(undefined)).toEqual('');
+});
+
+test('toCsvRow()', () => {
+    let err = new ValidationError({
+        check: 'manifestEntries',
+        filePath: 'data/a,b.txt',
+        message: "File 'data/a,b.txt' in manifest-md5.txt is missing from bag."
+    });
+    expect(err.toCsvRow()).toEqual(`error,manifestEntries,"data/a,b.txt","File 'data/a,b.txt' in manifest-md5.txt is missing from bag."`);
+});
@@ -3,16 +3,247 @@ const { Constants } = require('../core/constants');
 const { Context } = require('../core/context');
 const crypto = require('crypto');
 const EventEmitter = require('events');
+const { FileStat } = require('../util/file/filestat');
 const fs = require('fs');
+const { KeyValueCollection } = require('./key_value_collection');
 const { ManifestParser } = require('./manifest_parser');
 const minimatch = require("minimatch")
 const os = require('os');
 const path = require('path');
 const { PluginManager } = require('../plugins/plugin_manager');
 const stream = require('stream');
+const dateFormat = require('dateformat');
+const { TagDefinition } = require('./tag_definition');
 const { TagFileParser } = require('./tag_file_parser');
 const { TaskDescription } = require('./task_description');
 const { Util } = require('../core/util');
+const { ValidationError } = require('./validation_error');
+
+/**
+ * These names are reserved on Windows, with or without an extension.
+ * E.g. 'CON' and 'con.txt' are both illegal file names on Windows.
+ *
+ * @type {RegExp}
+ */
+const windowsReservedName = /^(CON|PRN|AUX|NUL|COM[1-9]|LPT[1-9])(\..*)?$/i;
+
+/**
+ * These characters are not allowed in Windows file names.
+ *
+ * @type {RegExp}
+ */
+const windowsIllegalChars = /[<>:"|?*\\\x00-\x1F]/;
+
+/**
+ * This matches the names of the change manifests in versioned bags.
+ * Version N of the bag adds changes-N.txt to describe how its payload
+ * differs from version N-1.
+ *
+ * @type {RegExp}
+ */
+const changeManifestName = /^changes-(\d+)\.txt$/;
+
+/**
+ * This is the name of the file in which versioned bags list payload
+ * files that were deleted in earlier versions.
+ *
+ * @type {string}
+ */
+const tombstoneFile = 'deleted.txt';
+
+/**
+ * This is the name of the file in which content-addressed bags map
+ * logical payload paths to the blobs that hold their contents.
+ *
+ * @type {string}
+ */
+const blobMapFile = 'blob-map.txt';
+
+/**
+ * This is the directory in which content-addressed bags store their
+ * payload blobs. Each blob's name is its content address.
+ *
+ * @type {string}
+ */
+const blobDirectory = 'blobs/';
+
+/**
+ * These are the magic numbers the validator uses to identify the actual
+ * format of a serialized bag. Each entry has the format's name, the
+ * offset of the magic number within the file, and the magic number
+ * itself.
+ *
+ * @type {Array<object>}
+ */
+const magicNumbers = [
+    { format: 'zip', offset: 0, bytes: Buffer.from('504b0304', 'hex') },
+    { format: 'zip', offset: 0, bytes: Buffer.from('504b0506', 'hex') },
+    { format: 'gzip', offset: 0, bytes: Buffer.from('1f8b', 'hex') },
+    { format: 'bzip2', offset: 0, bytes: Buffer.from('BZh') },
+    { format: '7z', offset: 0, bytes: Buffer.from('377abcaf271c', 'hex') },
+    { format: 'tar', offset: 257, bytes: Buffer.from('ustar') }
+];
+
+/**
+ * Tarred bags have these extensions. The last two are gzipped tar files.
+ *
+ * @type {RegExp}
+ */
+const tarExtension = /\.(tar|tar\.gz|tgz)$/;
+
+/**
+ * Serialized bags that the validator can read without extracting them
+ * have these extensions. Entries in these files are prefixed with the
+ * bag name.
+ *
+ * @type {RegExp}
+ */
+const archiveExtension = /\.(tar|tar\.gz|tgz|zip)$/;
+
+/**
+ * When pathToBag is this, the validator reads a tarred bag from STDIN.
+ *
+ * @type {string}
+ */
+const stdinPath = '-';
+
+/**
+ * Compressed tarred bags have these extensions.
+ *
+ * @type {RegExp}
+ */
+const compressedTarExtension = /\.(tar\.gz|tgz)$/;
+
+/**
+ * This maps the extensions of serialized bags to the formats in
+ * magicNumbers that files with those extensions should contain.
+ *
+ * @type {Object<string, string>}
+ */
+const formatForExtension = {
+    '.tar': 'tar',
+    '.zip': 'zip',
+    '.gz': 'gzip',
+    '.tgz': 'gzip',
+    '.bz2': 'bzip2',
+    '.7z': '7z'
+};
+
+/**
+ * These are the signatures of the payload file types that the validator
+ * can recognize when checking allowedPayloadMimeTypes. The validator
+ * looks for these bytes at the start of each file. Files that match
+ * none of these are text/plain if their first bytes contain no binary
+ * control characters, or application/octet-stream otherwise.
+ *
+ * @type {Array<object>}
+ */
+const mimeSignatures = [
+    { mimeType: 'application/pdf', bytes: Buffer.from('%PDF-') },
+    { mimeType: 'image/tiff', bytes: Buffer.from('49492a00', 'hex') },
+    { mimeType: 'image/tiff', bytes: Buffer.from('4d4d002a', 'hex') },
+    { mimeType: 'image/png', bytes: Buffer.from('89504e470d0a1a0a', 'hex') },
+    { mimeType: 'image/jpeg', bytes: Buffer.from('ffd8ff', 'hex') },
+    { mimeType: 'image/gif', bytes: Buffer.from('GIF87a') },
+    { mimeType: 'image/gif', bytes: Buffer.from('GIF89a') },
+    { mimeType: 'application/zip', bytes: Buffer.from('504b0304', 'hex') },
+    { mimeType: 'application/x-gzip', bytes: Buffer.from('1f8b08', 'hex') },
+    { mimeType: 'application/xml', bytes: Buffer.from('<?xml') }
+];
+
+/**
+ * This is the number of bytes at the start of each payload file that
+ * the validator uses to detect the file's MIME type.
+ *
+ * @type {number}
+ */
+const mimeSniffLength = 512;
+
+/**
+ * SpecRules describes the spec-level rules that differ from one version
+ * of the BagIt specification to the next. These are distinct from the
+ * profile-level rules described by a {@link BagItProfile}. The key is
+ * the BagIt version, and the value describes which rules apply.
+ *
+ * * everyFileInEveryManifest - RFC 8493 (BagIt 1.0) section 2.1.3 says
+ *   every payload file must be listed in every payload manifest. Earlier
+ *   drafts required only that each file appear in a payload manifest,
+ *   so payload manifests may also list different numbers of files.
+ * * bomForbiddenInBagItTxt - RFC 8493 section 2.1.1 says bagit.txt must
+ *   not begin with a byte order mark.
+ * * tagManifestsMatchPayloadAlgorithms - RFC 8493 section 2.2.1 says tag
+ *   manifests should use the same algorithms as the payload manifests.
+ *   Since this is a SHOULD, the validator reports a warning.
+ *
+ * Version 0.97 is draft-kunze-bagit-14. Version 1.0 is RFC 8493, at
+ * {@link https://tools.ietf.org/html/rfc8493}.
+ *
+ * @type {Object<string, Object>}
+ */
+const SpecRules = {
+    "0.97": {
+        everyFileInEveryManifest: false,
+        bomForbiddenInBagItTxt: false,
+        tagManifestsMatchPayloadAlgorithms: false
+    },
+    "1.0": {
+        everyFileInEveryManifest: true,
+        bomForbiddenInBagItTxt: true,
+        tagManifestsMatchPayloadAlgorithms: true
+    }
+};
+
+/**
+ * ResultCodes maps the name of each validation check to the exit code
+ * that {@link Validator#resultCode} returns when that check fails.
+ * Checks not listed here describe the structure of the bag.
+ *
+ * * 2 - The profile is missing or invalid, or the validator can't
+ *   apply it.
+ * * 3 - The bag's structure or metadata is invalid.
+ * * 4 - One or more checksums don't match.
+ * * 5 - The validator could not read the bag.
+ *
+ * @type {Object<string, number>}
+ */
+const ResultCodes = {
+    profile: 2,
+    specVersion: 2,
+    checksums: 4,
+    fixityRegistry: 4,
+    bagExists: 5,
+    readError: 5,
+    streamVerify: 5
+};
+
+/**
+ * This is the result code for checks that aren't listed in ResultCodes.
+ *
+ * @type {number}
+ */
+const StructureInvalid = 3;
+
+/**
+ * These are the public properties of a Validator that describe the bag
+ * it validated, rather than how to validate it. {@link Validator#reset}
+ * clears these, along with all of the validator's private properties.
+ *
+ * @type {string[]}
+ */
+const bagProperties = [
+    'pathToBag',
+    'bagName',
+    'bagRoot',
+    'files',
+    'manifestAlgorithmsFoundInBag',
+    'tagManifestAlgorithmsFoundInBag',
+    'errors',
+    'warnings',
+    'results',
+    'resolvedValues',
+    'checkpoint',
+    'inputStream'
+];
 
 /**
  * Validator validates BagIt packages (tarred or in directory format)
@@ -20,6 +251,14 @@ const { Util } = require('../core/util');
  *
  * See the validate() method for a list of events.
  *
+ * A Validator holds the results of validating one bag, so it can
+ * validate only one bag at a time. Node runs all of the validator's code
+ * on a single thread, so callbacks and event handlers never see it half
+ * updated, but the results of two validations running on the same
+ * validator would be mixed together. Use a separate Validator for each
+ * bag you want to validate at the same time. To validate another bag
+ * with the same settings after validation ends, call reset().
+ *
  */
 class Validator extends EventEmitter {
 
@@ -52,12 +291,12 @@ class Validator extends EventEmitter {
         /**
          * bagName is the calculated name of the bag, which will be either
          * the name of the directory that contains the bag files, or the name
-         * of the tar file, minus the .tar extension. You can override this
-         * by setting it explicitly.
+         * of the tar or zip file, minus the .tar, .tar.gz, .tgz, or .zip
+         * extension. You can override this by setting it explicitly.
          *
          * @type {BagItProfile}
          */
-        this.bagName = path.basename(pathToBag, '.tar');
+        this.bagName = Validator.bagNameFromPath(pathToBag);
         /**
          * bagRoot is the name of the top-level folder to which a tarred
          * bag untars. The folder name should match the bag name.
@@ -117,6 +356,23 @@ class Validator extends EventEmitter {
          * @type {Array<string>}
          */
         this.errors = [];
+        /**
+         * warnings is a list of messages describing things in the bag
+         * that a curator may want to review, but which do not make the
+         * bag invalid.
+         *
+         * @type {Array<string>}
+         */
+        this.warnings = [];
+        /**
+         * results is a list of {@link ValidationError} objects describing
+         * all of the errors and warnings, in the order the validator found
+         * them. Each item includes the name of the check that found the
+         * problem and the path of the file it applies to, if any.
+         *
+         * @type {Array<ValidationError>}
+         */
+        this.results = [];
         /**
          * When set to true, this flag tells the validator not to validate
          * the bag serialization format. You'll want to disable this in cases
@@ -131,44 +387,697 @@ class Validator extends EventEmitter {
          */
         this.disableSerializationCheck = false;
         /**
-         * This is a private internal variable that keeps track of the number
-         * of checksum digests currently being calculated. This is part of a
-         * lovely hack.
+         * inputStream is a readable stream containing a tarred bag. Set
+         * this to validate a bag that arrives through a pipe or socket
+         * instead of from a file. The validator also reads from
+         * process.stdin when pathToBag is '-'. The stream may be
+         * gzipped. See {@link TarReader#inputStream}.
+         *
+         * Streams can be read only once, so the validator reads them
+         * in a single forward pass. Since it can't know in advance which
+         * manifests the bag has, it calculates every digest the profile
+         * allows for every file, and compares them to the manifests
+         * after it reaches the end of the stream.
+         *
+         * @type {stream.Readable}
+         * @default null
+         */
+        this.inputStream = null;
+        /**
+         * When set to true, the validator will add a warning for each
+         * empty directory in the payload. Empty directories disappear
+         * when a bag is tarred or zipped, so they usually mean the bag's
+         * creator expected files that never made it into the bag.
+         *
+         * This check applies only to unserialized bags, because tar and
+         * zip files can't tell us about empty directories.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.warnOnEmptyDirectories = false;
+        /**
+         * When set to true, the validator will flag file paths that
+         * can't be extracted on Windows. These include reserved names
+         * such as CON and LPT1, names that end with a dot or a space,
+         * names containing characters like : * and ?, and paths that are
+         * too long.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.checkWindowsPortability = false;
+        /**
+         * When this is greater than zero, the validator flags each file
+         * whose relative path has more than this many directories above
+         * it. For example, data/docs/second.txt has a depth of 2. Deeply
+         * nested paths cause problems on some file systems and in some
+         * archivers. Zero means don't check.
          *
          * @type {number}
          * @default 0
          */
-        this._hashesInProgress = 0;
+        this.maxPathDepth = 0;
         /**
-         * This is a private internal variable that registers a preliminary
-         * count of files found in a bag. This count includes tag files and
-         * manifests as well as payload files.
+         * inventory is the list of payload files, such as
+         * 'data/photos/img.jpg', that an outside catalog says should be
+         * in the bag. If this is not empty, the validator reports
+         * payload files that are missing from the bag, and payload
+         * files that aren't in the inventory. This is independent of
+         * the manifests, so it catches bags whose payload and manifests
+         * are both wrong but agree with each other.
+         *
+         * @type {string[]}
+         * @default []
+         */
+        this.inventory = [];
+        /**
+         * When set to true, the validator will flag files and
+         * directories whose names differ only by case, such as
+         * README.txt and Readme.txt. These collide when the bag is
+         * extracted on a case-insensitive file system, such as the
+         * defaults on Windows and macOS. See caseCollisionScope.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.checkCaseCollisions = false;
+        /**
+         * caseCollisionScope says where checkCaseCollisions looks for
+         * names that differ only by case. If this is 'bag', the
+         * validator flags such names anywhere in the bag, so
+         * data/one/notes.txt collides with data/two/Notes.txt. If this
+         * is 'directory', the validator flags only names in the same
+         * directory, which are the ones that actually conflict on
+         * extraction.
+         *
+         * @type {string}
+         * @default 'bag'
+         */
+        this.caseCollisionScope = 'bag';
+        /**
+         * When set to true, the validator will flag manifest entries
+         * whose path differs in case from the path of the file in the
+         * bag. For example, a manifest that lists data/File.TXT when the
+         * bag contains data/file.txt. The validator matches such a
+         * file to its manifest entry without regard to case, checks its
+         * digests, and reports the difference in case, which tools that
+         * match paths exactly can't handle.
+         *
+         * When this is false, the validator matches paths exactly, so it
+         * reports the entry as missing from the bag and the file as
+         * missing from the manifest.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.requireExactPathCase = false;
+        /**
+         * When set to true, the validator normalizes paths to Unicode
+         * NFC before matching manifest entries to files in the bag. This
+         * lets a manifest written on a system that uses composed
+         * characters (é as one code point) match files from a system
+         * that uses decomposed characters (e followed by a combining
+         * accent), as macOS does. The BagIt spec calls for byte-exact
+         * matching, so this is off by default.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.normalizeUnicode = false;
+        /**
+         * When set to true, the validator treats the bag as a versioned
+         * bag, and checks that its payload matches the result of applying
+         * the change manifests changes-1.txt, changes-2.txt, etc. in
+         * order. Each line of a change manifest is either 'added',
+         * 'modified', or 'removed', followed by a space and the relative
+         * path of a payload file. For example:
+         *
+         * @example
+         * added data/images/photo.jpg
+         * removed data/images/old.jpg
+         *
+         * If the bag has a deleted.txt file, the validator also checks
+         * that each payload path listed there, one per line, was removed
+         * by a change manifest and is no longer in the bag.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.checkChangeManifests = false;
+        /**
+         * When set to true, the validator treats the bag as a
+         * content-addressed bag. The payload of such a bag is stored in
+         * the blobs directory, with each blob named by the digest of its
+         * contents, and the tag file blob-map.txt maps each logical
+         * payload path to its blob. Each line of blob-map.txt has the
+         * same format as a manifest line:
+         *
+         * @example
+         * 9fc5e3b8a6d6d0d5f2c1e1f4b6b5a9b0...  data/images/photo.jpg
+         *
+         * The validator resolves each payload manifest entry through the
+         * blob map, so the checksums in the manifest are compared to the
+         * digests of the blobs. Several logical paths may share one blob.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.contentAddressed = false;
+        /**
+         * weakAlgorithmSeverity describes how the validator reports
+         * manifests and tag manifests whose digest algorithm is weaker
+         * than weakAlgorithmThreshold. This applies even when the bag
+         * also has manifests that use stronger algorithms. Set this to
+         * 'warning' to warn about weak manifests, or 'error' to make
+         * them invalidate the bag. Leave it empty to allow them.
+         *
+         * @type {string}
+         * @default ''
+         */
+        this.weakAlgorithmSeverity = '';
+        /**
+         * nonUtf8EncodingSeverity describes how the validator reports a
+         * bagit.txt whose Tag-File-Character-Encoding is something other
+         * than UTF-8. The BagIt spec allows other encodings but
+         * recommends UTF-8, and tag files in other encodings are often
+         * mislabeled. Set this to 'error' to make such bags invalid, or
+         * leave it empty to allow them without comment. To limit the
+         * encodings a profile accepts, set the allowed values of its
+         * Tag-File-Character-Encoding tag.
+         *
+         * @type {string}
+         * @default 'warning'
+         */
+        this.nonUtf8EncodingSeverity = 'warning';
+        /**
+         * weakAlgorithmThreshold is the weakest digest algorithm that
+         * the validator accepts without complaint when
+         * weakAlgorithmSeverity is set. Algorithms that come before this
+         * one in {@link Constants.DIGEST_ALGORITHMS} are weaker.
+         *
+         * @type {string}
+         * @default 'sha256'
+         */
+        this.weakAlgorithmThreshold = 'sha256';
+        /**
+         * approvedAlgorithms is an organizational policy that applies
+         * on top of the profile. When it's not empty, the validator adds
+         * an error for each payload or tag manifest whose algorithm is
+         * not on this list, even if the profile allows it. Use this when
+         * the list of approved algorithms changes independently of your
+         * profiles.
+         *
+         * @type {Array<string>}
+         * @default []
+         */
+        this.approvedAlgorithms = [];
+        /**
+         * policyRequiredAlgorithms lists algorithms that an
+         * organizational policy expects every bag to have a payload
+         * manifest for. The validator adds a warning for each one that
+         * the bag lacks. Unlike the profile's manifestsRequired, this
+         * does not make the bag invalid.
+         *
+         * @type {Array<string>}
+         * @default []
+         */
+        this.policyRequiredAlgorithms = [];
+        /**
+         * skipChecks is a list of the names of validation checks that the
+         * validator should not apply, such as 'serialization' or
+         * 'payloadOxum'. The validator runs all other checks as usual.
+         * Problems found by skipped checks don't appear in errors or
+         * warnings. Instead, results includes one {@link ValidationError}
+         * with severity 'skipped' for each skipped check, so reports
+         * show what was not checked.
+         *
+         * Skipping 'checksums' also keeps the validator from hashing
+         * any files, and from reading payload files at all, which is
+         * most of the work of validating a large bag. See {@link Validator#validateStructure}.
+         *
+         * @type {string[]}
+         * @default []
+         */
+        this.skipChecks = [];
+        /**
+         * When set to true, the validator compares the algorithms listed
+         * in the non-standard Manifest-Algorithm tag in bag-info.txt with
+         * the algorithms of the payload manifests actually in the bag, and
+         * warns about any differences. The tag may list several algorithms,
+         * separated by commas, or appear more than once. Bags without the
+         * tag aren't checked.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this.checkManifestAlgorithmTag = false;
+        /**
+         * allowedPayloadMimeTypes is a list of the MIME types that payload
+         * files may have, such as 'application/pdf' and 'image/tiff'. The
+         * validator detects each file's type from its first few bytes,
+         * not from its extension, and flags files whose type isn't in
+         * this list. See mimeSignatures for the types it can detect.
+         * Leave this empty to allow all types.
+         *
+         * @type {string[]}
+         * @default []
+         */
+        this.allowedPayloadMimeTypes = [];
+        /**
+         * specVersion is the version of the BagIt specification whose
+         * rules the validator applies to spec-level checks, such as
+         * whether every payload file must appear in every payload
+         * manifest. This is independent of the profile-level checks
+         * described by the {@link BagItProfile}. It must be one of the
+         * keys in {@link SpecRules}.
+         *
+         * @type {string}
+         * @default '1.0'
+         */
+        this.specVersion = '1.0';
+        /**
+         * valueResolver looks up the allowed values of vocabulary-backed
+         * tags from an external authority. If this is null, the validator
+         * uses the static values in each {@link TagDefinition}.
+         *
+         * @type {ValueResolver}
+         * @default null
+         */
+        this.valueResolver = null;
+        /**
+         * resolvedValues caches the allowed values that valueResolver
+         * returned for vocabulary-backed tags during this validation run.
+         * The key is the tag name, and the value is the list of allowed
+         * values.
+         *
+         * @type {Object<string, string[]>}
+         */
+        this.resolvedValues = {};
+        /**
+         * fixityVerifier looks up the expected digests of payload files
+         * in an external fixity registry, such as a preservation
+         * repository's database. If this is null, the validator checks
+         * payload files against the bag's manifests only.
+         *
+         * @type {FixityVerifier}
+         * @default null
+         */
+        this.fixityVerifier = null;
+        /**
+         * This is a private internal variable that holds the promises
+         * returned by fixityVerifier, so we can wait for all of them
+         * to resolve before reporting results.
+         *
+         * @type {Promise[]}
+         */
+        this._fixityChecks = [];
+        /**
+         * This is a private internal variable that holds the payload
+         * digests that don't match the digests in the fixity registry.
+         *
+         * @type {object[]}
+         */
+        this._fixityMismatches = [];
+        /**
+         * signatureVerifier verifies detached signatures over the bag's
+         * manifests, such as tagmanifest-sha256.txt.asc. If this is null,
+         * the validator ignores signature files.
+         *
+         * @type {SignatureVerifier}
+         * @default null
+         */
+        this.signatureVerifier = null;
+        /**
+         * receiptSigner signs the receipts produced by {@link
+         * Validator#generateReceipt}. It's a function that takes the
+         * receipt as a JSON string and returns a signature string, or a
+         * Promise that resolves to one. If this is null, receipts are
+         * not signed.
+         *
+         * @type {function}
+         * @default null
+         */
+        this.receiptSigner = null;
+        /**
+         * This is a private internal variable that holds the raw bytes
+         * of each manifest and detached signature, so signatureVerifier
+         * can check them after the bag has been read. This is populated
+         * only when there's a signatureVerifier. The key is the file's
+         * relative path, and the value is a list of Buffers.
+         *
+         * @type {Object<string, Buffer[]>}
+         */
+        this._signatureBytes = {};
+        /**
+         * This is a private internal variable that holds the relative
+         * paths of signatures that signatureVerifier rejected.
+         *
+         * @type {string[]}
+         */
+        this._signatureFailures = [];
+        /**
+         * checkpoint records the checksums of each file as the validator
+         * finishes hashing it, so an interrupted validation can be
+         * resumed with {@link Validator#resumeValidate}. If this is null,
+         * the validator doesn't record its progress.
+         *
+         * @type {ValidationCheckpoint}
+         * @default null
+         */
+        this.checkpoint = null;
+        /**
+         * This is a private internal variable that counts the digests
+         * still being calculated for each file, so the validator knows
+         * when a file is completely hashed and can be recorded in the
+         * checkpoint. The key is the file's relative path.
+         *
+         * @type {Object<string, number>}
+         */
+        this._digestsPending = {};
+        /**
+         * This is a private internal variable that records which files
+         * had read errors. The key is the file's relative path. The
+         * validator doesn't compare checksums for these files, since
+         * it could not read all of their contents.
+         *
+         * @type {Object<string, boolean>}
+         */
+        this._unreadableFiles = {};
+        /**
+         * This is a private internal variable that holds the problems
+         * the {@link TagFileParser} found in each tag file. The key is
+         * the tag file's relative path.
+         *
+         * @type {Object<string, Array<object>>}
+         */
+        this._tagFileParseErrors = {};
+        /**
+         * This is a private internal variable that holds the entries the
+         * {@link ManifestParser} found in each payload and tag manifest,
+         * in the order they appear in the manifest. The key is the
+         * manifest's relative path.
+         *
+         * @type {Object<string, Array<object>>}
+         */
+        this._manifestEntries = {};
+        /**
+         * This is a private internal variable that holds the logical
+         * manifests assembled from manifests that were split into parts,
+         * such as manifest-sha256.000.txt and manifest-sha256.001.txt.
+         * The key is the logical manifest's name, e.g.
+         * manifest-sha256.txt, and the value is a BagItFile whose
+         * keyValueCollection has the entries of all the parts.
+         *
+         * @type {Object<string, BagItFile>}
+         */
+        this._mergedManifests = {};
+        /**
+         * This is a private internal variable that lists the manifests
+         * and tag manifests found during the initial scan whose
+         * algorithms are not registered with
+         * {@link BagItFile.registerDigestAlgorithm}.
+         * The validator doesn't calculate digests for these, and it
+         * doesn't compare their checksums.
+         *
+         * @type {Array<string>}
+         */
+        this._unsupportedManifests = [];
+        /**
+         * This is a private internal variable that is set to true when
+         * someone calls {@link Validator#cancel}.
+         *
+         * @type {boolean}
+         */
+        this._cancelled = false;
+        /**
+         * This is a private internal variable that is set to true by
+         * {@link Validator#validateStructure} and cleared when the run
+         * starts.
+         *
+         * @type {boolean}
+         */
+        this._structureOnly = false;
+        /**
+         * This is a private internal variable that lists the checks the
+         * current run skips in addition to those in skipChecks, such as
+         * 'checksums' for {@link Validator#validateStructure}. Unlike
+         * skipChecks, this doesn't carry over to the next run.
+         *
+         * @type {string[]}
+         */
+        this._runSkipChecks = [];
+        /**
+         * This is a private internal variable that is set to true after
+         * the validator emits its end event.
+         *
+         * @type {boolean}
+         */
+        this._finished = false;
+        /**
+         * This is a private internal variable that holds the time, in
+         * milliseconds since the epoch, at which validation started.
+         * See {@link Validator#report}.
          *
          * @type {number}
-         * @default 0
          */
-        this._initialFileCount = 0;
+        this._startTime = null;
         /**
-         * This is a private internal variable that keeps track of the number
-         * of files whose checksums we have compared against checksums in
-         * the manifest.
+         * This is a private internal variable that holds the time, in
+         * milliseconds since the epoch, at which the validator emitted
+         * its end event.
          *
          * @type {number}
-         * @default 0
          */
-        this._filesChecked = 0;
-    }
-
-    /**
-     * readingFromTar returns true if the bag being validated is in tar format.
-     *
-     * @deprecated Will be removed soon.
+        this._endTime = null;
+        /**
+         * This is a private internal variable that holds the reader
+         * plugin currently reading the bag, so {@link Validator#cancel}
+         * can stop it.
+         *
+         * @type {object}
+         */
+        this._reader = null;
+        /**
+         * This is a private internal variable that holds the stream of
+         * the file currently being read, so {@link Validator#cancel}
+         * can stop reading it, even if the file is very large.
+         *
+         * @type {stream.Readable}
+         */
+        this._currentReadStream = null;
+        /**
+         * This is a private internal variable that counts the bytes in
+         * the files the validator has finished processing. It's passed
+         * along with each "fileProcessed" event.
+         *
+         * @type {number}
+         */
+        this._bytesProcessed = 0;
+        /**
+         * This is a private internal variable that holds the detected
+         * MIME type of each payload file. This is populated only when
+         * allowedPayloadMimeTypes is not empty. The key is the file's
+         * relative path.
+         *
+         * @type {Object<string, string>}
+         */
+        this._payloadMimeTypes = {};
+        /**
+         * This is a private internal variable that counts the regular
+         * file entries the reader returned while reading the bag. This
+         * should match the number of files in this.files.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._regularFileEntries = 0;
+        /**
+         * This is a private internal variable that counts how many
+         * times each relative path appeared as a regular file entry
+         * while reading the bag. Any count above one means the archive
+         * has duplicate entries for that path.
+         *
+         * @type {Object<string, number>}
+         */
+        this._entryCounts = {};
+        /**
+         * This is a private internal variable that maps each logical
+         * payload path in a content-addressed bag to the relative path
+         * of the blob that holds its contents. See
+         * {@link Validator#contentAddressed}.
+         *
+         * @type {Object<string, string>}
+         */
+        this._blobMappings = {};
+        /**
+         * When this is greater than zero, the validator warns about
+         * compressed bags whose {@link Validator#compressionRatio} is
+         * higher than this. A very high ratio can mean the bag is padded
+         * with zeros or other filler. Zero means don't check.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this.compressionRatioWarning = 0;
+        /**
+         * This is a private internal variable that holds the number of
+         * bytes in the tar stream of a compressed bag, after it has
+         * been decompressed. This is set after the bag has been read.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._uncompressedByteCount = 0;
+        /**
+         * This is a private internal variable that will be set to true
+         * if bagit.txt begins with a byte order mark.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this._bagItTxtHasBOM = false;
+        /**
+         * This is a private internal variable that holds the raw bytes
+         * of each text tag file, so we can check them against the
+         * Tag-File-Character-Encoding declared in bagit.txt. The key is
+         * the file's relative path, and the value is a list of Buffers.
+         * Tag files are small, so this shouldn't take much memory.
+         *
+         * @type {Object<string, Buffer[]>}
+         */
+        this._tagFileBytes = {};
+        /**
+         * This is a private internal variable that will be set to true
+         * when validation was started by streamVerifyTar(). In that mode,
+         * the validator reads manifests first, verifies each payload
+         * file's checksums as the file streams by, and then discards
+         * the checksums.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this._streamVerify = false;
+        /**
+         * This is a private internal variable that holds the paths of the
+         * payload files that streamVerifyTar() has verified and dropped
+         * from this.files. The manifest checks need the paths, but not
+         * the rest of the {@link BagItFile}.
+         *
+         * @type {Set<string>}
+         */
+        this._streamedPayloadPaths = new Set();
+        /**
+         * This is a private internal variable that holds the total size,
+         * in bytes, of the payload files in _streamedPayloadPaths.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._streamedPayloadBytes = 0;
+        /**
+         * This is a private internal variable that holds the directory
+         * into which validateAndExtract() extracts the bag. It's null
+         * when the validator is not extracting.
+         *
+         * @type {string}
+         * @default null
+         */
+        this._extractTo = null;
+        /**
+         * This is a private internal variable that holds a Promise for
+         * each file validateAndExtract() is writing. Each one resolves
+         * when the file has been completely written.
+         *
+         * @type {Array<Promise>}
+         */
+        this._extractions = [];
+        /**
+         * This is a private internal variable that keeps track of the number
+         * of checksum digests currently being calculated. This is part of a
+         * lovely hack.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._hashesInProgress = 0;
+        /**
+         * This is a private internal variable that registers a preliminary
+         * count of files found in a bag. This count includes tag files and
+         * manifests as well as payload files.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._initialFileCount = 0;
+        /**
+         * This is a private internal variable that holds the name of
+         * each top-level entry in a tarred or zipped bag. A serialized
+         * bag should have exactly one, its bag directory.
+         *
+         * @type {Set<string>}
+         */
+        this._topLevelEntries = new Set();
+        /**
+         * This is a private internal variable that will be set to true
+         * if the reader finds the payload directory, data.
+         *
+         * @type {boolean}
+         * @default false
+         */
+        this._payloadDirectoryFound = false;
+        /**
+         * This is a private internal variable that keeps track of the number
+         * of files whose checksums we have compared against checksums in
+         * the manifest.
+         *
+         * @type {number}
+         * @default 0
+         */
+        this._filesChecked = 0;
+    }
+
+    /**
+     * readingFromTar returns true if the bag being validated is in tar format.
+     *
+     * @deprecated Will be removed soon.
      *
      * @returns {boolean}
      */
     readingFromTar() {
         // TODO: Remove me!
-        return this.pathToBag.endsWith('.tar');
+        return tarExtension.test(this.pathToBag);
+    }
+
+    /**
+     * readingFromArchive returns true if the bag being validated is a
+     * tar or zip file that the validator reads without extracting it.
+     *
+     * @returns {boolean}
+     */
+    readingFromArchive() {
+        return this.readingFromStream() || archiveExtension.test(this.pathToBag);
+    }
+
+    /**
+     * readingFromStream returns true if the validator is reading a
+     * tarred bag from inputStream or from STDIN, rather than from a
+     * file or directory.
+     *
+     * @returns {boolean}
+     */
+    readingFromStream() {
+        return this.inputStream != null || this.pathToBag == stdinPath;
+    }
+
+    /**
+     * readingFromCompressedTar returns true if the bag being validated
+     * is a gzipped tar file, ending in .tar.gz or .tgz.
+     *
+     * @returns {boolean}
+     */
+    readingFromCompressedTar() {
+        return compressedTarExtension.test(this.pathToBag);
     }
 
     /**
@@ -201,6 +1110,8 @@ class Validator extends EventEmitter {
 
     /**
      * Returns an array of BagItFile objects that represent payload files.
+     * After {@link Validator#streamVerifyTar}, this is empty, since that
+     * method drops payload files once it has verified them.
      *
      * @returns {Array<BagItFile>}
      */
@@ -208,13 +1119,69 @@ class Validator extends EventEmitter {
         return Object.values(this.files).filter(f => f.isPayloadFile());
     }
 
+    /**
+     * _payloadPaths returns the relative paths of all payload files,
+     * including those that streamVerifyTar() dropped from this.files.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {Array<string>}
+     */
+    _payloadPaths() {
+        return this.payloadFiles().map(f => f.relDestPath).concat([...this._streamedPayloadPaths]);
+    }
+
+    /**
+     * _payloadFileCount returns the number of payload files, including
+     * those that streamVerifyTar() dropped from this.files.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {number}
+     */
+    _payloadFileCount() {
+        return this.payloadFiles().length + this._streamedPayloadPaths.size;
+    }
+
+    /**
+     * _filePaths returns the relative paths of all files in the bag,
+     * including payload files that streamVerifyTar() dropped from
+     * this.files.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {Array<string>}
+     */
+    _filePaths() {
+        return Object.keys(this.files).concat([...this._streamedPayloadPaths]);
+    }
+
+    /**
+     * _hasFile returns true if the bag contains a file at relPath,
+     * including a payload file that streamVerifyTar() dropped from
+     * this.files.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {string} relPath - The relative path of the file.
+     *
+     * @returns {boolean}
+     */
+    _hasFile(relPath) {
+        return this.files[relPath] !== undefined || this._streamedPayloadPaths.has(relPath);
+    }
+
     /**
      * Returns an array of BagItFile objects that represent payload manifests.
      *
      * @returns {Array<BagItFile>}
      */
     payloadManifests() {
-        return Object.values(this.files).filter(f => f.isPayloadManifest());
+        return this._logicalManifests().filter(f => f.isPayloadManifest());
     }
 
     /**
@@ -232,117 +1199,1568 @@ class Validator extends EventEmitter {
      * @returns {Array<BagItFile>}
      */
     tagManifests() {
-        return Object.values(this.files).filter(f => f.isTagManifest());
+        return this._logicalManifests().filter(f => f.isTagManifest());
     }
 
     /**
-     * Returns a reader plugin that is capable of reading the bag we want
-     * to validate. Note that this always returns a new reader, so if you
-     * call it 20 times, you're going to get 20 individual reader objects.
+     * _logicalManifests returns the bag's manifests and tag manifests.
+     * Manifests that were split into parts appear as a single manifest
+     * containing the entries of all the parts, instead of one manifest
+     * per part.
      *
-     * @returns {Plugin}
+     * @returns {Array<BagItFile>}
+     *
+     * @private
      */
-    getNewReader() {
-        var fileExtension = path.extname(this.pathToBag);
-        if (this.readingFromDir()) {
-            fileExtension = 'directory';
+    _logicalManifests() {
+        let whole = Object.values(this.files).filter(f => (f.isPayloadManifest() || f.isTagManifest()) && !Constants.RE_SPLIT_MANIFEST.test(f.relDestPath));
+        return whole.concat(Object.values(this._mergedManifests));
+    }
+
+    /**
+     * _manifestFile returns the manifest or tag manifest with the given
+     * name, such as manifest-sha256.txt. If the bag split that manifest
+     * into parts, this returns the manifest assembled from the parts.
+     * Returns undefined if the bag has no such manifest.
+     *
+     * @param {string} name - The name of the manifest.
+     *
+     * @returns {BagItFile|undefined}
+     *
+     * @private
+     */
+    _manifestFile(name) {
+        return this.files[name] || this._mergedManifests[name];
+    }
+
+    /**
+     * _mergeSplitManifests assembles a logical manifest from each set of
+     * manifest parts in the bag, such as manifest-sha256.000.txt and
+     * manifest-sha256.001.txt. Parts are combined in order of their part
+     * numbers, as if they had been concatenated. The validator calls
+     * this after it has parsed the manifests, and treats each logical
+     * manifest as it would a manifest that was never split.
+     *
+     * If the bag has both a whole manifest and parts for the same
+     * algorithm, this adds an error and ignores the parts.
+     *
+     * @private
+     */
+    _mergeSplitManifests() {
+        let partsByName = {};
+        for (let relPath of Object.keys(this.files)) {
+            let match = relPath.match(Constants.RE_SPLIT_MANIFEST);
+            if (match) {
+                let name = `${match[1]}-${match[2]}.txt`;
+                partsByName[name] = partsByName[name] || [];
+                partsByName[name].push({ number: parseInt(match[3], 10), relPath: relPath });
+            }
         }
-        var plugins = PluginManager.canRead(fileExtension);
-        if (!plugins) {
-            throw new Error(`No plugins know how to read ${this.pathToBag}`);
+        this._mergedManifests = {};
+        for (let name of Object.keys(partsByName).sort()) {
+            let parts = partsByName[name].sort((a, b) => a.number - b.number).map(p => p.relPath);
+            if (this.files[name] !== undefined) {
+                this._addError('splitManifests', `Bag has ${name} and also has it split into parts: ${parts.join(', ')}`, name);
+                continue;
+            }
+            let size = parts.reduce((total, relPath) => total + Number(this.files[relPath].size), 0);
+            let manifest = new BagItFile('', name, new FileStat({ size: size, type: 'file' }));
+            manifest.keyValueCollection = new KeyValueCollection();
+            for (let relPath of parts) {
+                let collection = this.files[relPath].keyValueCollection;
+                if (collection == null) {
+                    continue;
+                }
+                for (let key of collection.keys()) {
+                    for (let value of collection.all(key)) {
+                        manifest.keyValueCollection.add(key, value);
+                    }
+                }
+            }
+            this._mergedManifests[name] = manifest;
         }
-        // plugins[0] is a reader plugin (a class) with a constructor
-        // that takes pathToBag as its sole param.
-        return new plugins[0](this.pathToBag);
     }
 
     /**
-     * validate runs all validation operations on the bag specified in the
-     * validator's pathToBag property. This includes:
+     * Returns all values of the specified tag in the specified tag file,
+     * in the order they appear. Returns an empty array if the bag has no
+     * such tag file or the file does not contain the tag. This is
+     * accurate only after the bag has been read.
      *
-     * * making sure the untarred bag name matches the tarred bag name
-     * * validation checksums for all payload files, and for tag files that have checksums
-     * * ensuring there are no extra or missing payload files
-     * * ensuring that required tag files and manifests are present and valid
-     * * ensuring that required tags are present and, where applicable, have legal values
+     * @param {string} tagFile - The relative path of the tag file.
+     * E.g. 'bag-info.txt'.
      *
-     * This method emits events "start", "task", "end", and "error".
+     * @param {string} tagName - The name of the tag.
+     *
+     * @returns {Array<string>}
      */
-    validate() {
-        this.emit('validateStart', `Validating ${this.pathToBag}`);
-        if (!fs.existsSync(this.pathToBag)) {
-            let msg = Context.y18n.__('File does not exist at %s', this.pathToBag);
-            this.errors.push(msg);
-            this.emit('error', msg);
-            this.emit('end');
-            return;
+    tagValues(tagFile, tagName) {
+        let bagItFile = this.files[tagFile];
+        if (!bagItFile || !bagItFile.keyValueCollection) {
+            return [];
         }
-        if (!this._validateProfile()) {
-            this.emit('error', this.errors.join(' '));
-            this.emit('end');
-            return;
+        return bagItFile.keyValueCollection.all(tagName) || [];
+    }
+
+    /**
+     * manifestContent returns the payload checksums the validator
+     * calculated for the specified algorithm, in BagIt manifest format.
+     * Lines are sorted by path, and each has the digest, two spaces, and
+     * the file's relative path with forward slashes, ending with a
+     * line feed. Call this after the bag has been read.
+     *
+     * The validator calculates only the algorithms the profile or the
+     * bag's manifests call for. This throws an Error if any payload file
+     * has no checksum for the algorithm.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @returns {string}
+     */
+    manifestContent(algorithm) {
+        let streamed = [...this._streamedPayloadPaths].sort();
+        if (streamed.length > 0) {
+            throw new Error(`Validator has no ${algorithm} checksum for ${streamed[0]}.`);
         }
-        if (!this._validateSerialization()) {
-            this.emit('error', this.errors.join(' '));
-            this.emit('end')
-            return;
+        let lines = [];
+        let files = this.payloadFiles().sort((a, b) => a.relDestPath < b.relDestPath ? -1 : 1);
+        for (let f of files) {
+            let digest = f.checksums[algorithm];
+            if (digest === undefined) {
+                throw new Error(`Validator has no ${algorithm} checksum for ${f.relDestPath}.`);
+            }
+            lines.push(`${digest}  ${ManifestParser.encodePath(f.relDestPath.replace(/\\/g, '/'))}\n`);
         }
-
-        this.emit('task', new TaskDescription(this.pathToBag, 'start'))
-
-        // Scan the bag for manifests. When that completes, it will
-        // call _readBag() to read the contents.
-        this._scanBag();
+        return lines.join('');
     }
 
     /**
-     * This method does an initial scan of the bag to see what manifests
-     * are present. While some BagItProfiles specify that a manifest
-     * with algorithm X must be present, the profile does preclude other
-     * manifests with different algorithms also being present. It's
-     * common among APTrust bags, for example, to find both md5 and sha256
-     * manifests. The validator does an initial scan of the bag to find
-     * extra manifests so it knows which checksums to run on payload and
-     * tag files.
+     * writeManifest writes the output of {@link Validator#manifestContent}
+     * to a writable stream. It does not end the stream. This returns a
+     * Promise that resolves when the data has been written, and rejects
+     * if the write fails or the validator has no checksums for the
+     * algorithm.
      *
-     * If an initial scan reveals manifest-md5.txt and manifest-sha256.txt,
-     * the manifestAlgorithmsFoundInBag will contain ["md5", "sha256"].
+     * @example
+     * let out = fs.createWriteStream(path.join(dir, 'manifest-sha256.txt'));
+     * validator.writeManifest(out, 'sha256').then(() => out.end());
      *
-     * The most common reason for finding multiple manifests in a bag
-     * comes from institutions that internally require one checksumming
-     * algorithm, and who have to produce bags whose spec requires a
-     * different algorithm.
+     * @param {stream.Writable} writable - The stream to write to.
      *
-     * The validator will later validate ALL checksums, even those found
-     * in manifests that are not part of the {@link BagItProfile}.
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
      *
+     * @returns {Promise}
      */
-    _scanBag() {
+    writeManifest(writable, algorithm) {
         var validator = this;
-        var reader = this.getNewReader();
-        reader.on('error', function(err) {
-            validator.emit('error', err);
-        });
-        reader.on('entry', function (entry) {
-            validator._initialFileCount += 1;
-            if (validator.bagRoot == null && validator.readingFromTar()) {
-                validator.bagRoot = entry.relPath.split(/\//)[0];
-            }
-            var relPath = validator._cleanEntryRelPath(entry.relPath);
-            if (relPath.match(Constants.RE_MANIFEST) || relPath.match(Constants.RE_TAG_MANIFEST)) {
-                var algorithm = relPath.split('-')[1].split('.')[0];
-                var list = relPath.match(Constants.RE_MANIFEST) ? validator.manifestAlgorithmsFoundInBag : validator.tagManifestAlgorithmsFoundInBag;
-                if (!list.includes(algorithm)) {
-                    list.push(algorithm);
+        return new Promise(function(resolve, reject) {
+            let content = validator.manifestContent(algorithm);
+            writable.write(content, function(err) {
+                if (err) {
+                    reject(err);
+                } else {
+                    resolve();
                 }
-            }
-        });
-        reader.on('end', function() {
-            validator._readBag();
+            });
         });
-
-        // List the contents of the bag.
-        reader.list();
+    }
+
+    /**
+     * payloadMerkleTree returns a Merkle tree built from the payload
+     * files' digests, for verifying or comparing parts of a payload
+     * without rehashing all of it. Call this after the bag has been
+     * read. It uses the checksums the validator already calculated, and
+     * throws an Error if any payload file has no checksum for the
+     * algorithm.
+     *
+     * The tree is a list of levels. Level 0 has one leaf per payload
+     * file, sorted by path. Each leaf is the hash of the file's manifest
+     * line, "digest  path", so renaming a file changes its leaf. Each
+     * node on the next level up is the hash of the binary digests of
+     * two adjacent nodes. When a level has an odd number of nodes, the
+     * last one moves up unchanged. The last level has only the root.
+     * An empty payload has a single level whose only node is the hash
+     * of an empty string.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @returns {Array<Array<string>>} The levels of the tree, from the
+     * leaves to the root, as hex-encoded digests.
+     */
+    payloadMerkleTree(algorithm) {
+        let hash = function(data) {
+            return crypto.createHash(algorithm).update(data).digest('hex');
+        };
+        let leaves = this.manifestContent(algorithm).split('\n').filter(line => line != '').map(hash);
+        if (leaves.length == 0) {
+            return [[hash('')]];
+        }
+        let levels = [leaves];
+        while (levels[levels.length - 1].length > 1) {
+            let nodes = levels[levels.length - 1];
+            let parents = [];
+            for (let i = 0; i < nodes.length; i += 2) {
+                if (i + 1 == nodes.length) {
+                    parents.push(nodes[i]);
+                } else {
+                    parents.push(hash(Buffer.concat([Buffer.from(nodes[i], 'hex'), Buffer.from(nodes[i + 1], 'hex')])));
+                }
+            }
+            levels.push(parents);
+        }
+        return levels;
+    }
+
+    /**
+     * payloadMerkleRoot returns the root of the Merkle tree described
+     * in {@link Validator#payloadMerkleTree}. The root is the same for
+     * any two bags whose payloads have the same paths and contents.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @returns {string}
+     */
+    payloadMerkleRoot(algorithm) {
+        let levels = this.payloadMerkleTree(algorithm);
+        return levels[levels.length - 1][0];
+    }
+
+    /**
+     * resultCsv returns the validator's errors and warnings as CSV, with
+     * one row per {@link ValidationError}. The first line is a header
+     * with the column names severity, check, filePath, and message.
+     * Call this after validation completes.
+     *
+     * @returns {string}
+     */
+    resultCsv() {
+        var lines = [ValidationError.csvHeader()];
+        for (let result of this.results) {
+            lines.push(result.toCsvRow());
+        }
+        return lines.join("\r\n") + "\r\n";
+    }
+
+    /**
+     * protoResult returns the outcome of validation as a flat object
+     * that maps directly to a Protocol Buffers message. It contains only
+     * scalar fields and lists of flat objects (repeated messages), with no
+     * maps and no nesting beyond one level. Call this after validation
+     * completes. The object has the following properties:
+     *
+     * * bagName - The name of the bag.
+     * * valid - True if there were no errors.
+     * * resultCode - See {@link Validator#resultCode}.
+     * * errors - A list of error messages.
+     * * warnings - A list of warning messages.
+     * * payloadFileCount - The number of payload files.
+     * * payloadByteCount - The total size of the payload, in bytes.
+     * * tagFileCount - The number of tag files.
+     * * manifestCount - The number of payload manifests.
+     * * tagManifestCount - The number of tag manifests.
+     * * results - A list of objects with severity, check, filePath, and
+     *   message properties. See {@link ValidationError}.
+     * * files - A list of objects with path, fileType, size, and status
+     *   properties, sorted by path. Status is 'error' if any error
+     *   applies to the file, 'warning' if any warning does, and 'ok'
+     *   otherwise.
+     *
+     * @returns {object}
+     */
+    protoResult() {
+        let fileStatus = {};
+        for (let result of this.results) {
+            if (result.severity == 'error') {
+                fileStatus[result.filePath] = 'error';
+            } else if (result.severity == 'warning' && fileStatus[result.filePath] === undefined) {
+                fileStatus[result.filePath] = 'warning';
+            }
+        }
+        let files = Object.keys(this.files).sort().map(relPath => ({
+            path: relPath,
+            fileType: this.files[relPath].fileType,
+            size: Number(this.files[relPath].size),
+            status: fileStatus[relPath] || 'ok'
+        }));
+        return {
+            bagName: this.bagName,
+            valid: this.errors.length == 0,
+            resultCode: this.resultCode(),
+            errors: this.errors.slice(),
+            warnings: this.warnings.slice(),
+            payloadFileCount: this._payloadFileCount(),
+            payloadByteCount: this.payloadByteCount(),
+            tagFileCount: this.tagFiles().length,
+            manifestCount: this.payloadManifests().length,
+            tagManifestCount: this.tagManifests().length,
+            results: this.results.map(r => ({
+                severity: r.severity,
+                check: r.check,
+                filePath: r.filePath,
+                message: r.message
+            })),
+            files: files
+        };
+    }
+
+    /**
+     * report returns a summary of the validation results as a plain
+     * object that a script can parse after JSON.stringify(). Call this
+     * after validation completes. It uses only what the validator
+     * recorded while reading the bag, so it doesn't read the bag again.
+     * The object has these properties:
+     *
+     * * bagPath - The path to the bag, as passed to the constructor.
+     * * profileName - The name of the {@link BagItProfile}.
+     * * valid - True if there were no errors.
+     * * errors - A list of objects with severity, check, filePath,
+     *   message, expected, actual, and actualSize properties. See
+     *   {@link ValidationError}.
+     * * payloadByteCount - The total size of the payload, in bytes.
+     * * payloadFileCount - The number of payload files.
+     * * algorithms - The digest algorithms the validator calculated,
+     *   sorted. This is empty if checksums were skipped.
+     * * elapsedMs - The time validation took, in milliseconds.
+     *
+     * @returns {object}
+     */
+    report() {
+        let algorithms = this._verifiedAlgorithms();
+        let elapsedMs = 0;
+        if (this._startTime != null) {
+            elapsedMs = (this._endTime || Date.now()) - this._startTime;
+        }
+        return {
+            bagPath: this.pathToBag,
+            profileName: this.profile.name,
+            valid: this.errors.length == 0,
+            errors: this.structuredErrors().map(e => ({
+                severity: e.severity,
+                check: e.check,
+                filePath: e.filePath,
+                message: e.message,
+                expected: e.expected,
+                actual: e.actual,
+                actualSize: e.actualSize
+            })),
+            payloadByteCount: this.payloadByteCount(),
+            payloadFileCount: this._payloadFileCount(),
+            algorithms: algorithms,
+            elapsedMs: elapsedMs
+        };
+    }
+
+    /**
+     * bagNameFromPath returns the name of the bag at pathToBag. For a
+     * directory, this is the name of the directory. For a tar or zip
+     * file, it's the name of the file minus its .tar, .tar.gz, .tgz, or
+     * .zip extension, which is also the name of the directory that
+     * contains the bag's files inside the archive. This calls
+     * {@link Util.bagNameFromPath}, but strips only the extensions of
+     * formats the validator can read. The name of a .rar or .7z file
+     * keeps its extension.
+     *
+     * @param {string} pathToBag - The path to the bag.
+     *
+     * @returns {string}
+     *
+     * @example
+     *
+     * Validator.bagNameFromPath('/bags/photos.tar.gz'); // 'photos'
+     */
+    static bagNameFromPath(pathToBag) {
+        return Util.bagNameFromPath(pathToBag, archiveExtension);
+    }
+
+    /**
+     * diffResults compares two results returned by {@link
+     * Validator#protoResult}, usually from validating the same bag at
+     * different times, and describes what changed. The returned object
+     * has these properties:
+     *
+     * * errorsAdded - Error results in current that were not in previous.
+     * * errorsRemoved - Error results in previous that are not in current.
+     * * warningsAdded - Warning results in current that were not in
+     *   previous.
+     * * warningsRemoved - Warning results in previous that are not in
+     *   current.
+     * * filesAdded - Paths of files in current that were not in previous.
+     * * filesRemoved - Paths of files in previous that are not in current.
+     * * filesResized - Objects with path, previousSize, and currentSize
+     *   for files whose size changed.
+     * * payloadFileCountDelta - The change in the number of payload files.
+     * * payloadByteCountDelta - The change in the size of the payload.
+     *
+     * Results are matched on severity, check, filePath, and message.
+     *
+     * @param {object} previous - The earlier result.
+     *
+     * @param {object} current - The later result.
+     *
+     * @returns {object}
+     */
+    static diffResults(previous, current) {
+        let key = r => [r.severity, r.check, r.filePath, r.message].join('\n');
+        let previousKeys = new Set(previous.results.map(key));
+        let currentKeys = new Set(current.results.map(key));
+        let added = current.results.filter(r => !previousKeys.has(key(r)));
+        let removed = previous.results.filter(r => !currentKeys.has(key(r)));
+        let previousSizes = new Map(previous.files.map(f => [f.path, f.size]));
+        let currentSizes = new Map(current.files.map(f => [f.path, f.size]));
+        let filesResized = [];
+        for (let [filePath, size] of currentSizes) {
+            if (previousSizes.has(filePath) && previousSizes.get(filePath) != size) {
+                filesResized.push({ path: filePath, previousSize: previousSizes.get(filePath), currentSize: size });
+            }
+        }
+        return {
+            errorsAdded: added.filter(r => r.severity == 'error'),
+            errorsRemoved: removed.filter(r => r.severity == 'error'),
+            warningsAdded: added.filter(r => r.severity == 'warning'),
+            warningsRemoved: removed.filter(r => r.severity == 'warning'),
+            filesAdded: [...currentSizes.keys()].filter(p => !previousSizes.has(p)),
+            filesRemoved: [...previousSizes.keys()].filter(p => !currentSizes.has(p)),
+            filesResized: filesResized,
+            payloadFileCountDelta: current.payloadFileCount - previous.payloadFileCount,
+            payloadByteCountDelta: current.payloadByteCount - previous.payloadByteCount
+        };
+    }
+
+    /**
+     * generateReceipt returns a Promise that resolves to a JSON receipt
+     * the depositor can keep as proof that the bag was valid when we
+     * received it. Call this after validation completes. The receipt
+     * has these properties, always in this order:
+     *
+     * * bagName - The name of the bag.
+     * * payloadOxum - The payload's byte count and file count, in the
+     *   form of the Payload-Oxum tag.
+     * * manifestAlgorithms - The algorithms of the bag's payload
+     *   manifests, sorted.
+     * * tagManifestAlgorithms - The algorithms of the bag's tag
+     *   manifests, sorted.
+     * * archiveSha256 - The sha256 digest of the tar or zip file. This
+     *   is empty for bags that are directories or streams, since there
+     *   is no single file to checksum.
+     * * generatedAt - The time the receipt was generated, in ISO
+     *   format, UTC.
+     *
+     * If receiptSigner is set, the receipt also has a signature property
+     * containing the signer's signature over the JSON of the properties
+     * above.
+     *
+     * The Promise is rejected if the bag is not valid.
+     *
+     * @returns {Promise<string>}
+     */
+    generateReceipt() {
+        if (this.errors.length > 0) {
+            return Promise.reject(new Error(`Cannot generate a receipt for invalid bag ${this.bagName}.`));
+        }
+        let validator = this;
+        let archiveSha256 = Promise.resolve('');
+        if (this.readingFromArchive() && !this.readingFromStream()) {
+            archiveSha256 = new Promise(function(resolve, reject) {
+                let hash = crypto.createHash('sha256');
+                let readStream = fs.createReadStream(validator.pathToBag);
+                readStream.on('error', reject);
+                readStream.on('data', chunk => hash.update(chunk));
+                readStream.on('end', () => resolve(hash.digest('hex')));
+            });
+        }
+        return archiveSha256.then(function(digest) {
+            let receipt = {
+                bagName: validator.bagName,
+                payloadOxum: `${validator.payloadByteCount()}.${validator._payloadFileCount()}`,
+                manifestAlgorithms: validator.manifestAlgorithmsFoundInBag.slice().sort(),
+                tagManifestAlgorithms: validator.tagManifestAlgorithmsFoundInBag.slice().sort(),
+                archiveSha256: digest,
+                generatedAt: dateFormat(Date.now(), 'isoUtcDateTime')
+            };
+            let unsigned = JSON.stringify(receipt);
+            if (validator.receiptSigner == null) {
+                return unsigned;
+            }
+            return Promise.resolve(validator.receiptSigner(unsigned)).then(function(signature) {
+                receipt.signature = signature;
+                return JSON.stringify(receipt);
+            });
+        });
+    }
+
+    /**
+     * remediationPlan returns a list of steps that would fix the errors
+     * found during validation, in the order they should be carried out.
+     * Call this after validation completes. Each step is an object whose
+     * action property says what to do. The other properties depend on
+     * the action:
+     *
+     * * removeFile - Delete the file at filePath, because the profile
+     *   doesn't allow it.
+     * * generateManifest - Create the payload manifest at filePath
+     *   using algorithm.
+     * * addTag - Add tagName to the tag file at filePath. If the profile
+     *   has a default value or a list of allowed values for the tag,
+     *   the step includes value or allowedValues.
+     * * setTagValue - Change the value of tagName in the tag file at
+     *   filePath to value, or to one of allowedValues.
+     * * generateTagManifest - Create or rebuild the tag manifest at
+     *   filePath using algorithm. This follows tag changes, since they
+     *   change the tag files' digests.
+     * * serialize - Package the bag in the specified format, such as
+     *   'application/tar'.
+     * * unserialize - Unpack the bag into a directory.
+     * * review - The validator can't suggest a fix for this problem,
+     *   which is described by check, filePath and message. Checksum
+     *   mismatches, for example, may mean a file is corrupt.
+     *
+     * Warnings don't appear in the plan. If the bag is valid, the plan
+     * is empty.
+     *
+     * @returns {Array<object>}
+     */
+    remediationPlan() {
+        let failedChecks = new Set(this.results.filter(r => r.severity == 'error').map(r => r.check));
+        let fixableChecks = ['allowedManifests', 'allowedTagFiles', 'requiredManifests', 'tags', 'payloadOxum', 'serialization'];
+        let steps = [];
+        for (let result of this.results) {
+            if (result.severity != 'error') {
+                continue;
+            }
+            if (result.check == 'allowedManifests' || result.check == 'allowedTagFiles') {
+                steps.push({ action: 'removeFile', filePath: result.filePath });
+            }
+        }
+        if (failedChecks.has('requiredManifests')) {
+            for (let alg of this.profile.manifestsRequired) {
+                if (this._manifestFile(`manifest-${alg}.txt`) === undefined) {
+                    steps.push({ action: 'generateManifest', filePath: `manifest-${alg}.txt`, algorithm: alg });
+                }
+            }
+        }
+        let tagSteps = [];
+        if (failedChecks.has('tags')) {
+            tagSteps = this._tagRemediationSteps();
+        }
+        if (failedChecks.has('payloadOxum') && this.files['fetch.txt'] === undefined) {
+            tagSteps.push({ action: 'setTagValue', filePath: 'bag-info.txt', tagName: 'Payload-Oxum', value: `${this.payloadByteCount()}.${this._payloadFileCount()}` });
+        }
+        steps = steps.concat(tagSteps);
+        let tagManifestAlgs = [];
+        if (tagSteps.length > 0) {
+            tagManifestAlgs = this.tagManifests().map(m => path.basename(m.relDestPath, '.txt').split('-')[1]);
+        }
+        if (failedChecks.has('requiredManifests')) {
+            tagManifestAlgs = tagManifestAlgs.concat(this.profile.tagManifestsRequired.filter(alg => this._manifestFile(`tagmanifest-${alg}.txt`) === undefined));
+        }
+        for (let alg of [...new Set(tagManifestAlgs)].sort()) {
+            steps.push({ action: 'generateTagManifest', filePath: `tagmanifest-${alg}.txt`, algorithm: alg });
+        }
+        for (let result of this.results) {
+            if (result.severity == 'error' && !fixableChecks.includes(result.check)) {
+                steps.push({ action: 'review', check: result.check, filePath: result.filePath, message: result.message });
+            }
+        }
+        if (failedChecks.has('serialization')) {
+            if (this.profile.serialization == 'forbidden') {
+                steps.push({ action: 'unserialize' });
+            } else {
+                steps.push({ action: 'serialize', format: this.profile.acceptSerialization[0] });
+            }
+        }
+        return steps;
+    }
+
+    /**
+     * _tagRemediationSteps returns the addTag and setTagValue steps
+     * needed to make the bag's tags conform to the profile. See
+     * {@link Validator#remediationPlan}.
+     *
+     * @returns {Array<object>}
+     *
+     * @private
+     */
+    _tagRemediationSteps() {
+        let steps = [];
+        for (let tagDef of this.profile.tags) {
+            let tagFile = this.files[tagDef.tagFile];
+            let values = null;
+            if (tagFile && tagFile.keyValueCollection != null) {
+                values = tagFile.keyValueCollection.all(tagDef.tagName);
+            }
+            let allowedValues = this._allowedValues(tagDef);
+            let required = tagDef.required || this._conditionallyRequired(tagDef);
+            let step = { filePath: tagDef.tagFile, tagName: tagDef.tagName };
+            if (values == null) {
+                if (!required) {
+                    continue;
+                }
+                step.action = 'addTag';
+            } else {
+                let isBad = values.some(value =>
+                    (required && value == '') ||
+                    (value != '' && allowedValues.length > 0 && !tagDef.isAllowedValue(value, allowedValues)) ||
+                    (value != '' && !tagDef.hasValidFormat(value)) ||
+                    (value != '' && allowedValues.length == 0 && !tagDef.hasValidPattern(value)));
+                if (!isBad) {
+                    continue;
+                }
+                step.action = 'setTagValue';
+            }
+            if (tagDef.defaultValue) {
+                step.value = tagDef.defaultValue;
+            }
+            if (allowedValues.length > 0) {
+                step.allowedValues = allowedValues;
+            }
+            steps.push(step);
+        }
+        return steps;
+    }
+
+    /**
+     * conformanceLevel returns the name of the highest conformance level
+     * in the profile's conformanceLevels that this bag achieves. Levels
+     * are cumulative, so a bag achieves a level only if it also meets the
+     * requirements of all the levels before it. Call this after validation
+     * completes.
+     *
+     * This returns an empty string if the bag is not valid, or if it
+     * doesn't meet the requirements of the first level.
+     *
+     * @returns {string}
+     */
+    conformanceLevel() {
+        if (this.errors.length > 0) {
+            return '';
+        }
+        let achieved = '';
+        for (let level of this.profile.conformanceLevels || []) {
+            if (!this._meetsConformanceLevel(level)) {
+                break;
+            }
+            achieved = level.name;
+        }
+        return achieved;
+    }
+
+    /**
+     * resultCode returns an exit code describing the most severe failure
+     * found during validation, so scripts can tell one kind of failure
+     * from another. The codes, from least to most severe, are:
+     *
+     * * 0 - The bag is valid.
+     * * 2 - The profile is missing or invalid.
+     * * 3 - The bag's structure or metadata is invalid.
+     * * 4 - One or more checksums don't match.
+     * * 5 - The validator could not read the bag.
+     *
+     * Warnings don't affect the result code. Call this after validation
+     * completes.
+     *
+     * @returns {number}
+     */
+    resultCode() {
+        let code = Constants.EXIT_SUCCESS;
+        for (let result of this.results) {
+            if (result.severity != 'error') {
+                continue;
+            }
+            let resultCode = ResultCodes[result.check] || StructureInvalid;
+            code = Math.max(code, resultCode);
+        }
+        return code;
+    }
+
+    /**
+     * generateReportTagFile returns the contents of a BagIt tag file that
+     * summarizes the results of validation, so you can embed a record of
+     * the validation in the bag itself. Call this after validation
+     * completes. The tag file includes the following tags:
+     *
+     * * Validation-Date - The current date and time, in UTC.
+     * * Validation-Software - The name and version of this software.
+     * * BagIt-Profile-Identifier - The identifier of the profile the bag
+     *   was validated against.
+     * * BagIt-Version - The version of the BagIt spec the validator applied.
+     * * Validation-Result - Either 'valid' or 'invalid'.
+     * * Algorithms-Verified - A comma-separated list of the digest
+     *   algorithms the validator calculated. Like the algorithms in
+     *   {@link report}, this leaves out algorithms the validator doesn't
+     *   support, and it's empty if checksums were skipped.
+     * * Error-Count - The number of errors.
+     * * Warning-Count - The number of warnings.
+     * * Checks-Skipped - A comma-separated list of the checks that were
+     *   skipped, including 'checksums' after validateStructure(). This
+     *   tag appears only if some checks were skipped.
+     *
+     * @returns {string}
+     */
+    generateReportTagFile() {
+        let algorithms = this._verifiedAlgorithms();
+        let profileInfo = this.profile ? this.profile.bagItProfileInfo : null;
+        let tags = [
+            ['Validation-Date', dateFormat(Date.now(), 'isoUtcDateTime')],
+            ['Validation-Software', Context.dartVersion()],
+            ['BagIt-Profile-Identifier', profileInfo ? profileInfo.bagItProfileIdentifier : ''],
+            ['BagIt-Version', this.specVersion],
+            ['Validation-Result', this.errors.length == 0 ? 'valid' : 'invalid'],
+            ['Algorithms-Verified', algorithms.join(', ')],
+            ['Error-Count', this.errors.length],
+            ['Warning-Count', this.warnings.length]
+        ];
+        if (this._skippedChecks().length > 0) {
+            tags.push(['Checks-Skipped', this._skippedChecks().join(', ')]);
+        }
+        let lines = tags.map(([tagName, value]) => new TagDefinition({
+            tagName: tagName,
+            userValue: String(value)
+        }).toFormattedString());
+        return lines.join("\n") + "\n";
+    }
+
+    /**
+     * _verifiedAlgorithms returns the sorted list of supported digest
+     * algorithms the validator calculated. This is empty if checksums
+     * were skipped.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {Array<string>}
+     */
+    _verifiedAlgorithms() {
+        if (this._isSkipped('checksums')) {
+            return [];
+        }
+        let supported = BagItFile.digestAlgorithms();
+        return this._digestAlgorithms().filter(alg => supported.includes(alg)).sort();
+    }
+
+    /**
+     * _skippedChecks returns the names of the checks that the current
+     * or most recent run skipped. That's skipChecks, plus 'checksums'
+     * if the run was started by {@link Validator#validateStructure}.
+     *
+     * @returns {string[]}
+     *
+     * @private
+     */
+    _skippedChecks() {
+        return this.skipChecks.concat(this._runSkipChecks.filter(c => !this.skipChecks.includes(c)));
+    }
+
+    /**
+     * _isSkipped returns true if the current run skips the named check.
+     * See {@link Validator#_skippedChecks}.
+     *
+     * @param {string} check - The name of the check.
+     *
+     * @returns {boolean}
+     *
+     * @private
+     */
+    _isSkipped(check) {
+        return this._skippedChecks().includes(check);
+    }
+
+    /**
+     * _meetsConformanceLevel returns true if the bag meets the
+     * requirements of the specified conformance level. See
+     * {@link BagItProfile#conformanceLevels}.
+     *
+     * @param {object} level - A conformance level from the profile.
+     *
+     * @returns {boolean}
+     *
+     * @private
+     */
+    _meetsConformanceLevel(level) {
+        for (let alg of level.manifestsRequired || []) {
+            if (this._manifestFile(`manifest-${alg}.txt`) === undefined) {
+                return false;
+            }
+        }
+        for (let alg of level.tagManifestsRequired || []) {
+            if (this._manifestFile(`tagmanifest-${alg}.txt`) === undefined) {
+                return false;
+            }
+        }
+        for (let tag of level.tags || []) {
+            let tagFile = this.files[tag.tagFile];
+            if (tagFile === undefined || tagFile.keyValueCollection == null) {
+                return false;
+            }
+            let values = tagFile.keyValueCollection.all(tag.tagName);
+            if (values == null || values.every(value => value == '')) {
+                return false;
+            }
+        }
+        return true;
+    }
+
+    /**
+     * _addError records an error that makes the bag invalid. The message
+     * goes into this.errors, and a {@link ValidationError} goes into
+     * this.results.
+     *
+     * @param {string} check - The name of the check that found the error.
+     *
+     * @param {string} message - A description of the error.
+     *
+     * @param {string} [filePath] - The relative path of the file to which
+     * the error applies, if any.
+     *
+     * @param {string} [expected] - For mismatches, the value the validator
+     * expected to find.
+     *
+     * @param {string} [actual] - For mismatches, the value the validator
+     * actually found.
+     *
+     * @returns {ValidationError} - The error that was recorded, or
+     * undefined if the check is skipped.
+     *
+     * @private
+     */
+    _addError(check, message, filePath, expected, actual) {
+        if (this._isSkipped(check)) {
+            return;
+        }
+        this.errors.push(message);
+        let result = new ValidationError({
+            severity: 'error',
+            check: check,
+            filePath: filePath,
+            message: message,
+            expected: expected === undefined ? '' : String(expected),
+            actual: actual === undefined ? '' : String(actual)
+        });
+        this.results.push(result);
+        return result;
+    }
+
+    /**
+     * structuredErrors returns a {@link ValidationError} for each error
+     * the validator found, in the same order as this.errors. Unlike the
+     * strings in this.errors, these say which check found each error and
+     * which file it applies to, so callers can group errors or react to
+     * specific kinds of failure.
+     *
+     * @returns {Array<ValidationError>}
+     */
+    structuredErrors() {
+        return this.results.filter(r => r.severity == 'error');
+    }
+
+    /**
+     * _addWarning records a problem that does not make the bag invalid.
+     * The message goes into this.warnings, and a {@link ValidationError}
+     * goes into this.results.
+     *
+     * @param {string} check - The name of the check that found the problem.
+     *
+     * @param {string} message - A description of the problem.
+     *
+     * @param {string} [filePath] - The relative path of the file to which
+     * the warning applies, if any.
+     *
+     * @private
+     */
+    _addWarning(check, message, filePath) {
+        if (this._isSkipped(check)) {
+            return;
+        }
+        this.warnings.push(message);
+        this.results.push(new ValidationError({
+            severity: 'warning',
+            check: check,
+            filePath: filePath,
+            message: message
+        }));
+    }
+
+    /**
+     * Returns the total number of bytes in the bag's payload files.
+     * This is accurate only after the bag has been read.
+     *
+     * @returns {number}
+     */
+    payloadByteCount() {
+        let byteCount = this._streamedPayloadBytes;
+        for (let f of this.payloadFiles()) {
+            byteCount += Number(f.size);
+        }
+        return byteCount;
+    }
+
+    /**
+     * compressionRatio returns the number of bytes in a compressed bag
+     * after decompression, divided by the size of the compressed file.
+     * Returns null if the bag isn't compressed. This is accurate only
+     * after the bag has been read.
+     *
+     * @returns {number|null}
+     */
+    compressionRatio() {
+        if (!this.readingFromCompressedTar()) {
+            return null;
+        }
+        let compressedSize = fs.statSync(this.pathToBag).size;
+        if (compressedSize == 0) {
+            return 0;
+        }
+        return this._uncompressedByteCount / compressedSize;
+    }
+
+    /**
+     * Returns a reader plugin that is capable of reading the bag we want
+     * to validate. Note that this always returns a new reader, so if you
+     * call it 20 times, you're going to get 20 individual reader objects.
+     *
+     * @returns {Plugin}
+     */
+    getNewReader() {
+        var fileExtension = this.fileExtension();
+        if (this.readingFromStream()) {
+            fileExtension = '.tar';
+        } else if (this.readingFromDir()) {
+            fileExtension = 'directory';
+        }
+        var plugins = PluginManager.canRead(fileExtension);
+        if (!plugins) {
+            throw new Error(`No plugins know how to read ${this.pathToBag}`);
+        }
+        // plugins[0] is a reader plugin (a class) with a constructor
+        // that takes pathToBag as its sole param.
+        var reader = new plugins[0](this.pathToBag);
+        if (this.readingFromStream()) {
+            reader.inputStream = this.inputStream || process.stdin;
+        }
+        return reader;
+    }
+
+    /**
+     * listContents lists the files in the bag, without reading their
+     * contents, calculating checksums, or validating anything. This is
+     * much faster than validate() for callers that only need to know
+     * what's in the bag. It doesn't use the profile, so you can pass a
+     * null profile to the constructor if you only want to list contents.
+     *
+     * This returns a Promise that resolves to a list of
+     * {@link BagItFile} objects, sorted by relative path. Each describes
+     * one payload file, tag file, or manifest, including its size and
+     * fileType. Directories are not included.
+     *
+     * @returns {Promise<Array<BagItFile>>}
+     */
+    listContents() {
+        var validator = this;
+        return new Promise(function(resolve, reject) {
+            var files = [];
+            var reader = validator.getNewReader();
+            reader.on('entry', function(entry) {
+                if (!entry.fileStat.isFile()) {
+                    return;
+                }
+                var relPath = validator._cleanEntryRelPath(entry.relPath);
+                var absPath = '';
+                if (!validator.readingFromArchive()) {
+                    absPath = path.join(validator.pathToBag, relPath);
+                    if (os.platform() === 'win32' && relPath.indexOf("\\") > -1) {
+                        relPath = relPath.replace(/\\/g, '/');
+                    }
+                }
+                files.push(new BagItFile(absPath, relPath, entry.fileStat));
+            });
+            // FileSystemReader emits 'err' and TarReader emits 'error'.
+            reader.on('error', reject);
+            reader.on('err', reject);
+            reader.on('end', function() {
+                resolve(files.sort((a, b) => a.relDestPath.localeCompare(b.relDestPath)));
+            });
+            reader.list();
+        });
+    }
+
+    /**
+     * validate runs all validation operations on the bag specified in the
+     * validator's pathToBag property. This includes:
+     *
+     * * making sure the untarred bag name matches the tarred bag name
+     * * validation checksums for all payload files, and for tag files that have checksums
+     * * ensuring there are no extra or missing payload files
+     * * ensuring that required tag files and manifests are present and valid
+     * * ensuring that required tags are present and, where applicable, have legal values
+     *
+     * This method emits events "start", "task", "stage", "fileProcessed",
+     * "end", and "error".
+     *
+     * The "stage" event receives the name of the stage validation is
+     * entering: 'profile', 'serialization', 'scan', 'readManifests',
+     * 'read', or 'contents'. 'scan' is skipped for streams, and
+     * 'readManifests' happens only in {@link Validator#streamVerifyTar}.
+     *
+     * The "fileProcessed" event fires after the validator has finished
+     * calculating the checksums of a file. It receives the {@link
+     * BagItFile} and the total number of bytes processed so far, so a
+     * user interface can show progress through a large bag.
+     */
+    validate() {
+        this._runSkipChecks = this._structureOnly ? ['checksums'] : [];
+        this._structureOnly = false;
+        this._startTime = Date.now();
+        this.prependOnceListener('end', () => {
+            this._finished = true;
+            this._endTime = Date.now();
+        });
+        this.emit('validateStart', `Validating ${this.pathToBag}`);
+        for (let check of this._skippedChecks()) {
+            this.results.push(new ValidationError({
+                severity: 'skipped',
+                check: check,
+                message: `Skipped ${check} check.`
+            }));
+        }
+        if (!this.readingFromStream() && !fs.existsSync(this.pathToBag)) {
+            let msg = Context.y18n.__('File does not exist at %s', this.pathToBag);
+            this._addError('bagExists', msg);
+            this.emit('error', msg);
+            this.emit('end');
+            return;
+        }
+        this.emit('stage', 'profile');
+        if (!this._validateProfile()) {
+            this.emit('error', this.errors.join(' '));
+            this.emit('end');
+            return;
+        }
+        if (SpecRules[this.specVersion] === undefined) {
+            this._addError('specVersion', `Validator does not know the rules for BagIt version '${this.specVersion}'. Valid versions: ${Object.keys(SpecRules).join(', ')}`);
+            this.emit('error', this.errors.join(' '));
+            this.emit('end');
+            return;
+        }
+        if (!ManifestParser.getParser(this.profile.manifestFormat || 'bagit')) {
+            this._addError('profile', `No manifest parser is registered for format '${this.profile.manifestFormat}'. Registered formats: ${ManifestParser.formats().join(', ')}`);
+            this.emit('error', this.errors.join(' '));
+            this.emit('end');
+            return;
+        }
+        this.emit('stage', 'serialization');
+        if (!this._validateSerialization()) {
+            this.emit('error', this.errors.join(' '));
+            this.emit('end')
+            return;
+        }
+        if (!this._validateContentType()) {
+            this.emit('error', this.errors.join(' '));
+            this.emit('end')
+            return;
+        }
+
+        this.emit('task', new TaskDescription(this.pathToBag, 'start'))
+
+        // Look up allowed values for vocabulary-backed tags, then scan
+        // the bag for manifests. When that completes, it will call
+        // _readBag() to read the contents. Streams can be read only
+        // once, so for those, we skip the scan.
+        var validator = this;
+        this._resolveAllowedValues().then(function() {
+            if (validator._cancelled) {
+                return;
+            }
+            if (validator.readingFromStream()) {
+                validator._readBag();
+            } else {
+                validator._scanBag();
+            }
+        }).catch(function(err) {
+            validator._addError('readError', `Could not read ${validator.pathToBag}: ${err.toString()}`);
+            validator.emit('error', err);
+            if (!validator._finished) {
+                validator.emit('end');
+            }
+        });
+    }
+
+    /**
+     * _resolveAllowedValues asks the valueResolver for the allowed values
+     * of each vocabulary-backed tag in the profile, and caches the results
+     * in this.resolvedValues. If there is no valueResolver, this does
+     * nothing. If the resolver fails for a tag, the validator logs a
+     * warning and falls back to the tag's static values.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _resolveAllowedValues() {
+        var validator = this;
+        this.resolvedValues = {};
+        if (this.valueResolver == null) {
+            return Promise.resolve();
+        }
+        var tagNames = this.profile.tags.filter(t => t.vocabularyBacked).map(t => t.tagName);
+        var promises = [...new Set(tagNames)].map(function(tagName) {
+            return Promise.resolve().then(function() {
+                return validator.valueResolver.allowedValues(tagName);
+            }).then(function(values) {
+                validator.resolvedValues[tagName] = values;
+            }).catch(function(err) {
+                Context.logger.warn(`Validator: Could not resolve allowed values for tag ${tagName}. Using values from profile. ${err}`);
+            });
+        });
+        return Promise.all(promises);
+    }
+
+    /**
+     * _allowedValues returns the list of allowed values for the specified
+     * tag. For vocabulary-backed tags, this is the list the valueResolver
+     * returned, if it returned one. Otherwise, it's the static list of
+     * values in the TagDefinition.
+     *
+     * @param {TagDefinition} tagDef - The tag whose allowed values you
+     * want.
+     *
+     * @returns {string[]}
+     *
+     * @private
+     */
+    _allowedValues(tagDef) {
+        if (tagDef.vocabularyBacked && Array.isArray(this.resolvedValues[tagDef.tagName])) {
+            return this.resolvedValues[tagDef.tagName];
+        }
+        return tagDef.values;
+    }
+
+    /**
+     * cancel stops a validation that is in progress. The validator stops
+     * reading the bag, even in the middle of a large file, adds the
+     * error "Validation was cancelled.", and emits its end event. It
+     * does not run any more checks, so the other errors and warnings
+     * are incomplete, and the bag should be considered invalid.
+     *
+     * Calling this after validation has ended has no effect.
+     *
+     */
+    cancel() {
+        if (this._cancelled || this._finished) {
+            return;
+        }
+        this._cancelled = true;
+        if (this._reader != null && typeof this._reader.stop === 'function') {
+            this._reader.stop();
+        }
+        if (this._currentReadStream != null) {
+            this._currentReadStream.unpipe();
+            this._currentReadStream.destroy();
+        }
+        this._addError('cancelled', 'Validation was cancelled.');
+        this.emit('end');
+    }
+
+    /**
+     * reset clears the results of the last validation, so the validator
+     * can validate another bag. Settings, such as skipChecks and
+     * inventory, are kept, and so are event listeners. Everything the
+     * validator learned about the last bag, including its files,
+     * errors, warnings, and checkpoint, is discarded. Since a stream can
+     * be read only once, this also clears inputStream.
+     *
+     * This throws an exception if validation is in progress. Wait for
+     * the end event, or call cancel() first.
+     *
+     * @param {string} [pathToBag] - The path to the next bag to
+     * validate. This defaults to the path of the last bag.
+     *
+     */
+    reset(pathToBag) {
+        if (this._startTime != null && !this._finished) {
+            throw new Error(`Cannot reset validator while it is validating ${this.pathToBag}.`);
+        }
+        let fresh = new Validator(pathToBag || this.pathToBag, this.profile);
+        let emitterProperties = Object.keys(new EventEmitter());
+        for (let key of Object.keys(fresh)) {
+            if (bagProperties.includes(key) || (key.startsWith('_') && !emitterProperties.includes(key))) {
+                this[key] = fresh[key];
+            }
+        }
+    }
+
+    /**
+     * validateStructure checks whether the bag is well-formed without
+     * verifying payload checksums. It runs all of the same checks as
+     * {@link Validator#validate}, including checks on serialization,
+     * required manifests and tag files, and tag values, but it doesn't
+     * calculate any checksums, and it doesn't read payload files.
+     * Manifests are still parsed, so files missing from the bag are
+     * still reported.
+     *
+     * This is much faster than validate() for large bags, but it can't
+     * catch payload files that are corrupt or altered. Results include
+     * a skipped 'checksums' check, so reports show that payload
+     * checksums were not verified. This doesn't change skipChecks, so
+     * a later call to validate() verifies checksums as usual.
+     *
+     * Like validate(), this emits events "start", "task", "end", and
+     * "error".
+     */
+    validateStructure() {
+        this._structureOnly = true;
+        this.validate();
+    }
+
+    /**
+     * validateStreamingTo validates the bag, and writes the relative
+     * path of each payload file to passStream or failStream as soon as
+     * the validator knows whether its checksums match the payload
+     * manifests. Each path is followed by a newline. This lets you pipe
+     * the lists into other tools without waiting for validation to
+     * finish.
+     *
+     * A payload file passes if every payload manifest lists it with a
+     * matching digest. If a file's checksums are ready before the
+     * manifests have been parsed, the validator holds on to it until
+     * they have been. Files that the manifests list but that are
+     * missing from the bag go to failStream when validation ends.
+     *
+     * This does not end either stream, since the caller may want to
+     * keep writing to them. It stops writing to them when validation
+     * ends, so a validator that's reset and reused won't write the
+     * next bag's files to them.
+     *
+     * @param {stream.Writable} passStream - Receives the paths of files
+     * whose checksums match.
+     *
+     * @param {stream.Writable} failStream - Receives the paths of files
+     * that are missing, unlisted, or whose checksums don't match.
+     *
+     * @returns {Promise<boolean>} Resolves to true if the bag is valid.
+     * Rejects if the validator emits an error, such as when it can't
+     * read the bag.
+     */
+    validateStreamingTo(passStream, failStream) {
+        let validator = this;
+        let pending = [];
+        let flush = function(final) {
+            pending = pending.filter(function(bagItFile) {
+                let passed = validator._payloadFileVerdict(bagItFile, final);
+                if (passed === null) {
+                    return true;
+                }
+                (passed ? passStream : failStream).write(bagItFile.relDestPath + '\n');
+                return false;
+            });
+        };
+        let onFileProcessed = function(bagItFile) {
+            if (bagItFile.isPayloadFile()) {
+                pending.push(bagItFile);
+            }
+            flush(false);
+        };
+        this.on('fileProcessed', onFileProcessed);
+        return new Promise(function(resolve, reject) {
+            let onError = function(err) {
+                validator.removeListener('fileProcessed', onFileProcessed);
+                validator.removeListener('end', onEnd);
+                reject(err instanceof Error ? err : new Error(err));
+            };
+            let onEnd = function() {
+                validator.removeListener('fileProcessed', onFileProcessed);
+                validator.removeListener('error', onError);
+                flush(true);
+                let fetched = validator._fetchFilenames();
+                let missing = new Set();
+                for (let manifest of validator.payloadManifests()) {
+                    if (manifest.keyValueCollection == null) {
+                        continue;
+                    }
+                    for (let relPath of manifest.keyValueCollection.keys()) {
+                        if (!validator._hasFile(relPath) && !fetched.has(relPath)) {
+                            missing.add(relPath);
+                        }
+                    }
+                }
+                for (let relPath of [...missing].sort()) {
+                    failStream.write(relPath + '\n');
+                }
+                resolve(validator.errors.length == 0);
+            };
+            validator.once('end', onEnd);
+            validator.once('error', onError);
+            validator.validate();
+        });
+    }
+
+    /**
+     * validateAndExtract validates a tarred or zipped bag and extracts it
+     * into destDir in the same pass, so the archive is read only once.
+     * Each file is written to destDir/<bag name>/<relative path> as the
+     * validator calculates its checksums. The validator refuses to write
+     * any file whose path would put it outside the bag's directory, and
+     * records an 'extract' error for it instead.
+     *
+     * Files are extracted before the validator knows whether the bag is
+     * valid. If the Promise resolves to false, the caller should delete
+     * the extracted bag. Empty directories are not extracted.
+     *
+     * Like validate(), this emits events "start", "task", "end", and
+     * "error".
+     *
+     * @param {string} destDir - The directory into which to extract the
+     * bag.
+     *
+     * @returns {Promise<boolean>} Resolves to true if the bag is valid,
+     * after all files have been written. Rejects if the bag is not
+     * serialized, if a file can't be written, or if the validator emits
+     * an error, such as when the archive is truncated.
+     */
+    validateAndExtract(destDir) {
+        if (!this.readingFromArchive()) {
+            return Promise.reject(new Error(`validateAndExtract() can only extract tarred or zipped bags, and ${this.pathToBag} is not serialized.`));
+        }
+        let validator = this;
+        this._extractTo = path.resolve(destDir);
+        return new Promise(function(resolve, reject) {
+            let onError = function(err) {
+                validator.removeListener('end', onEnd);
+                reject(err instanceof Error ? err : new Error(err));
+            };
+            let onEnd = function() {
+                validator.removeListener('error', onError);
+                Promise.all(validator._extractions).then(function() {
+                    resolve(validator.errors.length == 0);
+                }, reject);
+            };
+            validator.once('end', onEnd);
+            validator.once('error', onError);
+            validator.validate();
+        });
+    }
+
+    /**
+     * _payloadFileVerdict returns true if every payload manifest lists
+     * bagItFile with a digest that matches the one the validator
+     * calculated, and false if any manifest omits it or has a different
+     * digest. If a manifest hasn't been fully parsed yet, this returns
+     * null, meaning the answer isn't known yet, unless final is true.
+     * After validation ends, all manifests have been parsed, and a
+     * file with no manifests fails.
+     *
+     * @param {BagItFile} bagItFile - A payload file whose checksums
+     * have been calculated.
+     *
+     * @param {boolean} final - True if validation has ended.
+     *
+     * @returns {boolean|null}
+     *
+     * @private
+     */
+    _payloadFileVerdict(bagItFile, final) {
+        if (this._unreadableFiles[bagItFile.relDestPath]) {
+            return false;
+        }
+        let algorithms = this.manifestAlgorithmsFoundInBag;
+        if (algorithms.length == 0) {
+            return final ? false : null;
+        }
+        for (let algorithm of algorithms) {
+            let name = `manifest-${algorithm}.txt`;
+            if (!final && this._manifestEntries[name] === undefined) {
+                return null;
+            }
+            let manifest = this._manifestFile(name);
+            if (manifest === undefined || manifest.keyValueCollection == null) {
+                return false;
+            }
+            let expected = this._manifestDigest(manifest, bagItFile.relDestPath);
+            if (!expected || !this._digestsMatch(expected, bagItFile.checksums[algorithm])) {
+                return false;
+            }
+        }
+        return true;
+    }
+
+    /**
+     * streamVerifyTar validates a tarred bag the same way validate() does,
+     * but it verifies each payload file's checksums against the payload
+     * manifests as the file streams out of the tarball, rather than
+     * storing every payload checksum until the end of validation. Once
+     * a payload file has been verified, the validator drops it from
+     * this.files, and keeps only its path and a running count of
+     * payload bytes for the Payload-Oxum, payload size, and manifest
+     * checks. This keeps memory use low for bags with very large
+     * numbers of files.
+     *
+     * To make that possible, the validator reads the tar file one extra
+     * time to collect the manifests before it reads the payload. Errors
+     * are the same as those produced by validate(), though checksum
+     * errors may appear in a different order.
+     *
+     * After validation, this.files and payloadFiles() will not include
+     * payload files, though payloadByteCount() and {@link report} still
+     * count them. Tag files and manifests keep their checksums.
+     *
+     * This method emits the same events as validate().
+     */
+    streamVerifyTar() {
+        if (!this.readingFromTar()) {
+            let msg = `streamVerifyTar() can only validate tarred bags, and ${this.pathToBag} is not a tar file.`;
+            this._addError('streamVerify', msg);
+            this.emit('error', msg);
+            this.emit('end');
+            return;
+        }
+        this._streamVerify = true;
+        this.validate();
+    }
+
+    /**
+     * resumeValidate validates the bag the same way validate() does, but
+     * trusts the checksums in checkpoint for any file whose size and
+     * modification time haven't changed since the checkpoint recorded
+     * it. Those files are not hashed again. Everything else, including
+     * manifest comparisons and tag checks, runs as usual.
+     *
+     * The validator continues to record its progress in checkpoint, so
+     * if this validation is interrupted too, you can resume again.
+     *
+     * This method emits the same events as validate().
+     *
+     * @param {ValidationCheckpoint} checkpoint - A checkpoint recorded
+     * by an earlier, interrupted validation of the same bag.
+     */
+    resumeValidate(checkpoint) {
+        if (checkpoint.pathToBag != this.pathToBag) {
+            let msg = `Checkpoint is for ${checkpoint.pathToBag}, not ${this.pathToBag}.`;
+            this._addError('checkpoint', msg);
+            this.emit('error', msg);
+            this.emit('end');
+            return;
+        }
+        this.checkpoint = checkpoint;
+        this.validate();
+    }
+
+    /**
+     * This method does an initial scan of the bag to see what manifests
+     * are present. While some BagItProfiles specify that a manifest
+     * with algorithm X must be present, the profile does preclude other
+     * manifests with different algorithms also being present. It's
+     * common among APTrust bags, for example, to find both md5 and sha256
+     * manifests. The validator does an initial scan of the bag to find
+     * extra manifests so it knows which checksums to run on payload and
+     * tag files.
+     *
+     * If an initial scan reveals manifest-md5.txt and manifest-sha256.txt,
+     * the manifestAlgorithmsFoundInBag will contain ["md5", "sha256"].
+     *
+     * The most common reason for finding multiple manifests in a bag
+     * comes from institutions that internally require one checksumming
+     * algorithm, and who have to produce bags whose spec requires a
+     * different algorithm.
+     *
+     * The validator will later validate ALL checksums, even those found
+     * in manifests that are not part of the {@link BagItProfile}.
+     *
+     */
+    _scanBag() {
+        var validator = this;
+        this.emit('stage', 'scan');
+        var reader = this.getNewReader();
+        this._reader = reader;
+        reader.on('error', function(err) {
+            validator.emit('error', err);
+        });
+        reader.on('entry', function (entry) {
+            validator._scanEntry(entry);
+        });
+        reader.on('end', function() {
+            if (validator._cancelled) {
+                return;
+            }
+            if (validator._streamVerify) {
+                validator._readManifests();
+            } else {
+                validator._readBag();
+            }
+        });
+
+        // List the contents of the bag.
+        reader.list();
+    }
+
+    /**
+     * _scanEntry counts an entry found while scanning the bag, and
+     * notes the algorithm of any manifest or tag manifest. When reading
+     * from a tar file, this also notes the name of the bag's top-level
+     * directory. When reading from a stream, there is no separate scan,
+     * so _readBag() calls this for each entry.
+     *
+     * @param {object} entry - An entry returned by a reader plugin.
+     *
+     * @private
+     */
+    _scanEntry(entry) {
+        this._initialFileCount += 1;
+        if (this.readingFromArchive()) {
+            this._topLevelEntries.add(entry.relPath.split(/\//)[0]);
+        }
+        if (this.bagRoot == null && this.readingFromArchive()) {
+            this.bagRoot = entry.relPath.split(/\//)[0];
+            if (this.readingFromStream()) {
+                this.bagName = this.bagRoot;
+            }
+        }
+        var relPath = this._cleanEntryRelPath(entry.relPath);
+        if (relPath.match(Constants.RE_MANIFEST) || relPath.match(Constants.RE_TAG_MANIFEST) || relPath.match(Constants.RE_SPLIT_MANIFEST)) {
+            var algorithm = relPath.split('-')[1].split('.')[0];
+            var list = relPath.startsWith('manifest-') ? this.manifestAlgorithmsFoundInBag : this.tagManifestAlgorithmsFoundInBag;
+            if (!BagItFile.digestAlgorithms().includes(algorithm)) {
+                this._unsupportedManifests.push(relPath);
+            } else if (!list.includes(algorithm)) {
+                list.push(algorithm);
+            }
+        }
+    }
+
+    /**
+     * This method is used only by streamVerifyTar(). It reads and parses
+     * the payload manifests and tag manifests, skipping over all other
+     * files, so that the checksums in the manifests are available when
+     * _readBag() reads the payload. When reading is complete, this calls
+     * _readBag().
+     *
+     * @private
+     */
+    _readManifests() {
+        var validator = this;
+        this.emit('stage', 'readManifests');
+        var reader = this.getNewReader();
+        this._reader = reader;
+        reader.on('entry', function (entry) {
+            var relPath = validator._cleanEntryRelPath(entry.relPath);
+            if (entry.fileStat.isFile() && (relPath.match(Constants.RE_MANIFEST) || relPath.match(Constants.RE_TAG_MANIFEST) || relPath.match(Constants.RE_SPLIT_MANIFEST))) {
+                validator._readEntry(entry);
+            } else {
+                entry.stream.resume();
+            }
+        });
+        reader.on('error', function(err) { validator.emit('error', err) });
+        reader.on('end', function() {
+            // Wait for the manifest parsers and checksums to finish.
+            // See the comment in _readBag().
+            let hashInterval = setInterval(() => {
+                if (validator._cancelled) {
+                    clearInterval(hashInterval);
+                } else if (validator._hashesInProgress === 0) {
+                    clearInterval(hashInterval);
+                    validator._mergeSplitManifests();
+                    validator._readBag();
+                }
+            }, 50);
+        });
+        reader.read();
     }
 
     /**
@@ -350,535 +2768,2547 @@ class Validator extends EventEmitter {
      * in the callbacks. When reading is complete, this calls
      * _validateFormatAndContents()
      *
+     * If _readManifests() has already read the manifests, this skips them.
+     *
+     */
+    _readBag() {
+        // Attach listeners to our reader.
+        var validator = this;
+        this.emit('stage', 'read');
+        var reader = this.getNewReader();
+        this._reader = reader;
+        reader.on('entry', function (entry) {
+            if (validator.readingFromStream()) {
+                validator._scanEntry(entry);
+            }
+            if (entry.fileStat.isFile()) {
+                validator._regularFileEntries += 1;
+                let relPath = validator._cleanEntryRelPath(entry.relPath);
+                validator._entryCounts[relPath] = (validator._entryCounts[relPath] || 0) + 1;
+            }
+            if (validator._streamVerify && entry.fileStat.isFile() && validator.files[validator._cleanEntryRelPath(entry.relPath)]) {
+                entry.stream.resume();
+                return;
+            }
+            validator._readEntry(entry);
+        });
+        reader.on('error', function(err) { validator.emit('error', err) });
+
+        // Once reading is done, validate all the info we've gathered.
+        reader.on('end', function() {
+            if (validator.readingFromCompressedTar()) {
+                validator._uncompressedByteCount = reader.tarByteCount;
+            }
+            // Is this really what we want to emit here?
+            validator.emit('task', new TaskDescription(validator.pathToBag, 'read'))
+            // FileSystemReader emits end event while streamreader is
+            // still piping file contents to our hashing algorithms. We want
+            // those hash pipes to complete before validating checksums in
+            // _validateFormatAndContents(), so we don't wind up with
+            // undefined checksums. The lag time between end event and the
+            // completion of checksums is usually ~5ms. Not sure of a better
+            // way to do this, given that we have an unknown number of pipes
+            // on each file. Don't want to go full async with promises, because
+            // we don't want 10,000 open file handles. So we have this, which
+            // is like a WaitGroup counter in Go or a CountDownLatch in
+            // Java. We check every 50ms to see if it has reached zero. At
+            // zero, we know all the checksums have completed.
+            let hashInterval = setInterval(() => {
+                if (validator._cancelled) {
+                    clearInterval(hashInterval);
+                } else if (validator._hashesInProgress === 0) {
+                    clearInterval(hashInterval);
+                    // Wait for the fixity registry and the signature
+                    // verifier, if there are any.
+                    Promise.all(validator._fixityChecks).then(function() {
+                        return validator._verifySignatures();
+                    }).then(function() {
+                        validator._validateFormatAndContents();
+                    });
+                }
+            }, 50);
+        });
+
+        // Read the contents of the bag.
+        reader.read();
+    }
+
+    /**
+     * _validateFormatAndContents is called internally by the public validate()
+     * method. While validate() reads the contents of the bag, parses manifests
+     * and tag files, this method compares the info in the bag to what the
+     * {@link BagItProfile} says is valid.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateFormatAndContents() {
+        if (this._cancelled) {
+            return;
+        }
+        this.emit('stage', 'contents');
+        this._mergeSplitManifests();
+        var okToProceed = this._validateUntarDirectory();
+        if (okToProceed) {
+            this._decodeTagFiles();
+            this._resolveBlobMap();
+            this._validateEntryCount();
+            this._validateDuplicateEntries();
+            this._validateCompressionRatio();
+            this._validateRequiredManifests(Constants.PAYLOAD_MANIFEST);
+            this._validateRequiredManifests(Constants.TAG_MANIFEST);
+            this._validateAllowedManifests(Constants.PAYLOAD_MANIFEST);
+            this._validateAllowedManifests(Constants.TAG_MANIFEST);
+            this._validateAllowedTagFiles();
+            this._validatePayloadPaths();
+            this._validatePayloadDirectory();
+            this._validateFetch();
+            this._validateManifestAlgorithmsSupported();
+            this._validateManifestCounts();
+            this._validateManifestEntries(Constants.PAYLOAD_MANIFEST);
+            this._validateManifestEntries(Constants.TAG_MANIFEST);
+            this._validateManifestDuplicates();
+            this._validateManifestOrder();
+            this._validateDigestCase();
+            this._validateAlgorithmStrength();
+            this._validateAlgorithmPolicy();
+            this._validateTagFileAlgorithms();
+            this._validateTagManifestAlgorithms();
+            this._validateManifestAlgorithmTag();
+            this._validateFixityRegistry();
+            this._validateSignatures();
+            this._validateNoExtraneousPayloadFiles();
+            this._validateManifestsAgree();
+            this._validateInventory();
+            this._validatePayloadOxum();
+            this._validatePayloadSize();
+            this._validateMetadataSize();
+            this._validateNoEmptyDirectories();
+            this._validateWindowsPortability();
+            this._validatePathDepth();
+            this._validateCaseCollisions();
+            this._validatePayloadMimeTypes();
+            this._validateChangeManifests();
+            this._validateTombstones();
+            this._validateByteOrderMark();
+            this._validateDeclaredVersion();
+            this._validateDeclaredEncoding();
+            this._validateTagFileFormat();
+            this._validateRequiredTagFilesParse();
+            this._validateTags();
+            this._validateSourceOrganization();
+            this._validateTagOrder();
+            this._validateNoControlCharacters();
+        }
+        this.emit('end')
+    }
+
+    /**
+     * _validateSerialization checks to see whether or not the bag is
+     * in a format that adheres to the profile's serialization rules.
+     * The profile's serialization attribute is one of
+     * {@link Constants.REQUIREMENT_OPTIONS}:
+     *
+     * * required - The bag MUST be serialized in one of the formats in
+     *   acceptSerialization. A directory is invalid.
+     * * forbidden - The bag MUST be a directory.
+     * * optional - The bag may be a directory, or it may be serialized
+     *   in one of the formats in acceptSerialization. If
+     *   acceptSerialization is empty, no serialized bag is valid.
+     *
+     * The validator checks the profile before it gets here, so
+     * serialization should always be one of these values. If it's
+     * anything else, this adds an error rather than guessing.
+     * {@link BagItProfile#validate} requires at least one accepted
+     * format only when serialization is required. An optional profile
+     * with no accepted formats accepts only directories.
+     *
+     * If the profile has a requiredSerialization, a serialized bag MUST
+     * be in that format, even if acceptSerialization lists others.
+     *
+     * You can disable this check by setting
+     * Validator.disableSerializationCheck to true. You would want to do
+     * that in cases where you've built a bag and want to validate it
+     * before you tar or zip it. Adding 'serialization' to
+     * Validator.skipChecks has the same effect.
+     *
+     * @returns {boolean} entry - True if profile is valid, false if not.
+     *
+     */
+    _validateSerialization() {
+        if (this.disableSerializationCheck || this._isSkipped('serialization')) {
+            Context.logger.info(`Validator: Skipping validation of serialization format.`);
+            return true;
+        }
+        var bagIsDirectory = !this.readingFromStream() && fs.statSync(this.pathToBag).isDirectory();
+        switch (this.profile.serialization) {
+            case 'required':
+                if (bagIsDirectory) {
+                    this._addError('serialization', Context.y18n.__("Profile says bag must be serialized, but it is a directory."));
+                    return false;
+                }
+                break;
+            case 'forbidden':
+                if (!bagIsDirectory) {
+                    this._addError('serialization', Context.y18n.__("Profile says bag must not be serialized, but bag is not a directory."));
+                    return false;
+                }
+                break;
+            case 'optional':
+                // Directories are always fine, and serialized bags
+                // must be in an accepted format.
+                break;
+            default:
+                this._addError('serialization', Context.y18n.__("Profile has unknown serialization value '%s'. It must be required, optional, or forbidden.", this.profile.serialization));
+                return false;
+        }
+        if (bagIsDirectory) {
+            return true;
+        }
+        var ext = this.readingFromStream() ? '.tar' : path.extname(this.pathToBag);
+        if (this.profile.acceptSerialization.length == 0) {
+            this._addError('serialization', Context.y18n.__("Bag has extension %s, but profile does not accept any serialization formats.", ext));
+            return false;
+        }
+        if (!this._validateSerializationFormat()) {
+            this._addError('serialization', Context.y18n.__("Bag has extension %s, but profile says it must be serialized as of one of the following types: %s.", ext, this.profile.acceptSerialization.join(', ')));
+            return false;
+        }
+        if (!this._validateRequiredSerialization()) {
+            this._addError('serialization', Context.y18n.__("Bag has extension %s, which the profile accepts, but the profile requires bags to be serialized as %s.", ext, this.profile.requiredSerialization));
+            return false;
+        }
+        return true;
+    }
+
+    /**
+     * _validateContentType checks that the content of a serialized bag
+     * matches its file extension. For example, that a file ending in
+     * .tar is actually a tar file and not a zip file. Readers can't make
+     * sense of files in the wrong format, so without this check, the
+     * validator would report confusing errors about unreadable entries.
+     *
+     * This identifies formats by their magic numbers, and reports a
+     * mismatch only if it recognizes the content as some other format.
+     * It does not check directories, or files whose extensions it
+     * doesn't know.
+     *
+     * @returns {boolean} - True if the content matches the extension,
+     * or if it can't tell.
+     *
+     */
+    _validateContentType() {
+        let ext = path.extname(this.pathToBag).toLowerCase();
+        let expectedFormat = formatForExtension[ext];
+        if (this.readingFromStream() || this.readingFromDir() || expectedFormat === undefined) {
+            return true;
+        }
+        let actualFormat = this._sniffFormat();
+        if (actualFormat != null && actualFormat != expectedFormat) {
+            this._addError('contentType', `Extension/content mismatch: ${path.basename(this.pathToBag)} has extension ${ext}, but its content is in ${actualFormat} format.`);
+            return this._isSkipped('contentType');
+        }
+        return true;
+    }
+
+    /**
+     * _sniffFormat returns the name of the format of the serialized bag,
+     * based on its magic number. See magicNumbers above for the formats
+     * this can identify. Returns null if the format isn't one of those.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _sniffFormat() {
+        let header = Buffer.alloc(512);
+        let fd = fs.openSync(this.pathToBag, 'r');
+        let bytesRead = 0;
+        try {
+            bytesRead = fs.readSync(fd, header, 0, header.length, 0);
+        } finally {
+            fs.closeSync(fd);
+        }
+        for (let magic of magicNumbers) {
+            let end = magic.offset + magic.bytes.length;
+            if (end <= bytesRead && header.slice(magic.offset, end).equals(magic.bytes)) {
+                return magic.format;
+            }
+        }
+        return null;
+    }
+
+    /**
+     * _validateSerializationFormat checks to see if the bag is in an allowed
+     * serialized format. This is called only if necessary.
+     *
+     * @returns {boolean} entry - True if format is valid, false if not.
+     *
+     */
+    _validateSerializationFormat() {
+        if (this.readingFromStream()) {
+            return this.profile.acceptSerialization.includes('application/tar');
+        }
+        var matchesValidExtension = false;
+        for (var mimetype of this.profile.acceptSerialization) {
+            var extensionRegex = Constants.SERIALIZATION_FORMATS[mimetype];
+            if (extensionRegex !== undefined && this.pathToBag.match(extensionRegex)) {
+                matchesValidExtension = true;
+            }
+        }
+        return matchesValidExtension;
+    }
+
+    /**
+     * _validateRequiredSerialization checks to see if the bag is in the
+     * one serialization format the profile requires, if the profile has
+     * a requiredSerialization. This is called only if necessary.
+     *
+     * @returns {boolean} entry - True if format is valid, false if not.
+     *
+     */
+    _validateRequiredSerialization() {
+        if (!this.profile.requiredSerialization) {
+            return true;
+        }
+        if (this.readingFromStream()) {
+            return this.profile.requiredSerialization == 'application/tar';
+        }
+        var extensionRegex = Constants.SERIALIZATION_FORMATS[this.profile.requiredSerialization];
+        return extensionRegex !== undefined && this.pathToBag.match(extensionRegex) != null;
+    }
+
+    /**
+     * _validateProfile validates the BagItProfile that will be used to
+     * validate the bag. If the profile itself is not valid, we can't proceed.
+     *
+     * Errors in the BagItProfile will be copied into the validator.errors
+     * list.
+     *
+     * @returns {boolean} entry - True if profile is valid, false if not.
+     *
+     */
+    _validateProfile() {
+        if (this.profile == null) {
+            this._addError('profile', Context.y18n.__("Cannot validate bag because BagItProfile is missing."));
+            return false;
+        }
+        if (!this.profile.validate()) {
+            for (let err of Object.values(this.profile.errors)) {
+                this._addError('profile', `BagItProfile: ${err}`);
+            }
+            return false;
+        }
+        return true;
+    }
+
+    /**
+     * _readEntry reads a single entry from a TarReader or FileSystemReader.
+     * An entry represents one file within the bag (any type of file: payload,
+     * manifest, tag manifest, or tag file). This method add's the file's metadata
+     * to the Validator.files hash, computes the file's checksums, and parses the
+     * file's contents if necessary.
+     *
+     * @param {object} entry - An entry returned by a TarReader or FileSystemReader.
+     *
+     */
+    _readEntry(entry) {
+        let validator = this;
+        if (entry.fileStat.isFile()) {
+            var bagItFile = this._addBagItFile(entry);
+            if (Context.slowMotionDelay > 0) {
+                setTimeout(() => {
+                    validator._readFile(bagItFile, entry.stream)
+                }, Context.slowMotionDelay);
+            } else {
+                this._readFile(bagItFile, entry.stream);
+            }
+        } else if (entry.fileStat.isDirectory()) {
+            var relPath = this._cleanEntryRelPath(entry.relPath);
+            if (relPath == 'data') {
+                this._payloadDirectoryFound = true;
+            }
+            if (this.bagRoot == null && relPath == '') {
+                this.bagRoot = entry.relPath.replace(/\/$/, ''); // right relpath for untarring
+            } else if (this.bagRoot == null && relPath == entry.relPath.replace(/\/$/, '')) {
+                this.bagRoot = relPath; // wrong relpath for untarring
+            }
+            entry.stream.pipe(new stream.PassThrough());
+        } else {
+            // Skip symlinks, block devices, etc.
+            entry.stream.pipe(new stream.PassThrough());
+            Context.logger.info(`Validator: ${entry.relPath} as is not a regular file`);
+        }
+    }
+
+    /**
+     * _addBagItFile adds a BagItFile to the Validator.files hash, based on
+     * the entry it receives from the reader. At this point, the newly created
+     * BagItFile will have its path and stats info, but no parsed data or
+     * checksums.
+     *
+     * @param {object} entry - An entry returned by a TarReader or FileSystemReader.
+     *
+     * @param {string} entry.relPath - The relative path of the file inside the bag.
+     *
+     * @param {FileStat|fs.Stat} entry.fileStat - An object containing stats info
+     * about the file.
+     *
+     * @returns {BagItFile} The BagItFile created from the entry object.
+     *
+     */
+    _addBagItFile(entry) {
+        this.emit('task', new TaskDescription(entry.relPath, 'add'));
+        var relPath = this._cleanEntryRelPath(entry.relPath);
+        var absPath = '';
+        if (!this.readingFromArchive()) {
+            absPath = path.join(this.pathToBag, relPath);
+            if (os.platform() === 'win32' && relPath.indexOf("\\") > -1) {
+                relPath = relPath.replace(/\\/g, '/');
+            }
+        }
+        var bagItFile = new BagItFile(absPath, relPath, entry.fileStat);
+        this.files[relPath] = bagItFile;
+        var fileType = BagItFile.getFileType(relPath);
+        //Context.logger.info(`Validator added ${relPath} as ${fileType}`);
+        return bagItFile;
+    }
+
+    /**
+     * _cleanEntryRelPath removes trailing slashes from relPath. When the
+     * validator is reading from a tar or zip file, this also removes the
+     * leading bag name from the path. Since serialized bags must extract
+     * to a directory whose name matches the bag, relative paths within
+     * tar and zip files will always be prefixed with the bag name. To get
+     * a true relative path, we have to change "bagname/data/file.txt" to
+     * "data/file.txt".
+     *
+     * @param {string} relPath - The relative path, as we got it from the
+     * TarReader, ZipReader, or FileSystemReader.
+     *
+     * @returns {string} A clean version of the relative path.
+     *
+     */
+    _cleanEntryRelPath(relPath) {
+        var cleanPath = relPath;
+        if (this.readingFromArchive()) {
+            var archiveName = Validator.bagNameFromPath(this.pathToBag);
+            if (this.readingFromStream()) {
+                // Streams have no file name, so use the name of the
+                // top-level directory.
+                archiveName = this.bagRoot;
+            }
+            // Compare the prefix as a string. Bag names often contain
+            // dots and may contain other characters that have special
+            // meaning in a regular expression.
+            if (cleanPath.startsWith(archiveName + '/')) {
+                cleanPath = cleanPath.slice(archiveName.length + 1);
+            }
+        }
+        return cleanPath.replace(/\/$/, '');
+    }
+
+    /**
+     * _readFile pushes the file's bits through whatever checksum algorithms
+     * the {@link BagItProfile} says we're supposed to validate. It also parses
+     * the contents of the file, if it happens to be a manifest, tag manifest,
+     * or text-based tag file.
+     *
+     * Note that the read() method of TarReader and FileSystemReader will not
+     * advance until we've read the entire stream (or until it closes or errors out).
+     *
+     * @param {BagItFile} bagItFile - A file inside the directory or tarball.
+     *
+     * @param {ReadStream} readStream - A stream from which we can read
+     * the file's contents.
+     */
+    _readFile(bagItFile, readStream) {
+        var validator = this;
+        this._filesChecked += 1;
+        // When reading from a stream, we don't know the file count
+        // until we reach the end.
+        let percentComplete = this.readingFromStream() ? 0 : (this._filesChecked / this._initialFileCount) * 100;
+        this.emit('task', new TaskDescription(bagItFile.relDestPath, 'checksum', '', percentComplete));
+
+        // Get pipes for all of the hash digests we'll need to calculate.
+        // We need to calculate checksums on everything in the bag.
+        // If the checkpoint already has this file's checksums, we
+        // don't need to calculate them again.
+        // When skipping checksums, we don't hash anything.
+        var skipHashes = this._isSkipped('checksums');
+        var pipes = [];
+        if (skipHashes || this._restoreFromCheckpoint(bagItFile)) {
+            this._fileProcessed(bagItFile);
+        } else {
+            pipes = this._getCryptoHashes(bagItFile);
+        }
+
+        // For manifests, tag manifests, and tag files, we need to parse
+        // file contents as well.
+        if (bagItFile.isPayloadManifest() || bagItFile.isTagManifest()) {
+            var Parser = ManifestParser.getParser(this.profile.manifestFormat || 'bagit');
+            var manifestParser = new Parser(bagItFile);
+            manifestParser.stream.on('end', function() {
+                validator._manifestEntries[bagItFile.relDestPath] = manifestParser.entries;
+            });
+            pipes.push(manifestParser.stream);
+        } else if (bagItFile.isTagFile() && bagItFile.relDestPath.endsWith(".txt")) {
+            // bagit.txt always uses the standard delimiter.
+            var delimiter = ':';
+            if (bagItFile.relDestPath != 'bagit.txt') {
+                delimiter = this.profile.tagDelimiter || ':';
+            }
+            var tagFileParser = new TagFileParser(bagItFile, delimiter);
+            tagFileParser.stream.on('end', function() {
+                validator._tagFileParseErrors[bagItFile.relDestPath] = tagFileParser.errors;
+            });
+            pipes.push(tagFileParser.stream);
+            pipes.push(this._getTagFileCollector(bagItFile));
+        }
+        if (bagItFile.relDestPath == 'bagit.txt') {
+            pipes.push(this._getByteOrderMarkDetector());
+        }
+        if (this.allowedPayloadMimeTypes.length > 0 && bagItFile.isPayloadFile()) {
+            pipes.push(this._getMimeTypeDetector(bagItFile));
+        }
+        if (this._extractTo != null) {
+            let extractor = this._getExtractor(bagItFile);
+            if (extractor != null) {
+                pipes.push(extractor);
+            }
+        }
+        if (this.signatureVerifier != null && (bagItFile.isPayloadManifest() || bagItFile.isTagManifest() || BagItFile.isSignature(bagItFile.relDestPath))) {
+            pipes.push(this._getSignatureCollector(bagItFile));
+        }
+
+        // Push read errors up to where the user can see them. Streams
+        // don't end their pipes on error, so end them here. Otherwise,
+        // the hashes for this file would never finish, and validation
+        // would never get past this file.
+        readStream.on('error', function(err) {
+            if (validator._unreadableFiles[bagItFile.relDestPath]) {
+                return;
+            }
+            validator._addError('readError', `Read error in ${bagItFile.relDestPath}: ${err.toString()}`, bagItFile.relDestPath);
+            validator._unreadableFiles[bagItFile.relDestPath] = true;
+            for (let p of pipes) {
+                readStream.unpipe(p);
+                p.end();
+            }
+        });
+
+        // Now we can do a single read of the file, piping it through
+        // streams for checksum calculations and parsing. This is much
+        // more efficient than doing a seperate read for each, especially
+        // in bags that use multiple digest algorithms.
+        if (pipes.length == 0) {
+            // Nothing needs this file's contents. A file on disk can
+            // be closed without reading it. Entries in a tar or zip
+            // file must be drained before the reader can move on.
+            if (this.readingFromDir()) {
+                readStream.destroy();
+            } else {
+                readStream.resume();
+            }
+            return;
+        }
+        this._currentReadStream = readStream;
+        readStream.pause();
+        for (var p of pipes) {
+            readStream.pipe(p);
+        }
+        readStream.resume();
+        //Context.logger.info(`Validator is running checksums for ${bagItFile.relDestPath}`);
+    }
+
+    /**
+     * _fileProcessed adds the size of bagItFile to the count of bytes
+     * processed, and emits the "fileProcessed" event.
+     *
+     * @param {BagItFile} bagItFile - The file the validator has finished
+     * with.
+     *
+     * @private
+     */
+    _fileProcessed(bagItFile) {
+        this._bytesProcessed += Number(bagItFile.size);
+        this.emit('fileProcessed', bagItFile, this._bytesProcessed);
+    }
+
+    /**
+     * _getCryptoHashes returns a list of prepared cryptographic hashes that
+     * are ready to have bits streamed through them. Each hash includes a
+     * pre-wired 'end' event that assigns the computed checksum to the
+     * BagItFile's checksums hash. For example, a sha256 hash, once the bits
+     * have been pushed through it, will set the following in it's event event:
+     *
+     * @example
+     * bagItFile.checksums['sha256'] = "[computed hex value]";
+     *
+     * @param {BagItFile} bagItFile - A file inside the directory or tarball.
+     * This is the file whose checksums will be computed.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {Array<crypto.Hash>}
+     *
+     */
+    _getCryptoHashes(bagItFile) {
+        let validator = this;
+        let hashes = [];
+        let algorithms = this._digestAlgorithms();
+        // The done function decreases the validator's internal counter
+        // of how many digests are still begin calculated.
+        let done = function(cbData) { validator._hashCompleted(bagItFile, cbData) };
+        this._digestsPending[bagItFile.relDestPath] = algorithms.length;
+        for (let algorithm of algorithms) {
+            hashes.push(bagItFile.getCryptoHash(algorithm, done));
+            validator._hashesInProgress++;
+        }
+        return hashes;
+    }
+
+    /**
+     * _digestAlgorithms returns the names of all the digest algorithms
+     * the validator must calculate for each file. This includes the
+     * algorithms the profile requires for manifests and tag manifests,
+     * plus those of any other manifests and tag manifests found in the
+     * bag.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {string[]}
+     */
+    _digestAlgorithms() {
+        // Put together all of the algorithms we'll need for checksums,
+        // filtering out empty strings and duplicates.
+        let m = this.profile.chooseManifestAlgorithms('manifest');
+        let t = this.profile.chooseManifestAlgorithms('tagmanifest');
+        let f = this.manifestAlgorithmsFoundInBag.concat(this.tagManifestAlgorithmsFoundInBag);
+        if (this.readingFromStream()) {
+            // We can't scan a stream for manifests before reading it,
+            // so calculate every digest the profile allows.
+            f = f.concat(this.profile.manifestsAllowed, this.profile.tagManifestsAllowed);
+        }
+        return Array.from(new Set(m.concat(t, f).filter(alg => alg != '')));
+    }
+
+    /**
+     * _restoreFromCheckpoint copies bagItFile's checksums from the
+     * checkpoint, if the checkpoint has every digest the validator
+     * needs and the file hasn't changed since it was recorded. The
+     * restored digests go through _hashCompleted(), just like newly
+     * calculated ones, so the fixity registry and streamVerifyTar()
+     * still see them.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {BagItFile} bagItFile - A file inside the directory or tarball.
+     *
+     * @returns {boolean} True if the checksums were restored, false if
+     * the validator must calculate them.
+     */
+    _restoreFromCheckpoint(bagItFile) {
+        if (this.checkpoint == null) {
+            return false;
+        }
+        let checksums = this.checkpoint.checksumsFor(bagItFile);
+        let algorithms = this._digestAlgorithms();
+        if (checksums == null || !algorithms.every(alg => checksums[alg])) {
+            return false;
+        }
+        this._digestsPending[bagItFile.relDestPath] = algorithms.length;
+        this._hashesInProgress += algorithms.length;
+        for (let algorithm of algorithms) {
+            bagItFile.checksums[algorithm] = checksums[algorithm];
+            this._hashCompleted(bagItFile, {
+                absSourcePath: bagItFile.absSourcePath,
+                relDestPath: bagItFile.relDestPath,
+                algorithm: algorithm,
+                digest: checksums[algorithm]
+            });
+        }
+        return true;
+    }
+
+    /**
+     * _hashCompleted is called each time a checksum digest finishes.
+     * If there's a fixityVerifier, this asks it for the expected digest
+     * of each payload file. In streamVerifyTar() mode, this verifies
+     * payload checksums against the payload manifests and then discards
+     * them. Once all of a file's digests are done, this records the file
+     * in the checkpoint, if there is one. Since streamVerifyTar() discards
+     * payload checksums, a resumed validation will hash those payload
+     * files again.
+     *
+     * @param {BagItFile} bagItFile - The file whose digest was computed.
+     *
+     * @param {object} cbData - Info about the digest, including the
+     * algorithm and the digest itself. See {@link BagItFile#getCryptoHash}.
+     *
+     * @private
+     */
+    _hashCompleted(bagItFile, cbData) {
+        this._digestsPending[bagItFile.relDestPath]--;
+        if (this._unreadableFiles[bagItFile.relDestPath]) {
+            // This is the digest of part of the file. Don't keep it.
+            delete bagItFile.checksums[cbData.algorithm];
+            this._hashesInProgress--;
+            return;
+        }
+        if (this.fixityVerifier != null && bagItFile.isPayloadFile()) {
+            this._fixityChecks.push(this._checkFixityRegistry(bagItFile.relDestPath, cbData.algorithm, cbData.digest));
+        }
+        if (this._streamVerify && bagItFile.isPayloadFile()) {
+            this._verifyPayloadChecksum(bagItFile, cbData.algorithm, cbData.digest);
+            delete bagItFile.checksums[cbData.algorithm];
+        }
+        if (this._digestsPending[bagItFile.relDestPath] === 0) {
+            if (this.checkpoint != null) {
+                this.checkpoint.record(bagItFile);
+            }
+            this._fileProcessed(bagItFile);
+            if (this._streamVerify && bagItFile.isPayloadFile()) {
+                this._dropPayloadFile(bagItFile);
+            }
+        }
+        this._hashesInProgress--;
+    }
+
+    /**
+     * _dropPayloadFile removes a verified payload file from this.files,
+     * keeping only its path and size. This is used only by
+     * streamVerifyTar(), so memory use doesn't grow with a
+     * {@link BagItFile} for every payload file.
+     *
+     * @param {BagItFile} bagItFile - The payload file.
+     *
+     * @private
+     */
+    _dropPayloadFile(bagItFile) {
+        let relPath = bagItFile.relDestPath;
+        if (this.files[relPath] !== bagItFile) {
+            // Another entry with the same path replaced this one.
+            return;
+        }
+        delete this.files[relPath];
+        delete this._digestsPending[relPath];
+        if (!this._streamedPayloadPaths.has(relPath)) {
+            this._streamedPayloadPaths.add(relPath);
+            this._streamedPayloadBytes += Number(bagItFile.size);
+        }
+    }
+
+    /**
+     * _checkFixityRegistry asks fixityVerifier for the expected digest
+     * of a payload file and records a mismatch if the registry's digest
+     * differs from the one the validator calculated. If the registry has
+     * no digest for the file, there's nothing to compare.
+     *
+     * @param {string} relPath - The relative path of the payload file.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @param {string} digest - The digest the validator calculated.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _checkFixityRegistry(relPath, algorithm, digest) {
+        var validator = this;
+        return Promise.resolve().then(function() {
+            return validator.fixityVerifier.expectedChecksum(relPath, algorithm);
+        }).then(function(expected) {
+            if (expected != null && !validator._digestsMatch(expected, digest)) {
+                validator._fixityMismatches.push({
+                    relPath: relPath,
+                    algorithm: algorithm,
+                    expected: expected,
+                    actual: digest
+                });
+            }
+        }).catch(function(err) {
+            validator._addError('fixityRegistry', `Could not check ${algorithm} digest of '${relPath}' against fixity registry: ${err}`, relPath);
+        });
+    }
+
+    /**
+     * _verifyPayloadChecksum compares a payload file's digest against
+     * the digest in the payload manifest for the same algorithm, if
+     * there is one. This is used only by streamVerifyTar().
+     *
+     * @param {BagItFile} bagItFile - The payload file.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @param {string} digest - The digest the validator calculated.
+     *
+     * @private
+     */
+    _verifyPayloadChecksum(bagItFile, algorithm, digest) {
+        let manifest = this._manifestFile(`manifest-${algorithm}.txt`);
+        if (!manifest || !manifest.keyValueCollection) {
+            return;
+        }
+        let checksumInManifest = this._manifestDigest(manifest, bagItFile.relDestPath);
+        if (checksumInManifest != null && !this._digestsMatch(checksumInManifest, digest)) {
+            this._addChecksumError(algorithm, bagItFile.relDestPath, checksumInManifest, digest, bagItFile.size);
+        }
+    }
+
+    /**
+     * _digestsMatch returns true if two hex digests are the same. The
+     * comparison is case-insensitive, since the BagIt spec allows
+     * either uppercase or lowercase hex in manifests.
+     *
+     * @param {string} checksumInManifest - The digest listed in the manifest.
+     *
+     * @param {string} calculatedChecksum - The digest the validator calculated.
+     *
+     * @returns {boolean}
+     *
+     * @private
+     */
+    _digestsMatch(checksumInManifest, calculatedChecksum) {
+        if (typeof checksumInManifest !== 'string' || typeof calculatedChecksum !== 'string') {
+            return false;
+        }
+        return checksumInManifest.toLowerCase() == calculatedChecksum.toLowerCase();
+    }
+
+    /**
+     * _addChecksumError records a digest mismatch.
+     *
+     * @param {string} algorithm - The digest algorithm. E.g. 'sha256'.
+     *
+     * @param {string} filename - The relative path of the file.
+     *
+     * @param {string} checksumInManifest - The digest listed in the manifest.
+     *
+     * @param {string} calculatedChecksum - The digest the validator calculated.
+     *
+     * @param {number} size - The size of the file, in bytes. This goes
+     * into the error's actualSize, so operators can tell a truncated
+     * file from an altered one without fetching the file again.
+     *
+     * @private
+     */
+    _addChecksumError(algorithm, filename, checksumInManifest, calculatedChecksum, size) {
+        let result = this._addError('checksums', `Bad ${algorithm} digest for '${filename}': manifest says '${checksumInManifest}', file digest is '${calculatedChecksum}'.`, filename, checksumInManifest, calculatedChecksum);
+        if (result !== undefined) {
+            result.actualSize = Number(size);
+        }
+    }
+
+    /**
+     * _getByteOrderMarkDetector returns a stream that sets
+     * this._bagItTxtHasBOM to true if the first bytes piped through
+     * it are a UTF-8 byte order mark. The validator pipes bagit.txt
+     * through this stream.
+     *
+     * @returns {stream.PassThrough}
+     *
+     * @private
+     */
+    _getByteOrderMarkDetector() {
+        let validator = this;
+        let detector = new stream.PassThrough();
+        let firstChunk = true;
+        detector.on('data', function(chunk) {
+            if (firstChunk && chunk.length >= 3 && chunk[0] == 0xEF && chunk[1] == 0xBB && chunk[2] == 0xBF) {
+                validator._bagItTxtHasBOM = true;
+            }
+            firstChunk = false;
+        });
+        return detector;
+    }
+
+    /**
+     * _getTagFileCollector returns a stream that collects the raw bytes
+     * of a tag file in this._tagFileBytes, so that
+     * _validateTagFileFormat can check them later.
+     *
+     * @param {BagItFile} bagItFile - The tag file being read.
+     *
+     * @returns {stream.PassThrough}
+     *
+     * @private
+     */
+    _getTagFileCollector(bagItFile) {
+        let chunks = [];
+        this._tagFileBytes[bagItFile.relDestPath] = chunks;
+        let collector = new stream.PassThrough();
+        collector.on('data', function(chunk) {
+            chunks.push(chunk);
+        });
+        return collector;
+    }
+
+    /**
+     * _getMimeTypeDetector returns a stream that collects the first
+     * mimeSniffLength bytes of a payload file, and records the file's
+     * MIME type in this._payloadMimeTypes when the file has been read.
+     *
+     * @param {BagItFile} bagItFile - The payload file being read.
+     *
+     * @returns {stream.PassThrough}
+     *
+     * @private
+     */
+    _getMimeTypeDetector(bagItFile) {
+        var validator = this;
+        let head = Buffer.alloc(0);
+        let detector = new stream.PassThrough();
+        detector.on('data', function(chunk) {
+            if (head.length < mimeSniffLength) {
+                head = Buffer.concat([head, chunk]).slice(0, mimeSniffLength);
+            }
+        });
+        detector.on('end', function() {
+            validator._payloadMimeTypes[bagItFile.relDestPath] = Validator.detectMimeType(head);
+        });
+        return detector;
+    }
+
+    /**
+     * detectMimeType returns the MIME type of a file, based on its first
+     * few bytes. See mimeSignatures for the types this recognizes. For
+     * files that don't match any of those, this returns 'text/plain' if
+     * the bytes contain no binary control characters, and
+     * 'application/octet-stream' otherwise.
+     *
+     * @param {Buffer} head - The first bytes of the file. 512 bytes is
+     * plenty.
+     *
+     * @returns {string}
+     */
+    static detectMimeType(head) {
+        for (let sig of mimeSignatures) {
+            if (head.slice(0, sig.bytes.length).equals(sig.bytes)) {
+                return sig.mimeType;
+            }
+        }
+        for (let b of head) {
+            if (b <= 0x08 || b == 0x0b || (b >= 0x0e && b <= 0x1a) || (b >= 0x1c && b <= 0x1f)) {
+                return 'application/octet-stream';
+            }
+        }
+        return 'text/plain';
+    }
+
+    /**
+     * _getSignatureCollector returns a stream that collects the raw
+     * bytes of a manifest or detached signature in this._signatureBytes,
+     * so that _verifySignatures can check them later.
+     *
+     * @param {BagItFile} bagItFile - The manifest or signature being read.
+     *
+     * @returns {stream.PassThrough}
+     *
+     * @private
+     */
+    _getSignatureCollector(bagItFile) {
+        let chunks = [];
+        this._signatureBytes[bagItFile.relDestPath] = chunks;
+        let collector = new stream.PassThrough();
+        collector.on('data', function(chunk) {
+            chunks.push(chunk);
+        });
+        return collector;
+    }
+
+    /**
+     * _getExtractor returns a stream that writes bagItFile into the bag's
+     * directory under _extractTo, or null if the file's path would put it
+     * outside that directory. Paths with '..' segments are refused even
+     * if they resolve to a path inside the bag, since they could
+     * overwrite another file in the bag.
+     *
+     * @param {BagItFile} bagItFile - The file being read.
+     *
+     * @returns {fs.WriteStream}
+     *
+     * @private
+     */
+    _getExtractor(bagItFile) {
+        let relPath = bagItFile.relDestPath;
+        let bagDir = path.join(this._extractTo, this.readingFromStream() ? this.bagRoot : this.bagName);
+        let destPath = path.resolve(bagDir, relPath);
+        if (relPath.split('/').includes('..') || !destPath.startsWith(bagDir + path.sep)) {
+            this._addError('extract', `Refused to extract ${relPath}, which would be written outside ${bagDir}.`, relPath);
+            return null;
+        }
+        fs.mkdirSync(path.dirname(destPath), { recursive: true });
+        let writeStream = fs.createWriteStream(destPath);
+        this._extractions.push(new Promise(function(resolve, reject) {
+            writeStream.on('finish', resolve);
+            writeStream.on('error', reject);
+        }));
+        return writeStream;
+    }
+
+    /**
+     * _verifySignatures asks signatureVerifier to check each detached
+     * signature over a manifest, and records the signatures that don't
+     * verify in this._signatureFailures. Signatures over files other
+     * than manifests are ignored. If there's no signatureVerifier, this
+     * does nothing.
+     *
+     * @returns {Promise}
+     *
+     * @private
+     */
+    _verifySignatures() {
+        var validator = this;
+        if (this.signatureVerifier == null) {
+            return Promise.resolve();
+        }
+        let checks = [];
+        for (let sigPath of Object.keys(this._signatureBytes).sort()) {
+            if (!BagItFile.isSignature(sigPath)) {
+                continue;
+            }
+            let manifestPath = sigPath.replace(/\.(asc|sig)$/, '');
+            let fileType = BagItFile.getFileType(manifestPath);
+            if (fileType != Constants.PAYLOAD_MANIFEST && fileType != Constants.TAG_MANIFEST) {
+                continue;
+            }
+            if (!this._signatureBytes[manifestPath]) {
+                this._addError('signature', `Signature ${sigPath} is for ${manifestPath}, which is not in the bag.`, sigPath);
+                continue;
+            }
+            let manifestBytes = Buffer.concat(this._signatureBytes[manifestPath]);
+            let signatureBytes = Buffer.concat(this._signatureBytes[sigPath]);
+            checks.push(Promise.resolve().then(function() {
+                return validator.signatureVerifier.verify(manifestPath, manifestBytes, signatureBytes);
+            }).then(function(verified) {
+                if (!verified) {
+                    validator._signatureFailures.push(sigPath);
+                }
+            }).catch(function(err) {
+                validator._addError('signature', `Could not verify signature ${sigPath}: ${err}`, sigPath);
+            }));
+        }
+        return Promise.all(checks);
+    }
+
+    /**
+     * _validateUntarDirectory is for tarred bags only. It checks to see
+     * whether the tar file extracts to a directory whose name matches
+     * the bag name, minus the ".tar" extension. If it doesn't untar there,
+     * this method adds an error to the validation results.
+     *
+     * E.g. "myBag.tar" should untar to a directory called "myBag"
+     *
+     * This rule only apples for BagItProfiles where tarDirMustMatchName
+     * is true.
+     *
+     * For all profiles, this also checks that the tar file has exactly
+     * one top-level directory. The validator finds each file's path
+     * within the bag by removing the bag directory from the front of
+     * the file's path in the tar file, so entries outside that
+     * directory would end up with paths that make no sense.
+     *
+     * The official BagIt 1.0 spec at
+     * https://tools.ietf.org/html/rfc8493#section-2 says:
+     *
+     * `The base directory can have any name.`
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @returns {boolean} - If true, it's ok to proceed with the rest
+     * of the validation. If false, validation stops, because no files
+     * will be in their expected places.
+     *
+     */
+    _validateUntarDirectory() {
+        var okToProceed = true;
+        if (this.readingFromTar() && this._topLevelEntries.size > 1) {
+            let entries = Array.from(this._topLevelEntries).sort();
+            this._addError('untarDirectory', `Bag should untar to a single directory, but it has ${entries.length} top-level entries: ${entries.join(', ')}`);
+            okToProceed = this._isSkipped('untarDirectory');
+        }
+        if (this.readingFromTar() && this.profile.tarDirMustMatchName) {
+            var tarFileName = Validator.bagNameFromPath(this.pathToBag);
+            if (this.bagRoot != tarFileName) {
+                this._addError('untarDirectory', `Bag should untar to directory '${tarFileName}', not '${this.bagRoot}'`);
+                okToProceed = this._isSkipped('untarDirectory');
+            }
+        }
+        return okToProceed;
+    }
+
+    /**
+     * _resolveBlobMap adds a payload file for each logical path in the
+     * blob map of a content-addressed bag, if contentAddressed is true.
+     * Each of these files has the size and digests of its blob, so the
+     * payload manifests are checked against the blobs' contents. This
+     * also checks that each blob's name matches the digest of its
+     * contents, when the name is a digest the validator calculated, and
+     * warns about blobs that the map doesn't mention.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _resolveBlobMap() {
+        this._blobMappings = {};
+        if (!this.contentAddressed) {
+            return;
+        }
+        if (this._tagFileBytes[blobMapFile] === undefined) {
+            this._addError('blobMap', `Bag is content-addressed, but it has no ${blobMapFile}.`);
+            return;
+        }
+        let text = Buffer.concat(this._tagFileBytes[blobMapFile]).toString('utf8');
+        let referenced = new Set();
+        text.split(/\r?\n/).forEach((line, i) => {
+            if (line.trim() == '') {
+                return;
+            }
+            let [blobId, ...rest] = line.trim().split(/\s+/);
+            let logicalPath = ManifestParser.decodePath(rest.join(' '));
+            if (!logicalPath.startsWith('data/')) {
+                this._addError('blobMap', `Line ${i + 1} of ${blobMapFile} should map a blob to a payload path, like data/file.txt.`, blobMapFile);
+                return;
+            }
+            if (this._hasFile(logicalPath)) {
+                this._addError('blobMap', `${blobMapFile} maps ${logicalPath} to a blob, but the bag also has a file at that path.`, logicalPath);
+                return;
+            }
+            let blobPath = blobDirectory + blobId;
+            let blob = this.files[blobPath];
+            if (blob === undefined) {
+                this._addError('blobMap', `${blobMapFile} maps ${logicalPath} to blob ${blobId}, which is missing from ${blobDirectory}.`, logicalPath);
+                return;
+            }
+            referenced.add(blobPath);
+            let payloadFile = new BagItFile(blob.absSourcePath, logicalPath, new FileStat({ size: blob.size, type: 'file' }));
+            payloadFile.checksums = blob.checksums;
+            this.files[logicalPath] = payloadFile;
+            this._blobMappings[logicalPath] = blobPath;
+        });
+        for (let blobPath of [...referenced].sort()) {
+            let blobId = path.basename(blobPath).toLowerCase();
+            let digests = Object.values(this.files[blobPath].checksums).map(d => d.toLowerCase());
+            let sameLength = digests.filter(d => d.length == blobId.length);
+            if (sameLength.length > 0 && !sameLength.includes(blobId)) {
+                this._addError('blobMap', `Blob ${blobPath} does not match its content address. Its contents may be corrupt.`, blobPath);
+            }
+        }
+        let unreferenced = Object.keys(this.files).filter(relPath => relPath.startsWith(blobDirectory) && !referenced.has(relPath));
+        for (let blobPath of unreferenced.sort()) {
+            this._addWarning('blobMap', `Blob ${blobPath} is not mapped to any payload path in ${blobMapFile}.`, blobPath);
+        }
+    }
+
+    /**
+     * _validateEntryCount checks that the number of regular files the
+     * reader found in the bag equals the number of payload files, tag
+     * files, manifests, and tag manifests the validator recorded. If
+     * they differ, some entries were skipped or overwritten, which
+     * usually means a corrupt archive, or one with duplicate entries.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateEntryCount() {
+        let payloadCount = this._payloadFileCount();
+        let tagCount = this.tagFiles().length;
+        // Count manifest parts separately, since each part is a file.
+        let manifestCount = Object.values(this.files).filter(f => f.isPayloadManifest()).length;
+        let tagManifestCount = Object.values(this.files).filter(f => f.isTagManifest()).length;
+        // Payload files resolved through the blob map are not entries
+        // in the bag. Their blobs are counted as tag files.
+        payloadCount -= Object.keys(this._blobMappings).length;
+        let classified = payloadCount + tagCount + manifestCount + tagManifestCount;
+        if (classified != this._regularFileEntries) {
+            this._addError('entryCount', `Bag contains ${this._regularFileEntries} regular files, but the validator processed ${classified} (${payloadCount} payload files, ${tagCount} tag files, ${manifestCount} manifests, ${tagManifestCount} tag manifests). Some entries may be duplicated or corrupt.`);
+        }
+    }
+
+    /**
+     * _validateDuplicateEntries adds an error for each path that appears
+     * more than once in a serialized bag. When a tar file has two entries
+     * with the same name, extracting it silently replaces the first with
+     * the second, so there's no telling which bytes the creator meant to
+     * preserve. The validator checks the last entry, as an extractor
+     * would.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateDuplicateEntries() {
+        for (let relPath of Object.keys(this._entryCounts).sort()) {
+            let count = this._entryCounts[relPath];
+            if (count > 1) {
+                this._addError('duplicateEntries', `Archive contains ${count} entries for ${relPath}. Only the last one was validated.`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validateCompressionRatio warns if a compressed bag's compression
+     * ratio is higher than compressionRatioWarning.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateCompressionRatio() {
+        let ratio = this.compressionRatio();
+        if (this.compressionRatioWarning > 0 && ratio != null && ratio > this.compressionRatioWarning) {
+            this._addWarning('compressionRatio', `Bag has compression ratio ${ratio.toFixed(1)}, which is higher than the warning threshold of ${this.compressionRatioWarning}. It may be padded with filler.`);
+        }
+    }
+
+    /**
+     * _validateRequiredManifests checks to see if the manifests required by
+     * the {@link BagItProfile} are actually present in the bag. If they're not,
+     * it records the error in the Validator.errors array.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {string} manifestType - The type of manifest to look for.
+     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
+     * {Constants.TAG_MANIFEST}.
+     *
+     */
+    _validateRequiredManifests(manifestType) {
+        var manifestList = this.profile.manifestsRequired;
+        if (manifestType === Constants.TAG_MANIFEST) {
+            manifestList = this.profile.tagManifestsRequired;
+        }
+        for (var alg of manifestList) {
+            var name = `${manifestType}-${alg}.txt`
+            if(this._manifestFile(name) === undefined) {
+                this._addError('requiredManifests', `Bag is missing required ${manifestType} ${name}`, name);
+                this._addMisplacedManifestHint(name);
+            }
+        }
+    }
+
+
+    /**
+     * _addMisplacedManifestHint adds a warning for each payload file
+     * named like the missing manifest. A manifest under data/ is just
+     * another payload file, so producers who put it there by mistake
+     * would otherwise see only that the manifest is missing.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {string} name - The name of the missing manifest. E.g.
+     * 'manifest-md5.txt'.
+     *
+     */
+    _addMisplacedManifestHint(name) {
+        for (let relPath of this._payloadPaths()) {
+            if (path.basename(relPath) == name) {
+                this._addWarning('misplacedManifest', `Found ${name} inside data/ at ${relPath}. It must be at the bag root.`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validateAllowedManifests checks to see if the bag contains manifests
+     * not listed in the manifestsAllowed or tagManifestsAllowed list of the
+     * {@link BagItProfile}. This records illegal manifests in the
+     * Validator.errors array. If the profile's allowOnlyRequiredManifests
+     * is true, manifests that are allowed but not required are illegal
+     * too.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {string} manifestType - The type of manifest to look for.
+     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
+     * {Constants.TAG_MANIFEST}.
+     *
+     */
+    _validateAllowedManifests(manifestType) {
+        let allowed = this.profile.manifestsAllowed;
+        let required = this.profile.manifestsRequired;
+        let foundInBag = this.manifestAlgorithmsFoundInBag;
+        if (manifestType === Constants.TAG_MANIFEST) {
+            allowed = this.profile.tagManifestsAllowed;
+            required = this.profile.tagManifestsRequired;
+            foundInBag = this.tagManifestAlgorithmsFoundInBag;
+        }
+        for (var alg of foundInBag) {
+            if(!allowed.includes(alg)) {
+                this._addError('allowedManifests', `Bag includes ${manifestType} ${alg}, which is not in the list of allowed ${manifestType}s`, `${manifestType}-${alg}.txt`);
+            } else if (this.profile.allowOnlyRequiredManifests && !required.includes(alg)) {
+                this._addError('allowedManifests', `Bag includes ${manifestType} ${alg}, but the profile allows only required ${manifestType}s: ${required.join(', ') || 'none'}`, `${manifestType}-${alg}.txt`);
+            }
+        }
+    }
+
+    /**
+     * _validateManifestAlgorithmsSupported adds an error for each
+     * manifest or tag manifest whose name specifies an algorithm that
+     * is not registered with {@link BagItFile.registerDigestAlgorithm},
+     * such as manifest-rot13.txt. The validator can't calculate those
+     * digests, so it doesn't check the checksums in those manifests.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateManifestAlgorithmsSupported() {
+        for (let relPath of this._unsupportedManifests) {
+            let algorithm = relPath.split('-')[1].split('.')[0];
+            this._addError('manifestAlgorithm', `Unsupported checksum algorithm '${algorithm}' in ${relPath}`, relPath);
+        }
+    }
+
+    /**
+     * _validateAllowedTagFiles checks to see if the bag contains tag files
+     * not listed in the tagFilesAllowed list of the
+     * {@link BagItProfile}. This records illegal tag files in the
+     * Validator.errors array.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateAllowedTagFiles() {
+        let allowed = this.profile.tagFilesAllowed || [];
+        let tagFiles = this.tagFiles();
+        for (let file of tagFiles) {
+            if (file.relDestPath == 'bagit.txt') {
+                continue;
+            }
+            let matchesAllowedPattern = false;
+            let fileWasTested = false;
+            for (let pattern of allowed) {
+                if (Util.isEmpty(pattern) || pattern.trim() == '*') {
+                    continue;
+                }
+                if (minimatch(file.relDestPath, pattern)) {
+                    matchesAllowedPattern = true;
+                }
+                fileWasTested = true;
+            }
+            //console.log(`${file.relDestPath} Tested: ${fileWasTested}, Allowed: ${matchesAllowedPattern}`)
+            if (fileWasTested && !matchesAllowedPattern) {
+                this._addError('allowedTagFiles', `Tag file ${file.relDestPath} is not in the list of allowed tag files.`, file.relDestPath);
+            }
+        }
+    }
+
+    /**
+     * _validateManifestCounts checks that all payload manifests list the
+     * same number of files, as they must under BagIt 1.0 if they list
+     * the same files. This is a quick check that runs before the
+     * file-by-file comparisons, and it reports the number of files in
+     * each manifest, so a mismatch is easy to spot.
+     *
+     * This doesn't run for BagIt 0.97 bags. That version of the spec
+     * lets each payload file appear in just one payload manifest, so a
+     * valid 0.97 bag can have manifests that list different numbers of
+     * files. See {@link SpecRules}.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateManifestCounts() {
+        if (!SpecRules[this.specVersion].everyFileInEveryManifest) {
+            return;
+        }
+        let manifests = this.payloadManifests().sort((a, b) => a.relDestPath.localeCompare(b.relDestPath));
+        let counts = manifests.map(m => m.keyValueCollection.keys().length);
+        if (new Set(counts).size > 1) {
+            let totals = manifests.map((m, i) => `${m.relDestPath} has ${counts[i]}`).join(', ');
+            this._addError('manifestCounts', `Payload manifests list different numbers of files: ${totals}.`);
+        }
+    }
+
+    /**
+     * _validateManifestEntries checks to see that the checksum entries in a
+     * payload manifest or tag manifest match the actual computed digests of
+     * the files in the bag. It records mismatches in the Validator.errors
+     * array.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {string} manifestType - The type of manifest to look for.
+     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
+     * {Constants.TAG_MANIFEST}.
+     *
+     */
+    _validateManifestEntries(manifestType) {
+        var manifests = this.payloadManifests();
+        if (manifestType === Constants.TAG_MANIFEST) {
+            manifests = this.tagManifests();
+        }
+        //Context.logger.info(`Validator: Validating ${manifests.length} ${manifestType}s`);
+        for(var manifest of Object.values(manifests)) {
+            //Context.logger.info(`Validator: Validating ${manifest.relDestPath}`);
+            var basename = path.basename(manifest.relDestPath, '.txt');
+            var algorithm = basename.split('-')[1];
+            if (!BagItFile.digestAlgorithms().includes(algorithm)) {
+                // Reported by _validateManifestAlgorithmsSupported.
+                continue;
+            }
+            for (var filename of manifest.keyValueCollection.keys()) {
+                if (manifestType === Constants.PAYLOAD_MANIFEST && this._isBagMetadataFile(filename)) {
+                    var fileType = { manifest: 'payload manifest', tagmanifest: 'tag manifest' }[BagItFile.getFileType(filename)] || 'tag file';
+                    this._addError('manifestEntries', `Payload manifest ${manifest.relDestPath} lists ${filename}, which is a ${fileType}, not a payload file.`, filename);
+                    continue;
+                }
+                var relPath = this._hasFile(filename) ? filename : undefined;
+                if (relPath === undefined && this.normalizeUnicode) {
+                    relPath = this._findPathNormalized(manifest, filename);
+                }
+                if (relPath === undefined && this.requireExactPathCase) {
+                    relPath = this._findPathIgnoringCase(manifest, filename);
+                    if (relPath !== undefined) {
+                        this._addError('pathCase', `Path case in ${manifest.relDestPath} does not match bag: manifest lists '${filename}', but file is '${relPath}'.`, relPath);
+                    }
+                }
+                if (relPath === undefined) {
+                    if (manifestType === Constants.PAYLOAD_MANIFEST && this._fetchFilenames().has(filename)) {
+                        // Listed in fetch.txt, so it doesn't have to be here.
+                        continue;
+                    }
+                    this._addError('manifestEntries', `File '${filename}' in ${manifest.relDestPath} is missing from bag.`, filename);
+                    continue;
+                }
+                if (this._unreadableFiles[relPath]) {
+                    // Already reported as a read error.
+                    continue;
+                }
+                if (this._streamVerify && (manifestType === Constants.PAYLOAD_MANIFEST || this.files[relPath] === undefined)) {
+                    // Already verified as the file streamed by, or a
+                    // payload file whose checksums are gone.
+                    continue;
+                }
+                var bagItFile = this.files[relPath];
+                var checksumInManifest = manifest.keyValueCollection.first(filename);
+                var calculatedChecksum = bagItFile.checksums[algorithm];
+                if (!this._digestsMatch(checksumInManifest, calculatedChecksum)) {
+                    this._addChecksumError(algorithm, filename, checksumInManifest, calculatedChecksum, bagItFile.size);
+                }
+            }
+        }
+    }
+
+    /**
+     * _validateManifestDuplicates adds an error for each path that a
+     * payload or tag manifest lists more than once. The validator
+     * checks only the first digest for each path, so a second entry,
+     * such as one appended by a tool that should have rewritten the
+     * manifest, would otherwise go unnoticed. The error says whether
+     * the entries have conflicting digests.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateManifestDuplicates() {
+        let manifests = this.payloadManifests().concat(this.tagManifests());
+        for (let manifest of manifests.sort((a, b) => a.relDestPath.localeCompare(b.relDestPath))) {
+            for (let filename of manifest.keyValueCollection.keys()) {
+                let digests = manifest.keyValueCollection.all(filename);
+                if (digests.length < 2) {
+                    continue;
+                }
+                let distinct = [...new Set(digests.map(d => d.toLowerCase()))];
+                let detail = distinct.length > 1 ? `with conflicting digests: ${distinct.join(', ')}` : 'with the same digest';
+                this._addError('manifestDuplicates', `${manifest.relDestPath} lists ${filename} ${digests.length} times, ${detail}.`, filename);
+            }
+        }
+    }
+
+    /**
+     * _validateManifestOrder checks that the entries in each payload and
+     * tag manifest are in ascending order by path, if the profile's
+     * requireSortedManifests is true. Paths are compared character by
+     * character, so uppercase letters sort before lowercase. This
+     * reports only the first out-of-order entry in each manifest.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateManifestOrder() {
+        if (!this.profile.requireSortedManifests) {
+            return;
+        }
+        for (let relPath of Object.keys(this._manifestEntries).sort()) {
+            let entries = this._manifestEntries[relPath];
+            for (let i = 1; i < entries.length; i++) {
+                let previous = entries[i - 1].filename;
+                let current = entries[i].filename;
+                if (current < previous) {
+                    this._addError('manifestOrder', `Entries in ${relPath} are not sorted by path: line ${entries[i].lineNumber} lists ${current} after ${previous}.`, relPath);
+                    break;
+                }
+            }
+        }
+    }
+
+    /**
+     * _findPathIgnoringCase returns the path of the file in the bag that
+     * matches filename without regard to case, or undefined if there
+     * isn't exactly one such file. Files that the manifest lists under
+     * their exact path don't count as matches.
+     *
+     * @param {BagItFile} manifest - The manifest that lists filename.
+     *
+     * @param {string} filename - The path listed in the manifest.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _findPathIgnoringCase(manifest, filename) {
+        let lowerCaseName = filename.toLowerCase();
+        let matches = this._filePaths().filter(relPath =>
+            relPath.toLowerCase() === lowerCaseName &&
+            manifest.keyValueCollection.first(relPath) == null);
+        return matches.length === 1 ? matches[0] : undefined;
+    }
+
+    /**
+     * _findPathNormalized returns the path of the file in the bag that
+     * matches filename after both are normalized to Unicode NFC, or
+     * undefined if there isn't exactly one such file. Files that the
+     * manifest lists under their exact path don't count as matches.
+     *
+     * @param {BagItFile} manifest - The manifest that lists filename.
+     *
+     * @param {string} filename - The path listed in the manifest.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _findPathNormalized(manifest, filename) {
+        let normalizedName = filename.normalize('NFC');
+        let matches = this._filePaths().filter(relPath =>
+            relPath.normalize('NFC') === normalizedName &&
+            manifest.keyValueCollection.first(relPath) == null);
+        return matches.length === 1 ? matches[0] : undefined;
+    }
+
+    /**
+     * _manifestDigest returns the digest that manifest lists for relPath.
+     * If the manifest doesn't list relPath exactly, this returns the
+     * digest of an entry whose path matches relPath after Unicode
+     * normalization (if normalizeUnicode is true) or without regard to
+     * case (if requireExactPathCase is true, so the difference is
+     * reported as a pathCase error), as long as that entry doesn't
+     * refer to some other file in the bag. Returns null if the manifest
+     * doesn't list relPath.
+     *
+     * @param {BagItFile} manifest - A payload or tag manifest.
+     *
+     * @param {string} relPath - The relative path of a file in the bag.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _manifestDigest(manifest, relPath) {
+        let digest = manifest.keyValueCollection.first(relPath);
+        if (digest != null) {
+            return digest;
+        }
+        if (this.normalizeUnicode) {
+            let normalizedPath = relPath.normalize('NFC');
+            let key = manifest.keyValueCollection.keys().find(k =>
+                k.normalize('NFC') === normalizedPath && !this._hasFile(k));
+            if (key !== undefined) {
+                return manifest.keyValueCollection.first(key);
+            }
+        }
+        if (!this.requireExactPathCase) {
+            return null;
+        }
+        let lowerCasePath = relPath.toLowerCase();
+        let key = manifest.keyValueCollection.keys().find(k =>
+            k.toLowerCase() === lowerCasePath && !this._hasFile(k));
+        return key === undefined ? null : manifest.keyValueCollection.first(key);
+    }
+
+    /**
+     * _validateDigestCase adds a warning for each payload or tag manifest
+     * that contains both uppercase and lowercase hex digests. That's
+     * legal, since digests are compared without regard to case, but it
+     * usually means someone edited the manifest by hand or merged
+     * manifests from different tools.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
      */
-    _readBag() {
-        // Attach listeners to our reader.
-        var validator = this;
-        var reader = this.getNewReader();
-        reader.on('entry', function (entry) { validator._readEntry(entry) });
-        reader.on('error', function(err) { validator.emit('error', err) });
+    _validateDigestCase() {
+        for (let manifest of this.payloadManifests().concat(this.tagManifests())) {
+            if (manifest.keyValueCollection == null) {
+                continue;
+            }
+            let hasUpper = false;
+            let hasLower = false;
+            for (let filename of manifest.keyValueCollection.keys()) {
+                let digest = manifest.keyValueCollection.first(filename);
+                hasUpper = hasUpper || /[A-F]/.test(digest);
+                hasLower = hasLower || /[a-f]/.test(digest);
+            }
+            if (hasUpper && hasLower) {
+                this._addWarning('digestCase', `Manifest ${manifest.relDestPath} mixes uppercase and lowercase hex digests.`, manifest.relDestPath);
+            }
+        }
+    }
 
-        // Once reading is done, validate all the info we've gathered.
-        reader.on('end', function() {
-            // Is this really what we want to emit here?
-            validator.emit('task', new TaskDescription(validator.pathToBag, 'read'))
-            // FileSystemReader emits end event while streamreader is
-            // still piping file contents to our hashing algorithms. We want
-            // those hash pipes to complete before validating checksums in
-            // _validateFormatAndContents(), so we don't wind up with
-            // undefined checksums. The lag time between end event and the
-            // completion of checksums is usually ~5ms. Not sure of a better
-            // way to do this, given that we have an unknown number of pipes
-            // on each file. Don't want to go full async with promises, because
-            // we don't want 10,000 open file handles. So we have this, which
-            // is like a WaitGroup counter in Go or a CountDownLatch in
-            // Java. We check every 50ms to see if it has reached zero. At
-            // zero, we know all the checksums have completed.
-            let hashInterval = setInterval(() => {
-                if (validator._hashesInProgress === 0) {
-                    clearInterval(hashInterval);
-                    validator._validateFormatAndContents();
+    /**
+     * _validateFixityRegistry adds an error for each payload file whose
+     * digest doesn't match the digest in the fixity registry. The message
+     * includes the manifest's digest as well, so curators can tell
+     * whether the file or the manifest changed since ingest.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateFixityRegistry() {
+        for (let mismatch of this._fixityMismatches) {
+            let manifest = this._manifestFile(`manifest-${mismatch.algorithm}.txt`);
+            let inManifest = null;
+            if (manifest && manifest.keyValueCollection) {
+                inManifest = manifest.keyValueCollection.first(mismatch.relPath);
+            }
+            this._addError('fixityRegistry', `Fixity registry says ${mismatch.algorithm} digest for '${mismatch.relPath}' should be '${mismatch.expected}', file digest is '${mismatch.actual}', manifest says '${inManifest}'.`, mismatch.relPath);
+        }
+    }
+
+    /**
+     * _validateSignatures adds an error for each detached signature that
+     * signatureVerifier could not verify. That means the manifest was
+     * changed after it was signed, or someone other than the expected
+     * signer signed it.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateSignatures() {
+        for (let sigPath of this._signatureFailures.sort()) {
+            let manifestPath = sigPath.replace(/\.(asc|sig)$/, '');
+            this._addError('signature', `Signature ${sigPath} does not verify against ${manifestPath}.`, sigPath);
+        }
+    }
+
+    /**
+     * _validateAlgorithmStrength reports each payload or tag manifest
+     * whose algorithm is weaker than weakAlgorithmThreshold, as an error
+     * or warning according to weakAlgorithmSeverity. Algorithms that
+     * aren't in {@link Constants.DIGEST_ALGORITHMS} aren't ranked, so
+     * this doesn't report them.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateAlgorithmStrength() {
+        if (!this.weakAlgorithmSeverity) {
+            return;
+        }
+        let threshold = Constants.DIGEST_ALGORITHMS.indexOf(this.weakAlgorithmThreshold);
+        let manifests = this.payloadManifests().concat(this.tagManifests());
+        for (let manifest of manifests.sort((a, b) => a.relDestPath.localeCompare(b.relDestPath))) {
+            let algorithm = path.basename(manifest.relDestPath, '.txt').split('-')[1];
+            let strength = Constants.DIGEST_ALGORITHMS.indexOf(algorithm);
+            if (strength < 0 || strength >= threshold) {
+                continue;
+            }
+            let msg = `${manifest.relDestPath} uses ${algorithm}, which is weaker than the recommended minimum of ${this.weakAlgorithmThreshold}.`;
+            if (this.weakAlgorithmSeverity == 'error') {
+                this._addError('weakAlgorithm', msg, manifest.relDestPath);
+            } else {
+                this._addWarning('weakAlgorithm', msg, manifest.relDestPath);
+            }
+        }
+    }
+
+    /**
+     * _validateAlgorithmPolicy checks the bag's manifests against the
+     * runtime policy in approvedAlgorithms and policyRequiredAlgorithms.
+     * It adds an error for each payload or tag manifest whose algorithm
+     * isn't approved, and a warning for each policy-required algorithm
+     * that has no payload manifest.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateAlgorithmPolicy() {
+        if (this.approvedAlgorithms.length > 0) {
+            let manifests = this.payloadManifests().concat(this.tagManifests());
+            for (let manifest of manifests.sort((a, b) => a.relDestPath.localeCompare(b.relDestPath))) {
+                let algorithm = path.basename(manifest.relDestPath, '.txt').split('-')[1];
+                if (!this.approvedAlgorithms.includes(algorithm)) {
+                    this._addError('algorithmPolicy', `${manifest.relDestPath} uses ${algorithm}, which is not an approved algorithm. [Approved: ${this.approvedAlgorithms.join(', ')}]`, manifest.relDestPath);
                 }
-            }, 50);
-        });
+            }
+        }
+        let present = this.payloadManifests().map(m => path.basename(m.relDestPath, '.txt').split('-')[1]);
+        for (let algorithm of this.policyRequiredAlgorithms) {
+            if (!present.includes(algorithm)) {
+                this._addWarning('algorithmPolicy', `Policy calls for a ${algorithm} payload manifest, but the bag has none.`, `manifest-${algorithm}.txt`);
+            }
+        }
+    }
 
-        // Read the contents of the bag.
-        reader.read();
+    /**
+     * _validateTagFileAlgorithms checks that each tag file named in the
+     * profile's tagFileAlgorithms is listed in a tag manifest that uses
+     * the required algorithm. Tag files that aren't in the bag are
+     * skipped, since the tag checks report missing tag files that are
+     * required. _validateManifestEntries checks the digests themselves.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateTagFileAlgorithms() {
+        let tagManifests = this.tagManifests().sort((a, b) => a.relDestPath.localeCompare(b.relDestPath));
+        for (let filename of Object.keys(this.profile.tagFileAlgorithms || {}).sort()) {
+            if (this.files[filename] === undefined) {
+                continue;
+            }
+            let algorithm = this.profile.tagFileAlgorithms[filename];
+            let coveredBy = tagManifests.filter(m => this._manifestDigest(m, filename));
+            if (coveredBy.some(m => m.relDestPath == `tagmanifest-${algorithm}.txt`)) {
+                continue;
+            }
+            let detail = coveredBy.length > 0 ? `it is only in ${coveredBy.map(m => m.relDestPath).join(', ')}` : 'no tag manifest lists it';
+            this._addError('tagFileAlgorithms', `Tag file ${filename} must be listed in a ${algorithm} tag manifest, but ${detail}.`, filename);
+        }
     }
 
     /**
-     * _validateFormatAndContents is called internally by the public validate()
-     * method. While validate() reads the contents of the bag, parses manifests
-     * and tag files, this method compares the info in the bag to what the
-     * {@link BagItProfile} says is valid.
+     * _validateManifestAlgorithmTag warns about differences between the
+     * algorithms declared in bag-info.txt's Manifest-Algorithm tag and
+     * the algorithms of the bag's payload manifests, if
+     * checkManifestAlgorithmTag is true.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
      */
-    _validateFormatAndContents() {
-        var okToProceed = this._validateUntarDirectory();
-        if (okToProceed) {
-            // ------------------------------------------
-            // TODO: Validate fetch.txt
-            // ------------------------------------------
-            this._validateRequiredManifests(Constants.PAYLOAD_MANIFEST);
-            this._validateRequiredManifests(Constants.TAG_MANIFEST);
-            this._validateAllowedManifests(Constants.PAYLOAD_MANIFEST);
-            this._validateAllowedManifests(Constants.TAG_MANIFEST);
-            this._validateAllowedTagFiles();
-            this._validateManifestEntries(Constants.PAYLOAD_MANIFEST);
-            this._validateManifestEntries(Constants.TAG_MANIFEST);
-            this._validateNoExtraneousPayloadFiles();
-            this._validatePayloadOxum();
-            this._validateTags();
+    _validateManifestAlgorithmTag() {
+        let bagInfo = this.files['bag-info.txt'];
+        if (!this.checkManifestAlgorithmTag || !bagInfo || bagInfo.keyValueCollection == null) {
+            return;
+        }
+        let values = bagInfo.keyValueCollection.all('Manifest-Algorithm');
+        if (values == null) {
+            return;
+        }
+        let declared = new Set();
+        for (let value of values) {
+            value.split(',').map(alg => alg.trim().toLowerCase()).filter(alg => alg != '').forEach(alg => declared.add(alg));
+        }
+        let present = this.payloadManifests().map(m => path.basename(m.relDestPath, '.txt').split('-')[1]);
+        for (let alg of [...declared].sort()) {
+            if (!present.includes(alg)) {
+                this._addWarning('manifestAlgorithmTag', `bag-info.txt declares Manifest-Algorithm ${alg}, but the bag has no manifest-${alg}.txt.`, 'bag-info.txt');
+            }
+        }
+        for (let alg of present.sort()) {
+            if (!declared.has(alg)) {
+                this._addWarning('manifestAlgorithmTag', `Bag has manifest-${alg}.txt, but bag-info.txt does not declare ${alg} in Manifest-Algorithm.`, `manifest-${alg}.txt`);
+            }
         }
-        this.emit('end')
     }
 
     /**
-     * _validateSerialization checks to see whether or not the bag is
-     * in a format that adheres to the profile's serialization rules.
+     * _isBagMetadataFile returns true if filename is outside the payload
+     * directory and refers to one of the bag's tag files or manifests,
+     * or to one of the standard BagIt metadata files.
      *
-     * For example, if the profile's serialization attribute is "required"
-     * and acceptSerialization is "application/tar", then this bag MUST
-     * be a tar file.
+     * @param {string} filename - The relative path of a file in the bag.
      *
-     * You can disable this check by setting
-     * Validator.disableSerializationCheck to true. You would want to do
-     * that in cases where you've built a bag and want to validate it
-     * before you tar or zip it.
+     * @returns {boolean}
      *
-     * @returns {boolean} entry - True if profile is valid, false if not.
+     * @private
+     */
+    _isBagMetadataFile(filename) {
+        if (filename.startsWith('data/')) {
+            return false;
+        }
+        return this.files[filename] !== undefined ||
+            ['bagit.txt', 'bag-info.txt', 'fetch.txt'].includes(filename) ||
+            Constants.RE_MANIFEST.test(filename) ||
+            Constants.RE_TAG_MANIFEST.test(filename) ||
+            Constants.RE_SPLIT_MANIFEST.test(filename);
+    }
+
+    /**
+     * _validatePayloadPaths normalizes the path of each payload file,
+     * resolving segments like '..' and '.', and adds an error for any
+     * path that lands outside the payload directory. A path such as
+     * data/../bagit.txt gets its own error, since extracting it would
+     * overwrite one of the bag's metadata files.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
      *
      */
-    _validateSerialization() {
-        var validFormat = true;
-        if (!this.disableSerializationCheck) {
-            var checkSerializationFormat = true;
-            var bagIsDirectory = fs.statSync(this.pathToBag).isDirectory();
-            if (this.profile.serialization == 'required') {
-                if (bagIsDirectory) {
-                    this.errors.push(Context.y18n.__("Profile says bag must be serialized, but it is a directory."));
-                    validFormat = false;
+    _validatePayloadPaths() {
+        for (let relPath of this._payloadPaths()) {
+            let normalized = path.posix.normalize(relPath);
+            if (normalized.startsWith('data/')) {
+                continue;
+            }
+            if (this._isBagMetadataFile(normalized)) {
+                this._addError('payloadPaths', `Payload file ${relPath} resolves to ${normalized}, which is reserved for bag metadata.`, relPath);
+            } else {
+                this._addError('payloadPaths', `Payload file ${relPath} resolves to ${normalized}, which is outside the payload directory.`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validatePayloadDirectory adds an error if the bag has no payload
+     * directory. The BagIt spec requires a directory called data, even
+     * in a bag with no payload. Without this check, a bag whose payload
+     * was put somewhere else would pass whenever the profile allows
+     * tag files in other directories, since the validator treats
+     * everything outside data as a tag file.
+     *
+     * Tar files don't always include entries for directories, so any
+     * payload file counts as evidence of the directory. So does a
+     * fetch.txt that lists files, since the payload of a bag that
+     * hasn't been fetched yet may be entirely missing.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validatePayloadDirectory() {
+        if (this._payloadDirectoryFound || this._payloadFileCount() > 0 || this._fetchFilenames().size > 0) {
+            return;
+        }
+        this._addError('payloadDirectory', 'Bag has no payload directory. The BagIt spec requires a directory called data, even if the bag has no payload.');
+    }
+
+    /**
+     * _validateNoExtraneousPayloadFiles checks for files in the data directory
+     * that are not listed in the payload manifest(s). It records offending
+     * files in the Validator.errors array.
+     *
+     * Under BagIt 1.0, every payload file must appear in every payload
+     * manifest. Under earlier versions of the spec, it's enough for each
+     * payload file to appear in one payload manifest. See {@link SpecRules}.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateNoExtraneousPayloadFiles() {
+        //Context.logger.info(`Validator: Looking for extraneous payload files in ${this.pathToBag}`);
+        let manifests = this.payloadManifests();
+        if (SpecRules[this.specVersion].everyFileInEveryManifest) {
+            for(var manifest of manifests) {
+                for (var relPath of this._payloadPaths()) {
+                    if (!this._manifestDigest(manifest, relPath)) {
+                        this._addError('noExtraneousPayloadFiles', `Payload file ${relPath} not found in ${manifest.relDestPath}`, relPath);
+                    }
                 }
-            } else if (this.profile.serialization == 'forbidden') {
-                if (!bagIsDirectory) {
-                    this.errors.push(Context.y18n.__("Profile says bag must not be serialized, but bag is not a directory."));
-                    validFormat = false;
-                    checkSerializationFormat = false;
+            }
+        } else if (manifests.length > 0) {
+            for (let relPath of this._payloadPaths()) {
+                if (!manifests.some(m => this._manifestDigest(m, relPath))) {
+                    this._addError('noExtraneousPayloadFiles', `Payload file ${relPath} is not listed in any payload manifest`, relPath);
+                }
+            }
+        }
+    }
+
+    /**
+     * _validateTagManifestAlgorithms adds a warning for each algorithm
+     * that the bag uses for payload manifests but not for tag manifests,
+     * or the other way around, if the bag has any tag manifests. RFC 8493
+     * says tag manifests should use the same algorithms as payload
+     * manifests. Earlier versions of the spec don't. See {@link SpecRules}.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateTagManifestAlgorithms() {
+        let payloadAlgs = this.manifestAlgorithmsFoundInBag;
+        let tagAlgs = this.tagManifestAlgorithmsFoundInBag;
+        if (!SpecRules[this.specVersion].tagManifestsMatchPayloadAlgorithms || tagAlgs.length == 0) {
+            return;
+        }
+        for (let alg of payloadAlgs.filter(a => !tagAlgs.includes(a)).sort()) {
+            this._addWarning('tagManifestAlgorithms', `Bag has manifest-${alg}.txt but no tagmanifest-${alg}.txt. BagIt ${this.specVersion} says tag manifests should use the same algorithms as payload manifests.`, `manifest-${alg}.txt`);
+        }
+        for (let alg of tagAlgs.filter(a => !payloadAlgs.includes(a)).sort()) {
+            this._addWarning('tagManifestAlgorithms', `Bag has tagmanifest-${alg}.txt but no manifest-${alg}.txt. BagIt ${this.specVersion} says tag manifests should use the same algorithms as payload manifests.`, `tagmanifest-${alg}.txt`);
+        }
+    }
+
+    /**
+     * _validateManifestsAgree checks that all payload manifests list the
+     * same set of files, as BagIt 1.0 requires. It adds an error for
+     * each path that one payload manifest lists and another doesn't.
+     * Paths of payload files in the bag and of files in fetch.txt are
+     * skipped, since _validateNoExtraneousPayloadFiles and
+     * _validateFetch already report those. This catches manifests that
+     * disagree about files that are missing from the bag.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateManifestsAgree() {
+        if (!SpecRules[this.specVersion].everyFileInEveryManifest) {
+            return;
+        }
+        let manifests = this.payloadManifests().sort((a, b) => a.relDestPath.localeCompare(b.relDestPath));
+        for (let manifest of manifests) {
+            for (let filename of manifest.keyValueCollection.keys()) {
+                let bagItFile = this.files[filename];
+                if ((bagItFile !== undefined && bagItFile.isPayloadFile()) || this._streamedPayloadPaths.has(filename) || this._fetchFilenames().has(filename)) {
+                    continue;
                 }
+                for (let other of manifests) {
+                    if (other !== manifest && !this._manifestDigest(other, filename)) {
+                        this._addError('manifestsAgree', `${manifest.relDestPath} lists ${filename}, but ${other.relDestPath} does not.`, filename);
+                    }
+                }
+            }
+        }
+    }
+
+    /**
+     * _validateInventory compares the bag's payload files with the
+     * inventory, if there is one, and adds an error for each file
+     * that's in one but not the other.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateInventory() {
+        if (this.inventory.length == 0) {
+            return;
+        }
+        let expected = new Set(this.inventory);
+        let actual = new Set(this._payloadPaths());
+        for (let relPath of [...expected].sort()) {
+            if (!actual.has(relPath)) {
+                this._addError('inventory', `Inventory lists ${relPath}, which is missing from bag.`, relPath);
+            }
+        }
+        for (let relPath of [...actual].sort()) {
+            if (!expected.has(relPath)) {
+                this._addError('inventory', `Payload file ${relPath} is not in the inventory.`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validateNoEmptyDirectories adds a warning for each empty directory
+     * under the payload directory, if warnOnEmptyDirectories is true and
+     * the bag is a directory.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateNoEmptyDirectories() {
+        if (!this.warnOnEmptyDirectories || !this.readingFromDir()) {
+            return;
+        }
+        let dataDir = path.join(this.pathToBag, 'data');
+        if (!fs.existsSync(dataDir)) {
+            return;
+        }
+        let dirs = [dataDir];
+        while (dirs.length > 0) {
+            let dir = dirs.shift();
+            let entries = fs.readdirSync(dir, { withFileTypes: true });
+            let relPath = path.relative(this.pathToBag, dir).split(path.sep).join('/');
+            if (entries.length == 0 && dir != dataDir) {
+                this._addWarning('emptyDirectories', `Payload directory ${relPath} is empty.`, relPath);
             }
-            if (!bagIsDirectory && checkSerializationFormat) {
-                if (!this._validateSerializationFormat()) {
-                    var ext = path.extname(this.pathToBag);
-                    this.errors.push(Context.y18n.__("Bag has extension %s, but profile says it must be serialized as of one of the following types: %s.", ext, this.profile.acceptSerialization.join(', ')));
-                    validFormat = false;
+            for (let entry of entries.sort((a, b) => a.name < b.name ? -1 : 1)) {
+                if (entry.isDirectory()) {
+                    dirs.push(path.join(dir, entry.name));
                 }
             }
-        } else {
-            Context.logger.info(`Validator: Skipping validation of serialization format because disableSerializationCheck is true.`);
         }
-        return validFormat;
     }
 
     /**
-     * _validateSerializationFormat checks to see if the bag is in an allowed
-     * serialized format. This is called only if necessary.
+     * _validatePathDepth records an error for each file in the bag whose
+     * path is nested more deeply than maxPathDepth, if maxPathDepth is
+     * greater than zero.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validatePathDepth() {
+        if (this.maxPathDepth <= 0) {
+            return;
+        }
+        for (let relPath of this._filePaths().sort()) {
+            let depth = relPath.split('/').length - 1;
+            if (depth > this.maxPathDepth) {
+                this._addError('pathDepth', `File path ${relPath} is ${depth} directories deep, which exceeds the limit of ${this.maxPathDepth}.`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validateWindowsPortability records an error for each file in the
+     * bag whose path can't be extracted on Windows, if
+     * checkWindowsPortability is true.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateWindowsPortability() {
+        if (!this.checkWindowsPortability) {
+            return;
+        }
+        for (let relPath of this._filePaths().sort()) {
+            for (let problem of this._windowsPortabilityProblems(relPath)) {
+                this._addError('windowsPortability', `File path ${relPath} is not portable to Windows: ${problem}`, relPath);
+            }
+        }
+    }
+
+    /**
+     * _validateCaseCollisions records an error for each group of files
+     * and directories whose names differ only by case, if
+     * checkCaseCollisions is true. caseCollisionScope determines whether
+     * names must be in the same directory to collide.
      *
-     * @returns {boolean} entry - True if format is valid, false if not.
+     * This method is private, and it internal operations are
+     * subject to change without notice.
      *
      */
-    _validateSerializationFormat() {
-        var matchesValidExtension = false;
-        for (var mimetype of this.profile.acceptSerialization) {
-            var extensionRegex = Constants.SERIALIZATION_FORMATS[mimetype];
-            if (extensionRegex !== undefined && this.pathToBag.match(extensionRegex)) {
-                matchesValidExtension = true;
+    _validateCaseCollisions() {
+        if (!this.checkCaseCollisions) {
+            return;
+        }
+        let sameDirectoryOnly = this.caseCollisionScope == 'directory';
+        let pathsByKey = {};
+        let seen = new Set();
+        for (let relPath of this._filePaths().sort()) {
+            // Check each directory in the path as well as the file.
+            let parts = relPath.split('/');
+            for (let i = 1; i <= parts.length; i++) {
+                let entryPath = parts.slice(0, i).join('/');
+                if (seen.has(entryPath)) {
+                    continue;
+                }
+                seen.add(entryPath);
+                let name = parts[i - 1].toLowerCase();
+                let key = sameDirectoryOnly ? `${parts.slice(0, i - 1).join('/')}/${name}` : name;
+                if (!pathsByKey[key]) {
+                    pathsByKey[key] = [];
+                }
+                pathsByKey[key].push(entryPath);
+            }
+        }
+        for (let key of Object.keys(pathsByKey).sort()) {
+            let paths = pathsByKey[key];
+            let names = new Set(paths.map(p => path.posix.basename(p)));
+            if (names.size > 1) {
+                this._addError('caseCollision', `File names differ only by case: ${paths.join(', ')}`, paths[0]);
             }
         }
-        return matchesValidExtension;
     }
 
     /**
-     * _validateProfile validates the BagItProfile that will be used to
-     * validate the bag. If the profile itself is not valid, we can't proceed.
-     *
-     * Errors in the BagItProfile will be copied into the validator.errors
-     * list.
+     * _validatePayloadMimeTypes adds an error for each payload file whose
+     * detected MIME type is not in allowedPayloadMimeTypes. If that list
+     * is empty, all types are allowed. Files the validator could not read
+     * aren't checked.
      *
-     * @returns {boolean} entry - True if profile is valid, false if not.
+     * This method is private, and it internal operations are
+     * subject to change without notice.
      *
      */
-    _validateProfile() {
-        if (this.profile == null) {
-            this.errors.push(Context.y18n.__("Cannot validate bag because BagItProfile is missing."));
-            return false;
+    _validatePayloadMimeTypes() {
+        if (this.allowedPayloadMimeTypes.length == 0) {
+            return;
         }
-        if (!this.profile.validate()) {
-            for (let err of Object.values(this.profile.errors)) {
-                this.errors.push(`BagItProfile: ${err}`);
+        for (let relPath of Object.keys(this._payloadMimeTypes).sort()) {
+            if (this._unreadableFiles[relPath]) {
+                continue;
+            }
+            let mimeType = this._payloadMimeTypes[relPath];
+            if (!this.allowedPayloadMimeTypes.includes(mimeType)) {
+                this._addError('mimeType', `Payload file ${relPath} has type ${mimeType}, which is not allowed. [Allowed: ${this.allowedPayloadMimeTypes.join(', ')}]`, relPath);
             }
-            return false;
         }
-        return true;
     }
 
     /**
-     * _readEntry reads a single entry from a TarReader or FileSystemReader.
-     * An entry represents one file within the bag (any type of file: payload,
-     * manifest, tag manifest, or tag file). This method add's the file's metadata
-     * to the Validator.files hash, computes the file's checksums, and parses the
-     * file's contents if necessary.
+     * _validateChangeManifests checks that the payload of a versioned bag
+     * matches the result of applying all of its change manifests in order,
+     * if checkChangeManifests is true. Every file in the payload manifests
+     * must have been added or modified by some change manifest, and files
+     * that were removed must no longer be in the bag.
      *
-     * @param {object} entry - An entry returned by a TarReader or FileSystemReader.
+     * This method is private, and it internal operations are
+     * subject to change without notice.
      *
      */
-    _readEntry(entry) {
-        let validator = this;
-        if (entry.fileStat.isFile()) {
-            var bagItFile = this._addBagItFile(entry);
-            if (Context.slowMotionDelay > 0) {
-                setTimeout(() => {
-                    validator._readFile(bagItFile, entry.stream)
-                }, Context.slowMotionDelay);
-            } else {
-                this._readFile(bagItFile, entry.stream);
+    _validateChangeManifests() {
+        if (!this.checkChangeManifests) {
+            return;
+        }
+        let history = this._replayChanges();
+        if (history.versions == 0) {
+            this._addError('changeManifests', 'Bag has no change manifests. Versioned bags should have changes-1.txt, changes-2.txt, etc.');
+            return;
+        }
+        for (let [relPath, message] of history.problems) {
+            this._addError('changeManifests', message, relPath);
+        }
+        let listed = new Set();
+        for (let manifest of this.payloadManifests()) {
+            manifest.keyValueCollection.keys().forEach(key => listed.add(key));
+        }
+        for (let relPath of [...listed].sort()) {
+            if (history.files[relPath] === undefined && history.removed[relPath] === undefined) {
+                this._addError('changeManifests', `Payload file ${relPath} was not added or modified by any change manifest.`, relPath);
             }
-        } else if (entry.fileStat.isDirectory()) {
-            var relPath = this._cleanEntryRelPath(entry.relPath);
-            if (this.bagRoot == null && relPath == '') {
-                this.bagRoot = entry.relPath.replace(/\/$/, ''); // right relpath for untarring
-            } else if (this.bagRoot == null && relPath == entry.relPath.replace(/\/$/, '')) {
-                this.bagRoot = relPath; // wrong relpath for untarring
+        }
+        for (let relPath of Object.keys(history.files).sort()) {
+            if (!listed.has(relPath)) {
+                this._addError('changeManifests', `${history.files[relPath]} says ${relPath} is in the payload, but it is not in any payload manifest.`, relPath);
+            }
+        }
+        for (let relPath of Object.keys(history.removed).sort()) {
+            if (this._hasFile(relPath)) {
+                this._addError('changeManifests', `${history.removed[relPath]} removes ${relPath}, but it is still in the bag.`, relPath);
             }
-            entry.stream.pipe(new stream.PassThrough());
-        } else {
-            // Skip symlinks, block devices, etc.
-            entry.stream.pipe(new stream.PassThrough());
-            Context.logger.info(`Validator: ${entry.relPath} as is not a regular file`);
         }
     }
 
     /**
-     * _addBagItFile adds a BagItFile to the Validator.files hash, based on
-     * the entry it receives from the reader. At this point, the newly created
-     * BagItFile will have its path and stats info, but no parsed data or
-     * checksums.
-     *
-     * @param {object} entry - An entry returned by a TarReader or FileSystemReader.
-     *
-     * @param {string} entry.relPath - The relative path of the file inside the bag.
-     *
-     * @param {FileStat|fs.Stat} entry.fileStat - An object containing stats info
-     * about the file.
+     * _validateTombstones checks the deleted.txt file of a versioned bag,
+     * if checkChangeManifests is true and the bag has one. Each line of
+     * deleted.txt is the path of a payload file that was deleted. Each
+     * path must be absent from the bag, and a change manifest must show
+     * that it was in the payload of an earlier version.
      *
-     * @returns {BagItFile} The BagItFile created from the entry object.
+     * This method is private, and it internal operations are
+     * subject to change without notice.
      *
      */
-    _addBagItFile(entry) {
-        this.emit('task', new TaskDescription(entry.relPath, 'add'));
-        var relPath = this._cleanEntryRelPath(entry.relPath);
-        var absPath = '';
-        if (!this.readingFromTar()) {
-            absPath = path.join(this.pathToBag, relPath);
-            if (os.platform() === 'win32' && relPath.indexOf("\\") > -1) {
-                relPath = relPath.replace(/\\/g, '/');
-            }
+    _validateTombstones() {
+        if (!this.checkChangeManifests || this._tagFileBytes[tombstoneFile] === undefined) {
+            return;
         }
-        var bagItFile = new BagItFile(absPath, relPath, entry.fileStat);
-        this.files[relPath] = bagItFile;
-        var fileType = BagItFile.getFileType(relPath);
-        //Context.logger.info(`Validator added ${relPath} as ${fileType}`);
-        return bagItFile;
+        let history = this._replayChanges();
+        let lines = Buffer.concat(this._tagFileBytes[tombstoneFile]).toString('utf8').split(/\r?\n/);
+        lines.forEach((line, i) => {
+            let relPath = line.trim();
+            if (relPath == '') {
+                return;
+            }
+            if (!relPath.startsWith('data/')) {
+                this._addError('tombstones', `Line ${i + 1} of ${tombstoneFile} should be a payload path, like data/file.txt.`, tombstoneFile);
+            } else if (this._hasFile(relPath)) {
+                this._addError('tombstones', `${tombstoneFile} lists ${relPath} as deleted, but it is still in the bag.`, relPath);
+            } else if (history.removed[relPath] === undefined) {
+                this._addError('tombstones', `${tombstoneFile} lists ${relPath} as deleted, but no change manifest removes it from an earlier version.`, relPath);
+            }
+        });
     }
 
     /**
-     * _cleanEntryRelPath removes trailing slashes from relPath. When the
-     * validator is reading from a tar file, this also removes the leading
-     * bag name from the path. Since tarred bags must untar to a directory
-     * whose name matches the bag, relative paths within tar files will
-     * always be prefixed with the bag name. To get a true relative path,
-     * we have to change "bagname/data/file.txt" to "data/file.txt".
+     * _replayChanges applies the bag's change manifests in version order
+     * to reconstruct the payload of the latest version. It returns an
+     * object with the following properties:
      *
-     * @param {string} relPath - The relative path, as we got it from the
-     * TarReader or FileSystemReader.
+     * * versions - The number of change manifests in the bag.
+     * * files - The payload files of the latest version. The key is the
+     *   file's relative path, and the value is the name of the change
+     *   manifest that last added or modified it.
+     * * removed - Files that were removed and not added again. The key
+     *   is the file's relative path, and the value is the name of the
+     *   change manifest that removed it.
+     * * problems - A list of [relPath, message] pairs describing changes
+     *   that can't be applied, such as removing a file that doesn't exist.
      *
-     * @returns {string} A clean version of the relative path.
+     * @returns {object}
      *
+     * @private
      */
-    _cleanEntryRelPath(relPath) {
-        var cleanPath = relPath;
-        if (this.readingFromTar()) {
-            var tarFileName = path.basename(this.pathToBag, '.tar');
-            var re = new RegExp("^" + tarFileName + "/");
-            cleanPath = relPath.replace(re, '');
+    _replayChanges() {
+        let history = { versions: 0, files: {}, removed: {}, problems: [] };
+        let changeManifests = Object.keys(this._tagFileBytes)
+            .map(relPath => relPath.match(changeManifestName))
+            .filter(match => match != null)
+            .sort((a, b) => parseInt(a[1], 10) - parseInt(b[1], 10));
+        for (let match of changeManifests) {
+            let changeManifest = match[0];
+            let version = parseInt(match[1], 10);
+            history.versions++;
+            if (version != history.versions) {
+                history.problems.push([changeManifest, `Change manifests skip from version ${history.versions - 1} to ${changeManifest}.`]);
+                history.versions = version;
+            }
+            let lines = Buffer.concat(this._tagFileBytes[changeManifest]).toString('utf8').split(/\r?\n/);
+            lines.forEach((line, i) => {
+                if (line.trim() == '') {
+                    return;
+                }
+                let change = line.trim().match(/^(added|modified|removed)\s+(.+)$/);
+                if (!change) {
+                    history.problems.push([changeManifest, `Line ${i + 1} of ${changeManifest} should be 'added', 'modified', or 'removed', followed by a file path.`]);
+                    return;
+                }
+                let [, action, relPath] = change;
+                let exists = history.files[relPath] !== undefined;
+                if (action == 'added' && exists) {
+                    history.problems.push([relPath, `${changeManifest} adds ${relPath}, which already exists in the previous version.`]);
+                } else if (action != 'added' && !exists) {
+                    history.problems.push([relPath, `${changeManifest} says ${relPath} was ${action}, but it does not exist in the previous version.`]);
+                }
+                if (action == 'removed') {
+                    delete history.files[relPath];
+                    history.removed[relPath] = changeManifest;
+                } else {
+                    history.files[relPath] = changeManifest;
+                    delete history.removed[relPath];
+                }
+            });
         }
-        return cleanPath.replace(/\/$/, '');
+        return history;
     }
 
     /**
-     * _readFile pushes the file's bits through whatever checksum algorithms
-     * the {@link BagItProfile} says we're supposed to validate. It also parses
-     * the contents of the file, if it happens to be a manifest, tag manifest,
-     * or text-based tag file.
+     * _windowsPortabilityProblems returns a list of reasons why relPath
+     * can't be extracted on Windows. The list will be empty if there are
+     * no problems.
      *
-     * Note that the read() method of TarReader and FileSystemReader will not
-     * advance until we've read the entire stream (or until it closes or errors out).
+     * @param {string} relPath - The relative path of a file in the bag.
      *
-     * @param {BagItFile} bagItFile - A file inside the directory or tarball.
+     * @returns {string[]}
      *
-     * @param {ReadStream} readStream - A stream from which we can read
-     * the file's contents.
+     * @private
      */
-    _readFile(bagItFile, readStream) {
-        var validator = this;
-        this._filesChecked += 1;
-        let percentComplete = (this._filesChecked / this._initialFileCount) * 100;
-        this.emit('task', new TaskDescription(bagItFile.relDestPath, 'checksum', '', percentComplete));
-
-        // Get pipes for all of the hash digests we'll need to calculate.
-        // We need to calculate checksums on everything in the bag.
-        var pipes = this._getCryptoHashes(bagItFile)
-
-        // For manifests, tag manifests, and tag files, we need to parse
-        // file contents as well.
-        if (bagItFile.isPayloadManifest() || bagItFile.isTagManifest()) {
-            var manifestParser = new ManifestParser(bagItFile);
-            pipes.push(manifestParser.stream);
-        } else if (bagItFile.isTagFile() && bagItFile.relDestPath.endsWith(".txt")) {
-            var tagFileParser = new TagFileParser(bagItFile);
-            pipes.push(tagFileParser.stream);
+    _windowsPortabilityProblems(relPath) {
+        let problems = [];
+        if (relPath.length > 260) {
+            problems.push(`path is longer than 260 characters`);
         }
-
-        // Push read errors up to where the user can see them.
-        readStream.on('error', function(err) {
-            validator.errors.push(`Read error in ${bagItFile.relDestPath}: ${err.toString()}`)
-        });
-
-        // Now we can do a single read of the file, piping it through
-        // streams for checksum calculations and parsing. This is much
-        // more efficient than doing a seperate read for each, especially
-        // in bags that use multiple digest algorithms.
-        readStream.pause();
-        for (var p of pipes) {
-            readStream.pipe(p);
+        for (let name of relPath.split('/')) {
+            if (windowsReservedName.test(name)) {
+                problems.push(`'${name}' is a reserved name`);
+            }
+            if (windowsIllegalChars.test(name)) {
+                problems.push(`'${name}' contains characters that are not allowed`);
+            }
+            if (name.endsWith('.') || name.endsWith(' ')) {
+                problems.push(`'${name}' ends with a dot or space`);
+            }
+            if (name.length > 255) {
+                problems.push(`'${name}' is longer than 255 characters`);
+            }
         }
-        readStream.resume();
-        //Context.logger.info(`Validator is running checksums for ${bagItFile.relDestPath}`);
+        return problems;
     }
 
     /**
-     * _getCryptoHashes returns a list of prepared cryptographic hashes that
-     * are ready to have bits streamed through them. Each hash includes a
-     * pre-wired 'end' event that assigns the computed checksum to the
-     * BagItFile's checksums hash. For example, a sha256 hash, once the bits
-     * have been pushed through it, will set the following in it's event event:
-     *
-     * @example
-     * bagItFile.checksums['sha256'] = "[computed hex value]";
-     *
-     * @param {BagItFile} bagItFile - A file inside the directory or tarball.
-     * This is the file whose checksums will be computed.
+     * _validateByteOrderMark checks whether bagit.txt begins with a
+     * byte order mark, which BagIt 1.0 forbids. This check does not
+     * apply to earlier versions of the spec. See {@link SpecRules}.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
-     * @returns {Array<crypto.Hash>}
-     *
      */
-    _getCryptoHashes(bagItFile) {
-        let validator = this;
-        let hashes = [];
-        // Put together all of the algorithms we'll need for checksums,
-        // filtering out empty strings and duplicates.
-        let m = this.profile.chooseManifestAlgorithms('manifest');
-        let t = this.profile.chooseManifestAlgorithms('tagmanifest');
-        let f = this.manifestAlgorithmsFoundInBag;
-        let algorithms = new Set(m.concat(t, f).filter(alg => alg != ''));
-        // The done function decreases the validator's internal counter
-        // of how many digests are still begin calculated.
-        let done = function() { validator._hashCompleted() };
-        for (let algorithm of algorithms) {
-            hashes.push(bagItFile.getCryptoHash(algorithm, done));
-            validator._hashesInProgress++;
+    _validateByteOrderMark() {
+        if (this._bagItTxtHasBOM && SpecRules[this.specVersion].bomForbiddenInBagItTxt) {
+            this._addError('byteOrderMark', `bagit.txt must not begin with a byte order mark under BagIt version ${this.specVersion}`, 'bagit.txt');
         }
-        return hashes;
-    }
-
-    _hashCompleted() {
-        this._hashesInProgress--;
     }
 
     /**
-     * _validateUntarDirectory is for tarred bags only. It checks to see
-     * whether the tar file extracts to a directory whose name matches
-     * the bag name, minus the ".tar" extension. If it doesn't untar there,
-     * this method adds an error to the validation results.
-     *
-     * E.g. "myBag.tar" should untar to a directory called "myBag"
-     *
-     * This rule only apples for BagItProfiles where tarDirMustMatchName
-     * is true.
-     *
-     * The official BagIt 1.0 spec at
-     * https://tools.ietf.org/html/draft-kunze-bagit-17#section-2 says:
-     *
-     * `The base directory can have any name.`
+     * _validateDeclaredVersion adds an error if bagit.txt does not
+     * declare a BagIt-Version, which every version of the BagIt spec
+     * requires, unless the profile requires the tag, in which case the
+     * tag checks report it.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
-     * @returns {boolean} - If true, it's ok to proceed with the rest
-     * of the validation. If false, validation stops, because no files
-     * will be in their expected places.
-     *
      */
-    _validateUntarDirectory() {
-        var okToProceed = true;
-        if (this.readingFromTar() && this.profile.tarDirMustMatchName) {
-            var tarFileName = path.basename(this.pathToBag, '.tar');
-            if (this.bagRoot != tarFileName) {
-                this.errors.push(`Bag should untar to directory '${tarFileName}', not '${this.bagRoot}'`);
-                okToProceed = false;
-            }
+    _validateDeclaredVersion() {
+        let bagItTxt = this.files['bagit.txt'];
+        if (bagItTxt === undefined || bagItTxt.keyValueCollection == null || bagItTxt.keyValueCollection.first('BagIt-Version')) {
+            return;
+        }
+        let profileRequiresTag = this.profile.getTagsFromFile('bagit.txt', 'BagIt-Version').some(t => t.required);
+        if (!profileRequiresTag) {
+            this._addError('bagItVersion', 'bagit.txt does not declare BagIt-Version, which the BagIt spec requires.', 'bagit.txt');
         }
-        return okToProceed;
     }
 
     /**
-     * _validateRequiredManifests checks to see if the manifests required by
-     * the {@link BagItProfile} are actually present in the bag. If they're not,
-     * it records the error in the Validator.errors array.
+     * _validateDeclaredEncoding checks the Tag-File-Character-Encoding in
+     * bagit.txt. The BagIt spec requires this tag, so this adds an error
+     * if it's missing, unless the profile requires the tag, in which
+     * case the tag checks report it. If the declared encoding is
+     * something other than UTF-8, this adds a warning or error,
+     * according to nonUtf8EncodingSeverity. Encodings that the
+     * validator doesn't recognize are reported by
+     * _validateTagFileEncoding.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
-     * @param {string} manifestType - The type of manifest to look for.
-     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
-     * {Constants.TAG_MANIFEST}.
-     *
      */
-    _validateRequiredManifests(manifestType) {
-        var manifestList = this.profile.manifestsRequired;
-        if (manifestType === Constants.TAG_MANIFEST) {
-            manifestList = this.profile.tagManifestsRequired;
+    _validateDeclaredEncoding() {
+        if (this.files['bagit.txt'] === undefined) {
+            return;
         }
-        for (var alg of manifestList) {
-            var name = `${manifestType}-${alg}.txt`
-            if(this.files[name] === undefined) {
-                this.errors.push(`Bag is missing required ${manifestType} ${name}`);
+        let declared = this._declaredTagFileEncoding();
+        if (!declared) {
+            let profileRequiresTag = this.profile.getTagsFromFile('bagit.txt', 'Tag-File-Character-Encoding').some(t => t.required);
+            if (!profileRequiresTag) {
+                this._addError('tagFileEncoding', 'bagit.txt does not declare Tag-File-Character-Encoding, which the BagIt spec requires.', 'bagit.txt');
+            }
+            return;
+        }
+        if (!this.nonUtf8EncodingSeverity) {
+            return;
+        }
+        let encoding;
+        try {
+            encoding = new TextDecoder(declared).encoding;
+        } catch (ex) {
+            return;
+        }
+        if (encoding != 'utf-8') {
+            let message = `bagit.txt declares Tag-File-Character-Encoding ${declared}. The BagIt spec recommends UTF-8.`;
+            if (this.nonUtf8EncodingSeverity == 'error') {
+                this._addError('tagFileEncoding', message, 'bagit.txt');
+            } else {
+                this._addWarning('tagFileEncoding', message, 'bagit.txt');
             }
         }
     }
 
-
     /**
-     * _validateAllowedManifests checks to see if the bag contains manifests
-     * not listed in the manifestsAllowed or tagManifestsAllowed list of the
-     * {@link BagItProfile}. This records illegal manifests in the
-     * Validator.errors array.
+     * _validateTagFileEncoding checks that each text tag file can be
+     * decoded using the Tag-File-Character-Encoding declared in bagit.txt.
+     * This uses the WHATWG TextDecoder, which knows all of the common
+     * encodings.
+     *
+     * Single-byte encodings such as ISO-8859-1 can decode any sequence
+     * of bytes, so for those, this also flags tag files that contain
+     * valid UTF-8 multibyte sequences, since that almost always means
+     * the file was written as UTF-8.
+     *
+     * bagit.txt itself is always UTF-8, so this skips it.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
-     * @param {string} manifestType - The type of manifest to look for.
-     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
-     * {Constants.TAG_MANIFEST}.
+     * @returns {Set<string>} The relative paths of tag files that could
+     * not be decoded.
+     *
+     */
+    _validateTagFileEncoding() {
+        let undecodable = new Set();
+        let declared = this._declaredTagFileEncoding();
+        if (!declared) {
+            return undecodable;
+        }
+        let decoder;
+        try {
+            decoder = new TextDecoder(declared, { fatal: true });
+        } catch (ex) {
+            this._addError('tagFileEncoding', `bagit.txt declares unsupported Tag-File-Character-Encoding '${declared}'`, 'bagit.txt');
+            return undecodable;
+        }
+        let isUnicode = decoder.encoding.startsWith('utf-');
+        let utf8Decoder = new TextDecoder('utf-8', { fatal: true });
+        for (let relPath of Object.keys(this._tagFileBytes).sort()) {
+            if (relPath == 'bagit.txt') {
+                continue;
+            }
+            let bytes = Buffer.concat(this._tagFileBytes[relPath]);
+            try {
+                decoder.decode(bytes);
+            } catch (ex) {
+                this._addError('tagFileEncoding', `Tag file ${relPath} cannot be decoded as ${declared}`, relPath);
+                undecodable.add(relPath);
+                continue;
+            }
+            if (!isUnicode && bytes.some(b => b > 0x7F)) {
+                try {
+                    utf8Decoder.decode(bytes);
+                    this._addError('tagFileEncoding', `Tag file ${relPath} contains UTF-8 multibyte characters, but bagit.txt declares ${declared}`, relPath);
+                } catch (ex) {
+                    // Not UTF-8, so it's consistent with the declared encoding.
+                }
+            }
+        }
+        return undecodable;
+    }
+
+    /**
+     * _declaredTagFileEncoding returns the Tag-File-Character-Encoding
+     * from bagit.txt, or undefined if bagit.txt is missing or doesn't
+     * declare one.
+     *
+     * @returns {string}
      *
+     * @private
      */
-    _validateAllowedManifests(manifestType) {
-        let allowed = this.profile.manifestsAllowed;
-        let foundInBag = this.manifestAlgorithmsFoundInBag;
-        if (manifestType === Constants.TAG_MANIFEST) {
-            allowed = this.profile.tagManifestsAllowed;
-            foundInBag = this.tagManifestAlgorithmsFoundInBag;
-        }
-        for (var alg of foundInBag) {
-            if(!allowed.includes(alg)) {
-                this.errors.push(`Bag includes ${manifestType} ${alg}, which is not in the list of allowed ${manifestType}s`);
-            }
+    _declaredTagFileEncoding() {
+        let bagItTxt = this.files['bagit.txt'];
+        if (!bagItTxt || !bagItTxt.keyValueCollection) {
+            return undefined;
         }
+        return bagItTxt.keyValueCollection.first('Tag-File-Character-Encoding');
     }
 
     /**
-     * _validateAllowedTagFiles checks to see if the bag contains tag files
-     * not listed in the tagFilesAllowed list of the
-     * {@link BagItProfile}. This records illegal tag files in the
-     * Validator.errors array.
+     * _decodeTagFiles re-parses tag files using the
+     * Tag-File-Character-Encoding declared in bagit.txt. Tag files may
+     * be read before bagit.txt, especially in tarred bags, so the
+     * {@link TagFileParser} always reads them as UTF-8. When bagit.txt
+     * declares some other encoding, such as ISO-8859-1 or UTF-16, this
+     * decodes each tag file's raw bytes in that encoding and parses
+     * the result again, so that tag values containing accented
+     * characters match the values allowed by the profile.
+     *
+     * bagit.txt itself is always UTF-8, so this leaves it alone. It
+     * also leaves alone tag files that can't be decoded in the declared
+     * encoding. _validateTagFileEncoding reports those.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
      */
-    _validateAllowedTagFiles() {
-        let allowed = this.profile.tagFilesAllowed || [];
-        let tagFiles = this.tagFiles();
-        for (let file of tagFiles) {
-            if (file.relDestPath == 'bagit.txt') {
+    _decodeTagFiles() {
+        let declared = this._declaredTagFileEncoding();
+        if (!declared) {
+            return;
+        }
+        let decoder;
+        try {
+            decoder = new TextDecoder(declared, { fatal: true });
+        } catch (ex) {
+            // Unsupported encoding. _validateTagFileEncoding reports it.
+            return;
+        }
+        if (decoder.encoding == 'utf-8') {
+            return;
+        }
+        for (let relPath of Object.keys(this._tagFileBytes)) {
+            let bagItFile = this.files[relPath];
+            if (relPath == 'bagit.txt' || bagItFile === undefined || bagItFile.keyValueCollection == null) {
                 continue;
             }
-            let matchesAllowedPattern = false;
-            let fileWasTested = false;
-            for (let pattern of allowed) {
-                if (Util.isEmpty(pattern) || pattern.trim() == '*') {
-                    continue;
-                }
-                if (minimatch(file.relDestPath, pattern)) {
-                    matchesAllowedPattern = true;
-                }
-                fileWasTested = true;
-            }
-            //console.log(`${file.relDestPath} Tested: ${fileWasTested}, Allowed: ${matchesAllowedPattern}`)
-            if (fileWasTested && !matchesAllowedPattern) {
-                this.errors.push(`Tag file ${file.relDestPath} is not in the list of allowed tag files.`);
+            let text;
+            try {
+                text = decoder.decode(Buffer.concat(this._tagFileBytes[relPath]));
+            } catch (ex) {
+                continue;
             }
+            bagItFile.keyValueCollection = new KeyValueCollection();
+            let tagFileParser = new TagFileParser(bagItFile, this.profile.tagDelimiter || ':');
+            tagFileParser.parse(text);
+            this._tagFileParseErrors[relPath] = tagFileParser.errors;
         }
     }
 
     /**
-     * _validateManifestEntries checks to see that the checksum entries in a
-     * payload manifest or tag manifest match the actual computed digests of
-     * the files in the bag. It records mismatches in the Validator.errors
-     * array.
+     * _validateTagFileFormat checks the text format of each tag file.
+     * It first checks that the tag files decode under the
+     * Tag-File-Character-Encoding declared in bagit.txt (see
+     * _validateTagFileEncoding), then checks that each tag file that
+     * decoded cleanly uses a single line terminator throughout. The
+     * BagIt spec allows LF, CR, or CRLF, but a file that mixes them
+     * was usually edited by hand on different systems, and some
+     * parsers will read it incorrectly.
      *
      * This method is private, and it internal operations are
      * subject to change without notice.
      *
-     * @param {string} manifestType - The type of manifest to look for.
-     * This should be either {@link Constants.PAYLOAD_MANIFEST} or
-     * {Constants.TAG_MANIFEST}.
-     *
      */
-    _validateManifestEntries(manifestType) {
-        var manifests = this.payloadManifests();
-        if (manifestType === Constants.TAG_MANIFEST) {
-            manifests = this.tagManifests();
+    _validateTagFileFormat() {
+        let undecodable = this._validateTagFileEncoding();
+        let encoding = 'utf-8';
+        let declared = this._declaredTagFileEncoding();
+        try {
+            if (declared) {
+                encoding = new TextDecoder(declared).encoding;
+            }
+        } catch (ex) {
+            // Already reported by _validateTagFileEncoding.
         }
-        //Context.logger.info(`Validator: Validating ${manifests.length} ${manifestType}s`);
-        for(var manifest of Object.values(manifests)) {
-            //Context.logger.info(`Validator: Validating ${manifest.relDestPath}`);
-            var basename = path.basename(manifest.relDestPath, '.txt');
-            var algorithm = basename.split('-')[1];
-            for (var filename of manifest.keyValueCollection.keys()) {
-                var bagItFile = this.files[filename];
-                if (bagItFile === undefined) {
-                    this.errors.push(`File '${filename}' in ${manifest.relDestPath} is missing from bag.`);
-                    continue;
-                }
-                var checksumInManifest = manifest.keyValueCollection.first(filename);
-                var calculatedChecksum = bagItFile.checksums[algorithm];
-                if (checksumInManifest != calculatedChecksum) {
-                    this.errors.push(`Bad ${algorithm} digest for '${filename}': manifest says '${checksumInManifest}', file digest is '${calculatedChecksum}'.`);
-                }
+        for (let relPath of Object.keys(this._tagFileBytes).sort()) {
+            if (undecodable.has(relPath)) {
+                continue;
+            }
+            let decoder = new TextDecoder(relPath == 'bagit.txt' ? 'utf-8' : encoding);
+            let text = decoder.decode(Buffer.concat(this._tagFileBytes[relPath]));
+            let counts = this._lineEndingCounts(text);
+            let found = Object.keys(counts).filter(name => counts[name] > 0);
+            if (found.length > 1) {
+                let summary = found.map(name => `${counts[name]} ${name}`).join(', ');
+                this._addError('lineEndings', `Tag file ${relPath} mixes line endings: ${summary}.`, relPath);
             }
         }
     }
 
     /**
-     * _validateNoExtraneousPayloadFiles checks for files in the data directory
-     * that are not listed in the payload manifest(s). It records offending
-     * files in the Validator.errors array.
+     * _lineEndingCounts returns the number of CRLF, LF, and CR line
+     * terminators in text.
      *
-     * This method is private, and it internal operations are
-     * subject to change without notice.
+     * @param {string} text - The text of a tag file.
      *
+     * @returns {object}
+     *
+     * @private
      */
-    _validateNoExtraneousPayloadFiles() {
-        //Context.logger.info(`Validator: Looking for extraneous payload files in ${this.pathToBag}`);
-        for(var manifest of this.payloadManifests()) {
-            for (var f of this.payloadFiles()) {
-                if (!manifest.keyValueCollection.first(f.relDestPath)) {
-                    this.errors.push(`Payload file ${f.relDestPath} not found in ${manifest.relDestPath}`);
-                }
-            }
+    _lineEndingCounts(text) {
+        let counts = { CRLF: 0, LF: 0, CR: 0 };
+        for (let match of text.match(/\r\n|\r|\n/g) || []) {
+            counts[{ '\r\n': 'CRLF', '\n': 'LF', '\r': 'CR' }[match]]++;
         }
+        return counts;
     }
 
     /**
      * _validateTags ensures that all required tag files are present, that
      * all required tags are present, and that all tags have valid values
-     * if valid values were defined in the {@link BagItProfile}. This method
+     * if valid values were defined in the {@link BagItProfile}. Required
+     * tag files include those that contain tags from the profile and
+     * those in the profile's tagFilesRequired list. This method
      * records all the problems it finds in the Validator.errors array.
      *
      * This method is private, and it internal operations are
@@ -892,15 +5322,40 @@ class Validator extends EventEmitter {
             //Context.logger.info(`Validator: Validating tags in ${filename}`);
             var tagFile = this.files[filename];
             if (tagFile === undefined) {
-                this.errors.push(`Required tag file ${filename} is missing`);
+                this._addError('tags', `Required tag file ${filename} is missing`, filename);
                 continue;
             }
             if (tagFile.keyValueCollection == null) {
-                this.errors.push(`Tag file ${filename} has no data`);
+                this._addError('tags', `Tag file ${filename} has no data`, filename);
                 continue;
             }
             this._validateTagsInFile(filename, tagFile);
         }
+        for (let filename of this.profile.tagFilesRequired || []) {
+            if (requiredTags[filename] === undefined && this.files[filename] === undefined) {
+                this._addError('tags', `Required tag file ${filename} is missing`, filename);
+            }
+        }
+    }
+
+    /**
+     * _validateRequiredTagFilesParse adds an error for each line that the
+     * {@link TagFileParser} could not parse in tag files that contain
+     * required tags. A tag on a line the parser skipped would otherwise
+     * show up only as a missing tag, which doesn't point to the real
+     * problem.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateRequiredTagFilesParse() {
+        let requiredFiles = new Set(this.profile.tags.filter(t => t.required).map(t => t.tagFile));
+        for (let filename of [...requiredFiles].sort()) {
+            for (let err of this._tagFileParseErrors[filename] || []) {
+                this._addError('tagFileParse', `Required tag file ${filename} failed to parse at line ${err.line}: ${err.message}`, filename);
+            }
+        }
     }
 
     /**
@@ -908,7 +5363,9 @@ class Validator extends EventEmitter {
      * are present, that all required tags are present, and that all tags have
      * valid values if valid values were defined in the {@link BagItProfile}.
      * This method records all the problems it finds in the Validator.errors
-     * array.
+     * array. When a tag appears more than once, each of its values is
+     * checked, even if the tag is not repeatable, so one bad value
+     * doesn't hide another.
      *
      * @param {string} filename - The name of the tag file. E.g. bag-info.txt.
      *
@@ -922,20 +5379,171 @@ class Validator extends EventEmitter {
         var requiredTags = this.profile.tagsGroupedByFile();
         for (var tagDef of requiredTags[filename]) {
             var parsedTagValues = tagFile.keyValueCollection.all(tagDef.tagName);
+            var required = tagDef.required || this._conditionallyRequired(tagDef);
             if (parsedTagValues == null) {
                 // Tag was not present at all.
                 if (tagDef.required) {
-                    this.errors.push(`Required tag ${tagDef.tagName} is missing from ${filename}`);
+                    this._addError('tags', `Required tag ${tagDef.tagName} is missing from ${filename}`, filename);
+                } else if (required) {
+                    this._addError('tags', `Required tag ${tagDef.tagName} is missing from ${filename}. It is required when ${this._describeConditions(tagDef)}.`, filename);
                 }
                 continue;
             }
+            if (!tagDef.repeatable && parsedTagValues.length > 1) {
+                this._addError('tags', `Tag '${tagDef.tagName}' appears ${parsedTagValues.length} times in ${filename}, but the profile allows it only once.`, filename);
+            }
             for (var value of parsedTagValues) {
-                if (tagDef.required && value == '') {
-                    this.errors.push(`Value for tag '${tagDef.tagName}' in ${filename} is missing.`);
+                if (required && value == '') {
+                    this._addError('tags', `Value for tag '${tagDef.tagName}' in ${filename} is missing.`, filename);
+                    continue;
+                }
+                var allowedValues = this._allowedValues(tagDef);
+                if (Array.isArray(allowedValues) && allowedValues.length > 0 && !tagDef.isAllowedValue(value, allowedValues)) {
+                    this._addError('tags', `Tag '${tagDef.tagName}' in ${filename} contains illegal value '${value}'. [Allowed: ${allowedValues.join(', ')}]`, filename);
+                } else if (value != '' && !tagDef.hasValidFormat(value)) {
+                    this._addError('tags', `Tag '${tagDef.tagName}' in ${filename} has value '${value}', which is not a valid ${tagDef.formatDescription()}.`, filename);
+                } else if (value != '' && !(allowedValues && allowedValues.length > 0) && !tagDef.hasValidPattern(value)) {
+                    this._addError('tags', `Value '${value}' for tag '${tagDef.tagName}' in ${filename} does not match required pattern '${tagDef.pattern}'.`, filename);
+                }
+            }
+        }
+    }
+
+    /**
+     * _conditionallyRequired returns true if the bag meets all of the
+     * conditions in tagDef.requiredWhen. Each condition is met if any
+     * tag file in the bag has the named tag with the specified value.
+     * Returns false if tagDef has no conditions.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     * @param {TagDefinition} tagDef - The tag definition.
+     *
+     * @returns {boolean}
+     */
+    _conditionallyRequired(tagDef) {
+        let conditions = Object.entries(tagDef.requiredWhen || {});
+        if (conditions.length == 0) {
+            return false;
+        }
+        let tagFiles = this.tagFiles().filter(f => f.keyValueCollection != null);
+        return conditions.every(([tagName, value]) => {
+            return tagFiles.some(f => (f.keyValueCollection.all(tagName) || []).includes(value));
+        });
+    }
+
+    /**
+     * _validateSourceOrganization checks that the Source-Organization
+     * tag in bag-info.txt matches the sourceOrganization in the
+     * profile's {@link BagItProfileInfo}, if the profile's
+     * requireSourceOrganizationMatch is true. Leading and trailing
+     * whitespace is ignored, but case is not. If bag-info.txt has no
+     * Source-Organization tag, this adds an error too, since there's
+     * nothing to match.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateSourceOrganization() {
+        let expected = (this.profile.bagItProfileInfo.sourceOrganization || '').trim();
+        if (!this.profile.requireSourceOrganizationMatch || expected == '') {
+            return;
+        }
+        let bagInfo = this.files['bag-info.txt'];
+        let values = [];
+        if (bagInfo && bagInfo.keyValueCollection) {
+            values = bagInfo.keyValueCollection.all('Source-Organization') || [];
+        }
+        if (values.length == 0) {
+            this._addError('sourceOrganization', `Profile requires Source-Organization to be '${expected}', but bag-info.txt has no Source-Organization tag.`, 'bag-info.txt');
+        }
+        for (let value of values) {
+            if (value.trim() != expected) {
+                this._addError('sourceOrganization', `Source-Organization '${value}' in bag-info.txt does not match the profile's source organization '${expected}'.`, 'bag-info.txt', expected, value);
+            }
+        }
+    }
+
+    /**
+     * _describeConditions returns a readable description of the
+     * conditions in tagDef.requiredWhen, such as
+     * "Disposition is 'Retain'", for use in error messages.
+     *
+     * @param {TagDefinition} tagDef - The tag definition.
+     *
+     * @returns {string}
+     *
+     * @private
+     */
+    _describeConditions(tagDef) {
+        return Object.entries(tagDef.requiredWhen || {})
+            .map(([tagName, value]) => `${tagName} is '${value}'`)
+            .join(' and ');
+    }
+
+    /**
+     * _validateTagOrder checks that tags listed in the profile's
+     * requiredTagOrder appear in the specified relative order within
+     * their tag files. It records only the first out-of-order tag in
+     * each file, since one misplaced tag often makes the rest look out
+     * of order too.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateTagOrder() {
+        for (let [filename, order] of Object.entries(this.profile.requiredTagOrder || {})) {
+            let tagFile = this.files[filename];
+            if (tagFile === undefined || tagFile.keyValueCollection == null) {
+                // _validateTags reports missing tag files.
+                continue;
+            }
+            let keys = tagFile.keyValueCollection.keys();
+            let prevTag = null;
+            let prevIndex = -1;
+            for (let tagName of order) {
+                let index = keys.indexOf(tagName);
+                if (index < 0) {
                     continue;
                 }
-                if (Array.isArray(tagDef.values) && tagDef.values.length > 0 && !Util.listContains(tagDef.values, value)) {
-                    this.errors.push(`Tag '${tagDef.tagName}' in ${filename} contains illegal value '${value}'. [Allowed: ${tagDef.values.join(', ')}]`);
+                if (index < prevIndex) {
+                    this._addError('tagOrder', `Tag '${tagName}' in ${filename} must appear after '${prevTag}'`, filename);
+                    break;
+                }
+                prevTag = tagName;
+                prevIndex = index;
+            }
+        }
+    }
+
+    /**
+     * _validateNoControlCharacters checks the values of all tags in all
+     * parsed tag files for control characters, such as NUL, tab, or
+     * escape. These tend to break XML and JSON serialization of the
+     * bag's metadata downstream. The tag file parser has already joined
+     * continuation lines and trimmed line endings, so any control
+     * characters that remain are part of the value.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateNoControlCharacters() {
+        let controlChars = /[\x00-\x1F\x7F-\x9F]/g;
+        for (let tagFile of this.tagFiles()) {
+            if (tagFile.keyValueCollection == null) {
+                continue;
+            }
+            for (let tagName of tagFile.keyValueCollection.keys()) {
+                for (let value of tagFile.keyValueCollection.all(tagName)) {
+                    // Show control characters as hex escapes, e.g. \x00
+                    let printable = value.replace(controlChars, c => '\\x' + c.charCodeAt(0).toString(16).toUpperCase().padStart(2, '0'));
+                    if (printable != value) {
+                        this._addError('controlCharacters', `Tag '${tagName}' in ${tagFile.relDestPath} contains control characters: '${printable}'`, tagFile.relDestPath);
+                    }
                 }
             }
         }
@@ -960,20 +5568,25 @@ class Validator extends EventEmitter {
             let oxum = bagInfo.keyValueCollection.first("Payload-Oxum");
             if (oxum) {
                 found = true;
-                let parts = oxum.split('.');
+                if (!/^\d+\.\d+$/.test(oxum.trim())) {
+                    this._addError('payloadOxum', `Payload-Oxum '${oxum}' is not in the form OctetCount.StreamCount.`, 'bag-info.txt');
+                    return;
+                }
+                let parts = oxum.trim().split('.');
                 let oxumBytes = parseInt(parts[0], 10);
                 let oxumFiles = parseInt(parts[1], 10);
-                let byteCount = 0;
-                let fileCount = 0;
-                for (let f of this.payloadFiles()) {
-                    fileCount += 1;
-                    byteCount += Number(f.size);
+                let byteCount = this.payloadByteCount();
+                let fileCount = this._payloadFileCount();
+                if (this.files['fetch.txt'] !== undefined && (fileCount == 0 || this._unfetchedEntries().length > 0)) {
+                    // Some or all of the payload must be fetched.
+                    this._validateFetchOxum(oxumBytes, oxumFiles);
+                    return;
                 }
                 if (oxumFiles != fileCount) {
-                    this.errors.push(`Payload-Oxum says there should be ${oxumFiles} files in the payload, but validator found ${fileCount}.`);
+                    this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumFiles} files in the payload, but validator found ${fileCount}.`, 'bag-info.txt', oxumFiles, fileCount);
                 }
                 if (oxumBytes != byteCount) {
-                    this.errors.push(`Payload-Oxum says there should be ${oxumBytes} bytes in the payload, but validator found ${byteCount}.`);
+                    this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumBytes} bytes in the payload, but validator found ${byteCount}.`, 'bag-info.txt', oxumBytes, byteCount);
                 }
             }
         }
@@ -982,6 +5595,222 @@ class Validator extends EventEmitter {
         }
     }
 
+    /**
+     * _validateFetchOxum checks the Payload-Oxum of a bag whose payload
+     * is partly or wholly listed in fetch.txt. The expected counts are
+     * the payload files present in the bag plus the fetch.txt entries
+     * for files that aren't. If any of those entries has an unknown
+     * length ('-'), the oxum can't be computed, and this adds a warning
+     * instead.
+     *
+     * @param {number} oxumBytes - The byte count from Payload-Oxum.
+     *
+     * @param {number} oxumFiles - The file count from Payload-Oxum.
+     *
+     * @private
+     */
+    _validateFetchOxum(oxumBytes, oxumFiles) {
+        let entries = this._unfetchedEntries();
+        let unknown = entries.filter(entry => entry.length == '-');
+        if (unknown.length > 0) {
+            this._addWarning('payloadOxum', `Cannot check Payload-Oxum against fetch.txt, because ${unknown.length} fetch.txt entries have unknown length '-'.`, 'fetch.txt');
+            return;
+        }
+        let fileCount = this._payloadFileCount();
+        let byteCount = entries.reduce((total, entry) => total + parseInt(entry.length, 10), 0);
+        if (fileCount == 0) {
+            if (oxumFiles != entries.length) {
+                this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumFiles} files in the payload, but fetch.txt lists ${entries.length}.`, 'fetch.txt');
+            }
+            if (oxumBytes != byteCount) {
+                this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumBytes} bytes in the payload, but fetch.txt lengths add up to ${byteCount}.`, 'fetch.txt');
+            }
+            return;
+        }
+        fileCount += entries.length;
+        byteCount += this.payloadByteCount();
+        if (oxumFiles != fileCount) {
+            this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumFiles} files in the payload, but the bag and fetch.txt together have ${fileCount}.`, 'bag-info.txt', oxumFiles, fileCount);
+        }
+        if (oxumBytes != byteCount) {
+            this._addError('payloadOxum', `Payload-Oxum says there should be ${oxumBytes} bytes in the payload, but the bag and fetch.txt together have ${byteCount}.`, 'bag-info.txt', oxumBytes, byteCount);
+        }
+    }
+
+    /**
+     * _parseFetchTxt returns the entries in fetch.txt as a list of
+     * objects with url, length, filename, and lineNumber properties.
+     * Each line of fetch.txt has the format "URL LENGTH FILENAME", where
+     * LENGTH is either a number of bytes or '-' if the length is unknown.
+     * This skips blank lines, and lines that don't match that format.
+     * If you pass in a malformed array, this pushes an object with
+     * lineNumber and line properties onto it for each line it skips
+     * because of its format.
+     *
+     * @param {Array<object>} [malformed] - Optional list to collect
+     * malformed lines.
+     *
+     * @returns {Array<object>}
+     *
+     * @private
+     */
+    _parseFetchTxt(malformed) {
+        let entries = [];
+        let chunks = this._tagFileBytes['fetch.txt'];
+        if (!chunks) {
+            return entries;
+        }
+        let lines = Buffer.concat(chunks).toString('utf8').split(/\r?\n/);
+        for (let i = 0; i < lines.length; i++) {
+            let line = lines[i];
+            if (line.trim() == '') {
+                continue;
+            }
+            let match = line.trim().match(/^([a-zA-Z][a-zA-Z0-9+.-]*:\S+)\s+(\d+|-)\s+(.+)$/);
+            if (match) {
+                entries.push({ url: match[1], length: match[2], filename: ManifestParser.decodePath(match[3]), lineNumber: i + 1 });
+            } else if (malformed) {
+                malformed.push({ lineNumber: i + 1, line: line });
+            }
+        }
+        return entries;
+    }
+
+    /**
+     * _fetchFilenames returns a Set of the file names listed in
+     * fetch.txt. The set is empty if the bag has no fetch.txt.
+     *
+     * @returns {Set<string>}
+     *
+     * @private
+     */
+    _fetchFilenames() {
+        return new Set(this._parseFetchTxt().map(entry => entry.filename));
+    }
+
+    /**
+     * _unfetchedEntries returns the fetch.txt entries for payload
+     * files that are not physically present in the bag.
+     *
+     * @returns {Array<object>}
+     *
+     * @private
+     */
+    _unfetchedEntries() {
+        return this._parseFetchTxt().filter(entry => entry.filename.startsWith('data/') && !this._hasFile(entry.filename));
+    }
+
+    /**
+     * _validateFetch checks the contents of fetch.txt, if the bag has
+     * one. It adds an error if the profile doesn't allow fetch.txt, for
+     * each line that is not in the form "URL LENGTH FILENAME", for each
+     * file name outside the payload directory, and for each fetched file
+     * that's missing from any of the payload manifests. Files listed in
+     * fetch.txt may be absent from the payload directory, but the
+     * BagIt spec says they must still appear in every payload manifest.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateFetch() {
+        if (this.files['fetch.txt'] === undefined) {
+            return;
+        }
+        if (!this.profile.allowFetchTxt) {
+            this._addError('fetch', `Bag contains fetch.txt, but the profile does not allow it.`, 'fetch.txt');
+        }
+        let malformed = [];
+        let entries = this._parseFetchTxt(malformed);
+        for (let bad of malformed) {
+            this._addError('fetch', `Line ${bad.lineNumber} of fetch.txt is not in the form 'URL LENGTH FILENAME': ${bad.line}`, 'fetch.txt');
+        }
+        let manifests = this.payloadManifests();
+        for (let entry of entries) {
+            if (!entry.filename.startsWith('data/')) {
+                this._addError('fetch', `Line ${entry.lineNumber} of fetch.txt lists ${entry.filename}, which is outside the payload directory.`, 'fetch.txt');
+                continue;
+            }
+            for (let manifest of manifests) {
+                if (!this._manifestDigest(manifest, entry.filename)) {
+                    this._addError('fetch', `File ${entry.filename} in fetch.txt is not listed in ${manifest.relDestPath}.`, entry.filename);
+                }
+            }
+        }
+    }
+
+    /**
+     * _validatePayloadSize checks that the total size of the payload is
+     * within the profile's minPayloadSize and maxPayloadSize, and that
+     * the total size of all the files in the bag does not exceed the
+     * profile's maxBagSize. Zero means there is no limit. The sizes come
+     * from the entries the reader found, so this works the same way for
+     * tarred bags as for directories, without extracting anything.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validatePayloadSize() {
+        let maxPayloadSize = this.profile.maxPayloadSize;
+        let minPayloadSize = this.profile.minPayloadSize;
+        let byteCount = this.payloadByteCount();
+        if (maxPayloadSize && byteCount > maxPayloadSize) {
+            this._addError('payloadSize', `Payload contains ${byteCount} bytes, which exceeds the profile's limit of ${maxPayloadSize} bytes.`, '', maxPayloadSize, byteCount);
+        }
+        if (minPayloadSize && byteCount < minPayloadSize) {
+            this._addError('payloadSize', `Payload contains ${byteCount} bytes, which is less than the profile's minimum of ${minPayloadSize} bytes.`, '', minPayloadSize, byteCount);
+        }
+        let maxBagSize = this.profile.maxBagSize;
+        if (!maxBagSize) {
+            return;
+        }
+        let bagSize = this._streamedPayloadBytes;
+        for (let [relPath, f] of Object.entries(this.files)) {
+            // Payload files mapped to blobs share the blobs' bytes.
+            if (this._blobMappings[relPath] === undefined) {
+                bagSize += Number(f.size);
+            }
+        }
+        if (bagSize > maxBagSize) {
+            this._addError('payloadSize', `Bag contains ${bagSize} bytes, which exceeds the profile's limit of ${maxBagSize} bytes.`, '', maxBagSize, bagSize);
+        }
+    }
+
+    /**
+     * _validateMetadataSize checks the sizes of the bag's tag files
+     * against the profile's maxTagFileSize and maxTotalMetadataSize.
+     * maxTagFileSize applies to each tag file for which the profile
+     * defines tags. maxTotalMetadataSize applies to the combined size of
+     * all tag files in the bag, not including manifests. Zero means
+     * there is no limit.
+     *
+     * This method is private, and it internal operations are
+     * subject to change without notice.
+     *
+     */
+    _validateMetadataSize() {
+        let maxTagFileSize = this.profile.maxTagFileSize;
+        if (maxTagFileSize) {
+            for (let filename of Object.keys(this.profile.tagsGroupedByFile()).sort()) {
+                let tagFile = this.files[filename];
+                if (tagFile && Number(tagFile.size) > maxTagFileSize) {
+                    this._addError('metadataSize', `Tag file ${filename} contains ${tagFile.size} bytes, which exceeds the profile's limit of ${maxTagFileSize} bytes per tag file.`, filename);
+                }
+            }
+        }
+        let maxTotalMetadataSize = this.profile.maxTotalMetadataSize;
+        if (maxTotalMetadataSize) {
+            let byteCount = 0;
+            for (let f of this.tagFiles()) {
+                byteCount += Number(f.size);
+            }
+            if (byteCount > maxTotalMetadataSize) {
+                this._addError('metadataSize', `Tag files contain ${byteCount} bytes, which exceeds the profile's limit of ${maxTotalMetadataSize} bytes for all metadata.`);
+            }
+        }
+    }
+
 }
 
 module.exports.Validator = Validator;
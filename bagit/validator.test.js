@@ -1,10 +1,23 @@
 const { BagItProfile } = require('./bagit_profile');
+const { Constants } = require('../core/constants');
 const { Context } = require('../core/context');
+const crypto = require('crypto');
 const FileSystemReader = require('../plugins/formats/read/file_system_reader');
+const { FixityVerifier } = require('./fixity_verifier');
+const { ManifestParser } = require('./manifest_parser');
+const fs = require('fs');
+const os = require('os');
 const path = require('path');
+const { PassThrough, Transform } = require('stream');
+const { SignatureVerifier } = require('./signature_verifier');
 const TarReader = require('../plugins/formats/read/tar_reader');
+const { TagDefinition } = require('./tag_definition');
+const { TagFileParser } = require('./tag_file_parser');
 const { TestUtil } = require('../core/test_util');
+const { Util } = require('../core/util');
 const { Validator } = require('./validator');
+const { ValueResolver } = require('./value_resolver');
+const ZipReader = require('../plugins/formats/read/zip_reader');
 
 test('Constructor sets initial properties', () => {
     let profile = new BagItProfile();
@@ -23,6 +36,8 @@ test('Constructor sets initial properties', () => {
     expect(validator.tagManifests.length).toEqual(0);
     expect(validator.errors).not.toBeNull();
     expect(validator.errors.length).toEqual(0);
+    expect(validator.warnings).toEqual([]);
+    expect(validator.results).toEqual([]);
     expect(validator.readingFromTar()).toEqual(true);
 });
 
@@ -90,6 +105,9 @@ test('fileExtension()', () => {
     validator.pathToBag = "path/to/bag.tar.gz";
     expect(validator.fileExtension()).toEqual(".tar.gz");
 
+    validator.pathToBag = "path/to/bag.tgz";
+    expect(validator.fileExtension()).toEqual(".tgz");
+
     validator.pathToBag = "path/to/bag.zip";
     expect(validator.fileExtension()).toEqual(".zip");
 
@@ -105,6 +123,10 @@ test('getNewReader()', () => {
     validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good");
     reader = validator.getNewReader();
     expect(reader instanceof FileSystemReader).toEqual(true);
+
+    validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good.zip");
+    reader = validator.getNewReader();
+    expect(reader instanceof ZipReader).toEqual(true);
 });
 
 // --------- FROM HERE DOWN, TEST ACTUAL BAGS ----------- //
@@ -175,6 +197,29 @@ test('Validator accepts valid APTrust bag with additional tags', done => {
     validator.validate();
 });
 
+// This test uses the ZipReader instead of the TarReader.
+test('Validator accepts valid zipped APTrust bag', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good.zip");
+    validator.profile.acceptSerialization = ['application/tar', 'application/zip'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.bagRoot).toEqual("example.edu.sample_good");
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/datastream-DC",
+            "data/datastream-MARC",
+            "data/datastream-RELS-EXT",
+            "data/datastream-descMetadata"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
 // This test uses the FileSystemReader instead of the TarReader.
 test('Validator emits expected events for untarred APTrust bag', done => {
 
@@ -250,12 +295,14 @@ test('Validator rejects bad DPN bag', done => {
 test('Validator identifies errors in bad APTrust bag', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
     let expected = [
+        "Payload manifests list different numbers of files: manifest-md5.txt has 4, manifest-sha256.txt has 5.",
         "Bad sha256 digest for 'data/datastream-descMetadata': manifest says 'This-checksum-is-bad-on-purpose.-The-validator-should-catch-it!!', file digest is 'cf9cbce80062932e10ee9cd70ec05ebc24019deddfea4e54b8788decd28b4bc7'.",
         "File 'data/file-not-in-bag' in manifest-sha256.txt is missing from bag.",
         "Bad md5 digest for 'custom_tags/tracked_tag_file.txt': manifest says '00000000000000000000000000000000', file digest is 'dafbffffc3ed28ef18363394935a2651'.",
         "File 'custom_tags/tag_file_xyz.pdf' in tagmanifest-md5.txt is missing from bag.",
         "Bad sha256 digest for 'custom_tags/tracked_tag_file.txt': manifest says '0000000000000000000000000000000000000000000000000000000000000000', file digest is '3f2f50c5bde87b58d6132faee14d1a295d115338643c658df7fa147e2296ccdd'.",
         "File 'custom_tags/tag_file_xyz.pdf' in tagmanifest-sha256.txt is missing from bag.",
+        "manifest-sha256.txt lists data/file-not-in-bag, but manifest-md5.txt does not.",
         "Tag 'Access' in aptrust-info.txt contains illegal value 'acksess'. [Allowed: Consortia, Institution, Restricted]",
         "Tag 'Storage-Option' in aptrust-info.txt contains illegal value 'Cardboard-Box'. [Allowed: Standard, Glacier-OH, Glacier-OR, Glacier-VA, Glacier-Deep-OH, Glacier-Deep-OR, Glacier-Deep-VA, Wasabi-VA, Wasabi-OR]",
         "Value for tag 'Title' in aptrust-info.txt is missing."
@@ -273,6 +320,42 @@ test('Validator identifies errors in bad APTrust bag', done => {
     validator.validate();
 });
 
+test('structuredErrors() describes each error', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    validator.on('end', function() {
+        let errors = validator.structuredErrors();
+        expect(errors.map(e => e.message)).toEqual(validator.errors);
+        expect(errors.every(e => e.severity == 'error')).toBe(true);
+        let checksumErrors = errors.filter(e => e.check == 'checksums');
+        expect(checksumErrors.length).toEqual(3);
+        expect(checksumErrors[0].filePath).toEqual('data/datastream-descMetadata');
+        expect(checksumErrors[0].expected).toEqual('This-checksum-is-bad-on-purpose.-The-validator-should-catch-it!!');
+        expect(checksumErrors[0].actual).toEqual('cf9cbce80062932e10ee9cd70ec05ebc24019deddfea4e54b8788decd28b4bc7');
+        expect(checksumErrors[0].actualSize).toEqual(6191);
+        expect(errors.find(e => e.check == 'manifestEntries').actualSize).toBeNull();
+        let missing = errors.filter(e => e.check == 'manifestEntries').map(e => e.filePath);
+        expect(missing).toEqual(['data/file-not-in-bag', 'custom_tags/tag_file_xyz.pdf', 'custom_tags/tag_file_xyz.pdf']);
+        expect(errors.find(e => e.check == 'manifestEntries').expected).toEqual('');
+        done();
+    });
+    validator.validate();
+});
+
+test('structuredErrors() includes expected and actual Payload-Oxum values', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.on('end', function() {
+        let errors = validator.structuredErrors();
+        expect(errors.length).toEqual(1);
+        expect(errors[0].check).toEqual('payloadOxum');
+        expect(errors[0].expected).toEqual('41');
+        expect(errors[0].actual).toEqual('43');
+        done();
+    });
+    let payloadByteCount = validator.payloadByteCount;
+    validator.payloadByteCount = function() { return payloadByteCount.call(validator) + 2 };
+    validator.validate();
+});
+
 test('Validator identifies missing payload file', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_missing_data_file.tar");
     let expected = ["File 'data/datastream-DC' in manifest-md5.txt is missing from bag.",
@@ -314,6 +397,7 @@ test('Validator identifies missing tag file', done => {
 test('Validator identifies missing data dir', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_no_data_dir.tar");
     let expected = [
+        "Bag has no payload directory. The BagIt spec requires a directory called data, even if the bag has no payload.",
         "File 'data/datastream-DC' in manifest-md5.txt is missing from bag.",
         "File 'data/datastream-descMetadata' in manifest-md5.txt is missing from bag.",
         "File 'data/datastream-MARC' in manifest-md5.txt is missing from bag.",
@@ -333,6 +417,18 @@ test('Validator identifies missing data dir', done => {
     validator.validate();
 });
 
+test('Validator identifies payload outside the data directory', done => {
+    let validator = getBagItValidator("payload_outside_data");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag has no payload directory. The BagIt spec requires a directory called data, even if the bag has no payload."
+        ]);
+        expect(validator.results[0].check).toEqual('payloadDirectory');
+        done();
+    });
+    validator.validate();
+});
+
 test('Validator identifies missing manifest', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_no_md5_manifest.tar");
     // Add a required profile to be sure validator checks for it.
@@ -353,6 +449,26 @@ test('Validator identifies missing manifest', done => {
     validator.validate();
 });
 
+test('Validator.bagNameFromPath()', () => {
+    expect(Validator.bagNameFromPath('/bags/photos.tar')).toEqual('photos');
+    expect(Validator.bagNameFromPath('/bags/photos.tar.gz')).toEqual('photos');
+    expect(Validator.bagNameFromPath('/bags/photos.tgz')).toEqual('photos');
+    expect(Validator.bagNameFromPath('/bags/photos.zip')).toEqual('photos');
+    expect(Validator.bagNameFromPath('/bags/example.edu.photos.tar')).toEqual('example.edu.photos');
+    expect(Validator.bagNameFromPath('/bags/photos')).toEqual('photos');
+    expect(Validator.bagNameFromPath('/bags/photos.txt')).toEqual('photos.txt');
+});
+
+test('Validator strips only the exact bag name from paths in archives', () => {
+    let profile = new BagItProfile();
+    let validator = new Validator('/bags/example.edu.photos.tar.gz', profile);
+    expect(validator._cleanEntryRelPath('example.edu.photos/data/file.txt')).toEqual('data/file.txt');
+    expect(validator._cleanEntryRelPath('example.edu.photos/')).toEqual('');
+    expect(validator._cleanEntryRelPath('example_edu_photos/data/file.txt')).toEqual('example_edu_photos/data/file.txt');
+    validator = new Validator('/bags/photos (2).zip', profile);
+    expect(validator._cleanEntryRelPath('photos (2)/bagit.txt')).toEqual('bagit.txt');
+});
+
 test('Validator identifies wrong folder name', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_wrong_folder_name.tar");
     let expected = ["Bag should untar to directory 'example.edu.sample_wrong_folder_name', not 'wrong_folder_name'"];
@@ -370,6 +486,17 @@ test('Validator identifies wrong folder name', done => {
     validator.validate();
 });
 
+test('Validator identifies tar file with more than one top-level directory', done => {
+    let validator = getBagItValidator("multiple_top_level_dirs.tar");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag should untar to a single directory, but it has 2 top-level entries: extra, multiple_top_level_dirs"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
 test('Validator rejects unserialized bag if profile says it must be serialized', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good");
     let expected = [Context.y18n.__("Profile says bag must be serialized, but it is a directory.")];
@@ -405,6 +532,88 @@ test('Validator ignores serialization rules when disableSerializationCheck is tr
     validator.validate();
 });
 
+test('Validator accepts bag in the profile\'s required serialization format', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good.tar");
+    validator.profile.acceptSerialization = ['application/tar', 'application/zip'];
+    validator.profile.requiredSerialization = 'application/tar';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects bag in an accepted format other than the required one', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good.tar");
+    validator.profile.acceptSerialization = ['application/tar', 'application/zip'];
+    validator.profile.requiredSerialization = 'application/zip';
+    validator.on('error', function(err) {
+        expect(err).not.toBeNull();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag has extension .tar, which the profile accepts, but the profile requires bags to be serialized as application/zip."
+        ]);
+        expect(validator.results[0].check).toEqual('serialization');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts directory when serialization is optional and no formats are accepted', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.serialization = 'optional';
+    validator.profile.acceptSerialization = [];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects serialized bag when serialization is optional and no formats are accepted', done => {
+    let validator = getBagItValidator("payload_first.tar");
+    validator.profile.serialization = 'optional';
+    validator.profile.acceptSerialization = [];
+    validator.on('error', function(err) {
+        expect(err).not.toBeNull();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag has extension .tar, but profile does not accept any serialization formats."
+        ]);
+        expect(validator.results[0].check).toEqual('serialization');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts serialized bag in an accepted format when serialization is optional', done => {
+    let validator = getBagItValidator("payload_first.tar");
+    validator.profile.serialization = 'optional';
+    validator.profile.acceptSerialization = ['application/tar'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects unknown serialization values', () => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.serialization = 'sometimes';
+    expect(validator._validateSerialization()).toBe(false);
+    expect(validator.errors).toEqual([
+        "Profile has unknown serialization value 'sometimes'. It must be required, optional, or forbidden."
+    ]);
+    expect(validator.results[0].check).toEqual('serialization');
+});
+
 test('Validator finds bad Payload-Oxum', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_bad_oxum.tar");
     let expected = [
@@ -442,6 +651,21 @@ test('Validator identifies illegal manifests', done => {
     validator.validate();
 });
 
+test('Validator allows only required manifests when the profile says so', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    validator.profile.manifestsRequired = ["sha256"];
+    validator.profile.tagManifestsRequired = ["sha256"];
+    validator.profile.allowOnlyRequiredManifests = true;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag includes manifest md5, but the profile allows only required manifests: sha256",
+            "Bag includes tagmanifest md5, but the profile allows only required tagmanifests: sha256"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
 test('Validator identifies illegal tag manifests', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
     validator.profile.tagManifestsAllowed = [];
@@ -459,6 +683,24 @@ test('Validator identifies illegal tag manifests', done => {
     validator.validate();
 });
 
+test('Validator identifies missing files from tagFilesRequired', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    validator.profile.tagFilesRequired = [
+        "custom_tag_file.txt",
+        "custom_tags/missing.txt"
+    ];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual(["Required tag file custom_tags/missing.txt is missing"]);
+        done();
+    });
+    validator.validate();
+});
+
 test('Validator identifies illegal tag files', done => {
     let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
     // bagit.txt is always allowed.
@@ -482,3 +724,3254 @@ test('Validator identifies illegal tag files', done => {
     });
     validator.validate();
 });
+
+function getBagItValidator(bagName) {
+    let bagPath = path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+    return new Validator(bagPath, new BagItProfile());
+}
+
+test('Validator defaults to the latest BagIt spec version', () => {
+    let validator = new Validator("/path/to/bag.tar", new BagItProfile());
+    expect(validator.specVersion).toEqual("1.0");
+});
+
+test('Validator emits error for unknown BagIt spec version', done => {
+    let validator = getBagItValidator("bom_in_bagit_txt");
+    validator.specVersion = "0.96";
+    validator.on('error', function(err) {
+        expect(err).toEqual("Validator does not know the rules for BagIt version '0.96'. Valid versions: 0.97, 1.0");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator allows byte order mark in bagit.txt under BagIt 0.97', done => {
+    let validator = getBagItValidator("bom_in_bagit_txt");
+    validator.specVersion = "0.97";
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects byte order mark in bagit.txt under BagIt 1.0', done => {
+    let validator = getBagItValidator("bom_in_bagit_txt");
+    validator.specVersion = "1.0";
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "bagit.txt must not begin with a byte order mark under BagIt version 1.0"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts incomplete manifest under BagIt 0.97', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    validator.specVersion = "0.97";
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects incomplete manifest under BagIt 1.0', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    validator.specVersion = "1.0";
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload manifests list different numbers of files: manifest-md5.txt has 1, manifest-sha256.txt has 2.",
+            "Payload file data/docs/second.txt not found in manifest-md5.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects payload manifests that list different files', done => {
+    let validator = getBagItValidator("manifests_disagree");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File 'data/old.txt' in manifest-md5.txt is missing from bag.",
+            "Payload file data/docs/second.txt not found in manifest-md5.txt",
+            "manifest-md5.txt lists data/old.txt, but manifest-sha256.txt does not."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['manifestEntries', 'noExtraneousPayloadFiles', 'manifestsAgree']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator does not compare payload manifests under BagIt 0.97', done => {
+    let validator = getBagItValidator("manifests_disagree");
+    validator.specVersion = "0.97";
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File 'data/old.txt' in manifest-md5.txt is missing from bag."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports payload manifests with different file counts', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    validator.on('end', function() {
+        let results = validator.results.filter(r => r.check == 'manifestCounts');
+        expect(results.map(r => r.message)).toEqual([
+            "Payload manifests list different numbers of files: manifest-md5.txt has 1, manifest-sha256.txt has 2."
+        ]);
+        // This comes before the file-by-file comparisons.
+        expect(validator.results.indexOf(results[0])).toEqual(0);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator does not compare payload manifest file counts under BagIt 0.97', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    validator.specVersion = "0.97";
+    validator.on('end', function() {
+        // BagIt 0.97 lets each file appear in just one manifest, so
+        // manifests with different file counts are legal.
+        expect(validator.results.filter(r => r.check == 'manifestCounts')).toEqual([]);
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts tag file covered by the required tag manifest algorithm', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    validator.profile.tagFileAlgorithms = { 'aptrust-info.txt': 'sha256' };
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects tag file covered only by a different tag manifest algorithm', done => {
+    let validator = getBagItValidator("md5_tag_manifest");
+    validator.profile.tagFileAlgorithms = { 'bag-info.txt': 'sha256' };
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt must be listed in a sha256 tag manifest, but it is only in tagmanifest-md5.txt."
+        ]);
+        expect(validator.structuredErrors().map(r => r.check)).toEqual(['tagFileAlgorithms']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator warns when tag manifests and payload manifests use different algorithms', done => {
+    let validator = getBagItValidator("md5_tag_manifest");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([
+            "Bag has manifest-sha256.txt but no tagmanifest-sha256.txt. BagIt 1.0 says tag manifests should use the same algorithms as payload manifests.",
+            "Bag has tagmanifest-md5.txt but no manifest-md5.txt. BagIt 1.0 says tag manifests should use the same algorithms as payload manifests."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['tagManifestAlgorithms', 'tagManifestAlgorithms']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator allows tag manifests with other algorithms under BagIt 0.97', done => {
+    let validator = getBagItValidator("md5_tag_manifest");
+    validator.specVersion = '0.97';
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects required tag file algorithm when no tag manifest lists the file', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.tagFileAlgorithms = { 'bag-info.txt': 'sha256', 'missing-info.txt': 'sha256' };
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt must be listed in a sha256 tag manifest, but no tag manifest lists it."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+class MockResolver extends ValueResolver {
+    constructor(values) {
+        super();
+        this.values = values;
+        this.calls = [];
+    }
+    allowedValues(tagName) {
+        this.calls.push(tagName);
+        if (this.values[tagName] === undefined) {
+            return Promise.reject(new Error(`No vocabulary for ${tagName}`));
+        }
+        return Promise.resolve(this.values[tagName]);
+    }
+}
+
+function getVocabularyValidator(resolver) {
+    let validator = getBagItValidator("valid_bag");
+    let tagDef = validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0];
+    tagDef.vocabularyBacked = true;
+    tagDef.values = ["Static University"];
+    validator.valueResolver = resolver;
+    return validator;
+}
+
+test('Validator uses allowed values from ValueResolver', done => {
+    let resolver = new MockResolver({
+        "Source-Organization": ["Example University", "Sample College"]
+    });
+    let validator = getVocabularyValidator(resolver);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(resolver.calls).toEqual(["Source-Organization"]);
+        expect(validator.resolvedValues["Source-Organization"]).toEqual(["Example University", "Sample College"]);
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects values not supplied by ValueResolver', done => {
+    let resolver = new MockResolver({
+        "Source-Organization": ["Sample College"]
+    });
+    let validator = getVocabularyValidator(resolver);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Source-Organization' in bag-info.txt contains illegal value 'Example University'. [Allowed: Sample College]"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator falls back to static values when ValueResolver fails', done => {
+    let resolver = new MockResolver({});
+    let validator = getVocabularyValidator(resolver);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(resolver.calls).toEqual(["Source-Organization"]);
+        expect(validator.errors).toEqual([
+            "Tag 'Source-Organization' in bag-info.txt contains illegal value 'Example University'. [Allowed: Static University]"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator emits end when it cannot start reading the bag', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator._scanBag = function() {
+        throw new Error('Simulated scan failure');
+    };
+    let errorEmitted = null;
+    validator.on('error', function(err) {
+        errorEmitted = err;
+    });
+    validator.on('end', function() {
+        expect(errorEmitted.message).toEqual('Simulated scan failure');
+        expect(validator.errors.length).toEqual(1);
+        expect(validator.errors[0]).toMatch(/Simulated scan failure/);
+        expect(() => validator.reset()).not.toThrow();
+        done();
+    });
+    validator.validate();
+});
+
+test('resultCsv() returns one row per error', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_bad_oxum.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let lines = validator.resultCsv().split("\r\n");
+        expect(lines).toEqual([
+            'severity,check,filePath,message',
+            'error,payloadOxum,bag-info.txt,"Payload-Oxum says there should be 24 files in the payload, but validator found 4."',
+            'error,payloadOxum,bag-info.txt,"Payload-Oxum says there should be 99999 bytes in the payload, but validator found 13821."',
+            ''
+        ]);
+        expect(validator.results.length).toEqual(2);
+        expect(validator.results[0].check).toEqual('payloadOxum');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags UTF-8 tag files in bag that declares ISO-8859-1', done => {
+    let validator = getBagItValidator("latin1_declared_utf8_tags");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt contains UTF-8 multibyte characters, but bagit.txt declares ISO-8859-1"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags Latin-1 tag files in bag that declares UTF-8', done => {
+    let validator = getBagItValidator("utf8_declared_latin1_tags");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt cannot be decoded as UTF-8"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects algorithms that policy does not approve', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.approvedAlgorithms = ['sha256', 'sha512'];
+    validator.policyRequiredAlgorithms = ['sha256', 'sha512'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "manifest-md5.txt uses md5, which is not an approved algorithm. [Approved: sha256, sha512]"
+        ]);
+        expect(validator.warnings).toEqual([
+            "Policy calls for a sha512 payload manifest, but the bag has none."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['algorithmPolicy', 'algorithmPolicy']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator applies no algorithm policy by default', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags paths listed twice in one manifest', done => {
+    let validator = getBagItValidator("duplicate_manifest_entry");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "manifest-sha256.txt lists data/first.txt 2 times, with conflicting digests: 65a4afb6b2e4d34598a1ee6965b029f8bb785751cb474b2033d3fc6e3401904c, 0000000000000000000000000000000000000000000000000000000000000000."
+        ]);
+        expect(validator.results[0].check).toEqual('manifestDuplicates');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects manifests with unsupported algorithms', done => {
+    let validator = getBagItValidator("unsupported_manifest_algorithm");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.manifestAlgorithmsFoundInBag).toEqual(["sha256"]);
+        expect(validator.errors).toEqual([
+            "Unsupported checksum algorithm 'rot13' in manifest-rot13.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator decodes percent-encoded paths in manifests', done => {
+    let validator = getBagItValidator("percent_encoded_paths.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/100%.txt",
+            "data/carriage\rreturn.txt",
+            "data/line\nbreak.txt"
+        ]);
+        expect(validator.manifestContent('sha256')).toEqual(
+            "49f08c7a24592e312dfecd48c30bae0d2f8f288b11d5f001ddf81385e0d3ad28  data/100%25.txt\n" +
+            "fba906641e7243decc39d457472e2433a8d9297c095e4f32e568b4a2fe3e5743  data/carriage%0Dreturn.txt\n" +
+            "e350954af0f8b914eb5a661bd84ae131cc483cd6dc3af2ed3df53b1470d1a559  data/line%0Abreak.txt\n");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts tag files with consistent CRLF line endings', done => {
+    let validator = getBagItValidator("crlf_tag_files");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags tag files with mixed line endings', done => {
+    let validator = getBagItValidator("mixed_line_endings");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt mixes line endings: 1 CRLF, 2 LF."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('_lineEndingCounts()', () => {
+    let validator = new Validator("/path/to/bag", new BagItProfile());
+    expect(validator._lineEndingCounts("a\r\nb\nc\rd\r\n")).toEqual({ CRLF: 2, LF: 1, CR: 1 });
+    expect(validator._lineEndingCounts("no terminator")).toEqual({ CRLF: 0, LF: 0, CR: 0 });
+});
+
+test('streamVerifyTar() finds the same errors as validate()', done => {
+    let standard = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    standard.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    standard.on('end', function() {
+        let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+        validator.on('error', function(err) {
+            // Force failure & stop test.
+            expect(err).toBeNull();
+            done();
+        });
+        validator.on('end', function() {
+            expect(validator.errors.length).toEqual(standard.errors.length);
+            expect(validator.errors.slice().sort()).toEqual(standard.errors.slice().sort());
+            done();
+        });
+        validator.streamVerifyTar();
+    });
+    standard.validate();
+});
+
+test('streamVerifyTar() checks Payload-Oxum without keeping payload files', done => {
+    let standard = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_bad_oxum.tar");
+    standard.on('end', function() {
+        let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_bad_oxum.tar");
+        validator.on('end', function() {
+            expect(validator.errors.length).toBeGreaterThan(0);
+            expect(validator.errors.slice().sort()).toEqual(standard.errors.slice().sort());
+            done();
+        });
+        validator.streamVerifyTar();
+    });
+    standard.validate();
+});
+
+test('streamVerifyTar() does not keep payload files', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    let standard = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.payloadFiles()).toEqual([]);
+        expect(validator.report().payloadFileCount).toEqual(4);
+        expect(validator.payloadByteCount()).toEqual(standard.payloadByteCount());
+        for (let f of validator.tagFiles()) {
+            expect(f.checksums['sha256']).toMatch(/^[0-9a-f]{64}$/);
+        }
+        done();
+    });
+    standard.on('end', function() {
+        validator.streamVerifyTar();
+    });
+    standard.validate();
+});
+
+test('streamVerifyTar() rejects bags that are not tarred', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.on('error', function(err) {
+        expect(err).toMatch("streamVerifyTar() can only validate tarred bags");
+        done();
+    });
+    validator.streamVerifyTar();
+});
+
+test('Validator accepts tags in required order', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.requiredTagOrder = {
+        "bag-info.txt": ["Source-Organization", "Payload-Oxum", "Tag-Not-In-Bag"]
+    };
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports first tag out of required order', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.requiredTagOrder = {
+        "bag-info.txt": ["Payload-Oxum", "Bagging-Date", "Source-Organization"]
+    };
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Bagging-Date' in bag-info.txt must appear after 'Payload-Oxum'"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags payload manifest entries that point to tag files', done => {
+    let validator = getBagItValidator("payload_manifest_lists_tag_files");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload manifest manifest-sha256.txt lists bagit.txt, which is a tag file, not a payload file.",
+            "Payload manifest manifest-sha256.txt lists bag-info.txt, which is a tag file, not a payload file."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('generateReportTagFile() summarizes validation in tag file format', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_bad_oxum.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let report = validator.generateReportTagFile();
+        // TagFileParser only needs the keyValueCollection property.
+        let bagItFile = { relDestPath: 'validation-report.txt', keyValueCollection: null };
+        let parser = new TagFileParser(bagItFile);
+        parser.stream.on('end', function() {
+            let tags = bagItFile.keyValueCollection;
+            expect(tags.keys()).toEqual([
+                'Validation-Date',
+                'Validation-Software',
+                'BagIt-Profile-Identifier',
+                'BagIt-Version',
+                'Validation-Result',
+                'Algorithms-Verified',
+                'Error-Count',
+                'Warning-Count'
+            ]);
+            expect(tags.first('Validation-Date')).toMatch(/^\d{4}-\d{2}-\d{2}T/);
+            expect(tags.first('BagIt-Profile-Identifier')).toEqual(validator.profile.bagItProfileInfo.bagItProfileIdentifier);
+            expect(tags.first('BagIt-Version')).toEqual('1.0');
+            expect(tags.first('Validation-Result')).toEqual('invalid');
+            expect(tags.first('Algorithms-Verified')).toEqual('md5, sha256');
+            expect(tags.first('Error-Count')).toEqual('2');
+            expect(tags.first('Warning-Count')).toEqual('0');
+            done();
+        });
+        parser.stream.end(report);
+        parser.stream.resume();
+    });
+    validator.validate();
+});
+
+test('generateReportTagFile() lists only supported algorithms', done => {
+    let validator = getBagItValidator("unsupported_manifest_algorithm");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.generateReportTagFile()).toMatch(/\nAlgorithms-Verified: sha256\n/);
+        done();
+    });
+    validator.validate();
+});
+
+test('generateReportTagFile() lists no algorithms when checksums are skipped', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.skipChecks = ['checksums'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let report = validator.generateReportTagFile();
+        expect(report).toMatch(/\nAlgorithms-Verified: *\n/);
+        expect(report).toMatch(/\nChecks-Skipped: checksums\n$/);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts payload within maxPayloadSize', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.maxPayloadSize = 41;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.payloadByteCount()).toEqual(41);
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects payload larger than maxPayloadSize', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.maxPayloadSize = 40;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload contains 41 bytes, which exceeds the profile's limit of 40 bytes."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects payload smaller than minPayloadSize', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.minPayloadSize = 42;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload contains 41 bytes, which is less than the profile's minimum of 42 bytes."
+        ]);
+        expect(validator.results[0].expected).toEqual('42');
+        expect(validator.results[0].actual).toEqual('41');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator checks maxBagSize against all files in the bag', done => {
+    let validator = getBagItValidator("payload_first.tar");
+    validator.profile.maxBagSize = 41;
+    validator.profile.minPayloadSize = 41;
+    validator.on('end', function() {
+        let bagSize = Object.values(validator.files).reduce((total, f) => total + Number(f.size), 0);
+        expect(bagSize).toBeGreaterThan(41);
+        expect(validator.errors).toEqual([
+            `Bag contains ${bagSize} bytes, which exceeds the profile's limit of 41 bytes.`
+        ]);
+        expect(validator.results[0].check).toEqual('payloadSize');
+        expect(validator.results[0].actual).toEqual(String(bagSize));
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts tag files within maxTagFileSize and maxTotalMetadataSize', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.maxTagFileSize = 84;
+    validator.profile.maxTotalMetadataSize = 138;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects tag files larger than maxTagFileSize and maxTotalMetadataSize', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.maxTagFileSize = 50;
+    validator.profile.maxTotalMetadataSize = 100;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag file bag-info.txt contains 84 bytes, which exceeds the profile's limit of 50 bytes per tag file.",
+            "Tag file bagit.txt contains 54 bytes, which exceeds the profile's limit of 50 bytes per tag file.",
+            "Tag files contain 138 bytes, which exceeds the profile's limit of 100 bytes for all metadata."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores case collisions by default', done => {
+    let validator = getBagItValidator("case_collisions.tar");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags case collisions anywhere in the bag', done => {
+    let validator = getBagItValidator("case_collisions.tar");
+    validator.checkCaseCollisions = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File names differ only by case: data/one/notes.txt, data/two/Notes.txt",
+            "File names differ only by case: data/README.txt, data/Readme.txt"
+        ]);
+        expect(validator.results[0].check).toEqual('caseCollision');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags case collisions only within a directory when caseCollisionScope is directory', done => {
+    let validator = getBagItValidator("case_collisions.tar");
+    validator.checkCaseCollisions = true;
+    validator.caseCollisionScope = 'directory';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File names differ only by case: data/README.txt, data/Readme.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts sorted manifests when profile requires them', done => {
+    let validator = getBagItValidator("sorted_manifest");
+    validator.profile.requireSortedManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects unsorted manifests when profile requires sorted manifests', done => {
+    let validator = getBagItValidator("unsorted_manifest");
+    validator.profile.requireSortedManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Entries in manifest-sha256.txt are not sorted by path: line 4 lists data/b.txt after data/b/c.txt."
+        ]);
+        expect(validator.results[0].check).toEqual('manifestOrder');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores manifest order by default', done => {
+    let validator = getBagItValidator("unsorted_manifest");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+describe('Validator with warnOnEmptyDirectories', () => {
+    // Git doesn't track empty directories, so we copy the bag to a
+    // temp directory and create the empty one there, leaving the
+    // checked-in fixture alone.
+    let fixture = path.join(__dirname, "..", "test", "bags", "bagit", "empty_payload_dir");
+    let bagDir = null;
+
+    beforeEach(() => {
+        bagDir = Util.tmpFilePath();
+        fs.mkdirSync(path.join(bagDir, "data", "docs", "empty"), { recursive: true });
+        for (let relPath of ["bagit.txt", "bag-info.txt", "manifest-sha256.txt", "data/first.txt", "data/docs/second.txt"]) {
+            fs.copyFileSync(path.join(fixture, relPath), path.join(bagDir, relPath));
+        }
+    });
+
+    afterEach(() => {
+        Util.deleteRecursive(bagDir);
+    });
+
+    test('warns about empty payload directories', done => {
+        let validator = new Validator(bagDir, new BagItProfile());
+        validator.warnOnEmptyDirectories = true;
+        validator.on('error', function(err) {
+            // Force failure & stop test.
+            expect(err).toBeNull();
+            done();
+        });
+        validator.on('end', function() {
+            expect(validator.errors).toEqual([]);
+            expect(validator.warnings).toEqual([
+                "Payload directory data/docs/empty is empty."
+            ]);
+            expect(validator.results[0].severity).toEqual('warning');
+            done();
+        });
+        validator.validate();
+    });
+
+    test('ignores empty payload directories by default', done => {
+        let validator = new Validator(bagDir, new BagItProfile());
+        validator.on('error', function(err) {
+            // Force failure & stop test.
+            expect(err).toBeNull();
+            done();
+        });
+        validator.on('end', function() {
+            expect(validator.errors).toEqual([]);
+            expect(validator.warnings).toEqual([]);
+            done();
+        });
+        validator.validate();
+    });
+});
+
+// Reads manifests with tab-separated digest, path, size and mtime
+// columns, ignoring everything after the path.
+class ExtendedTsvManifestParser extends ManifestParser {
+    parseLine(line) {
+        let columns = line.split("\t");
+        if (columns.length < 2 || columns[0] == '' || columns[1] == '') {
+            return null;
+        }
+        return { filename: columns[1], digest: columns[0] };
+    }
+}
+
+test('Validator uses registered manifest parser for profile manifestFormat', done => {
+    ManifestParser.register('extended-tsv', ExtendedTsvManifestParser);
+    let validator = getBagItValidator("extended_tsv_manifest");
+    validator.profile.manifestFormat = 'extended-tsv';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator cannot read extended manifest with default parser', done => {
+    let validator = getBagItValidator("extended_tsv_manifest");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toContain("Payload file data/first.txt not found in manifest-sha256.txt");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator emits error for unregistered manifest format', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.manifestFormat = 'no-such-format';
+    validator.on('error', function(err) {
+        expect(err).toMatch("No manifest parser is registered for format 'no-such-format'");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator warns about manifests with mixed-case digests', done => {
+    let validator = getBagItValidator("mixed_case_digests");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        // Digests are compared without regard to case.
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([
+            "Manifest manifest-sha256.txt mixes uppercase and lowercase hex digests."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('conformanceLevel() returns highest level the bag achieves', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.conformanceLevels = [
+        {
+            name: 'minimal',
+            manifestsRequired: ['sha256']
+        },
+        {
+            name: 'recommended',
+            tags: [{ tagFile: 'bag-info.txt', tagName: 'Bagging-Date' }]
+        },
+        {
+            name: 'complete',
+            tagManifestsRequired: ['sha256'],
+            tags: [{ tagFile: 'bag-info.txt', tagName: 'External-Description' }]
+        }
+    ];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.conformanceLevel()).toEqual('recommended');
+        done();
+    });
+    validator.validate();
+});
+
+test('conformanceLevel() returns empty string for invalid bag', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    validator.profile.conformanceLevels = [{ name: 'minimal' }];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors.length).toEqual(2);
+        expect(validator.conformanceLevel()).toEqual('');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags control characters in tag values', done => {
+    let validator = getBagItValidator("nul_in_tag_value");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Source-Organization' in bag-info.txt contains control characters: 'Example\\x00University'"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+// Stub bags have no payload, so the validator will also report that
+// the files in the manifest are missing. These tests are concerned
+// only with the Payload-Oxum.
+function oxumResults(validator) {
+    return validator.results.filter(r => r.check == 'payloadOxum').map(r => `${r.severity}: ${r.message}`);
+}
+
+test('Validator rejects malformed Payload-Oxum', done => {
+    let validator = getBagItValidator("malformed_payload_oxum");
+    validator.on('end', function() {
+        expect(oxumResults(validator)).toEqual([
+            "error: Payload-Oxum '41 bytes' is not in the form OctetCount.StreamCount."
+        ]);
+        expect(validator.errors.length).toEqual(1);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator checks Payload-Oxum of stub bag against fetch.txt', done => {
+    let validator = getBagItValidator("fetch_stub_bag");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(oxumResults(validator)).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports Payload-Oxum mismatch with fetch.txt lengths', done => {
+    let validator = getBagItValidator("fetch_stub_bag_bad_lengths");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(oxumResults(validator)).toEqual([
+            "error: Payload-Oxum says there should be 41 bytes in the payload, but fetch.txt lengths add up to 120."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator warns when fetch.txt lengths are unknown', done => {
+    let validator = getBagItValidator("fetch_stub_bag_unknown_length");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(oxumResults(validator)).toEqual([
+            "warning: Cannot check Payload-Oxum against fetch.txt, because 1 fetch.txt entries have unknown length '-'."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator checks fetch.txt entries against the payload manifests', done => {
+    let validator = getBagItValidator("fetch_txt_invalid");
+    validator.profile.allowFetchTxt = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Line 3 of fetch.txt is not in the form 'URL LENGTH FILENAME': data/no-url.txt",
+            "File data/extra.txt in fetch.txt is not listed in manifest-sha256.txt.",
+            "Line 4 of fetch.txt lists bagit.txt, which is outside the payload directory."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator counts fetched files in Payload-Oxum', done => {
+    let validator = getBagItValidator("fetch_txt_invalid");
+    validator.profile.allowFetchTxt = true;
+    validator.skipChecks = ['fetch'];
+    validator.on('end', function() {
+        expect(oxumResults(validator)).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects fetch.txt when profile does not allow it', done => {
+    let validator = getBagItValidator("fetch_stub_bag");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag contains fetch.txt, but the profile does not allow it."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('_parseFetchTxt()', () => {
+    let validator = new Validator("/path/to/bag", new BagItProfile());
+    validator._tagFileBytes['fetch.txt'] = [Buffer.from("http://example.com/a 10 data/a b.txt\r\n\nbad line\nftp://example.com/c - data/c.txt\n")];
+    let malformed = [];
+    expect(validator._parseFetchTxt(malformed)).toEqual([
+        { url: 'http://example.com/a', length: '10', filename: 'data/a b.txt', lineNumber: 1 },
+        { url: 'ftp://example.com/c', length: '-', filename: 'data/c.txt', lineNumber: 4 }
+    ]);
+    expect(malformed).toEqual([{ lineNumber: 3, line: 'bad line' }]);
+});
+
+test('_windowsPortabilityProblems()', () => {
+    let validator = new Validator("/path/to/bag.tar", new BagItProfile());
+    expect(validator._windowsPortabilityProblems("data/docs/file.txt")).toEqual([]);
+    expect(validator._windowsPortabilityProblems("data/console.txt")).toEqual([]);
+    expect(validator._windowsPortabilityProblems("data/nul")).toEqual(["'nul' is a reserved name"]);
+    expect(validator._windowsPortabilityProblems("data/LPT1.log")).toEqual(["'LPT1.log' is a reserved name"]);
+    expect(validator._windowsPortabilityProblems("data/a:b.txt")).toEqual(["'a:b.txt' contains characters that are not allowed"]);
+    expect(validator._windowsPortabilityProblems("data/star*.txt")).toEqual(["'star*.txt' contains characters that are not allowed"]);
+    expect(validator._windowsPortabilityProblems("data/dir /file.txt")).toEqual(["'dir ' ends with a dot or space"]);
+    expect(validator._windowsPortabilityProblems("data/" + "x".repeat(256))).toEqual([
+        "path is longer than 260 characters",
+        "'" + "x".repeat(256) + "' is longer than 255 characters"
+    ]);
+    expect(validator._windowsPortabilityProblems("data/" + "dir/".repeat(64) + "file.txt")).toEqual([
+        "path is longer than 260 characters"
+    ]);
+});
+
+test('Validator flags paths that are not portable to Windows', done => {
+    let validator = getBagItValidator("windows_unfriendly.tar");
+    validator.checkWindowsPortability = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File path data/CON.txt is not portable to Windows: 'CON.txt' is a reserved name",
+            "File path data/docs./second.txt is not portable to Windows: 'docs.' ends with a dot or space",
+            "File path data/what?.txt is not portable to Windows: 'what?.txt' contains characters that are not allowed"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores Windows portability by default', done => {
+    let validator = getBagItValidator("windows_unfriendly.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+class MockFixityVerifier extends FixityVerifier {
+    constructor(digests) {
+        super();
+        this.digests = digests;
+    }
+    expectedChecksum(relPath, algorithm) {
+        let digest = this.digests[`${algorithm}:${relPath}`];
+        return Promise.resolve(digest === undefined ? null : digest);
+    }
+}
+
+test('Validator accepts digests that match the fixity registry', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.fixityVerifier = new MockFixityVerifier({
+        "sha256:data/first.txt": "65a4afb6b2e4d34598a1ee6965b029f8bb785751cb474b2033d3fc6e3401904c",
+        "sha256:data/docs/second.txt": "2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5"
+    });
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports digests that differ from the fixity registry', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.fixityVerifier = new MockFixityVerifier({
+        "sha256:data/first.txt": "0".repeat(64),
+        "sha256:data/docs/second.txt": "2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5"
+    });
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Fixity registry says sha256 digest for 'data/first.txt' should be '0000000000000000000000000000000000000000000000000000000000000000', file digest is '65a4afb6b2e4d34598a1ee6965b029f8bb785751cb474b2033d3fc6e3401904c', manifest says '65a4afb6b2e4d34598a1ee6965b029f8bb785751cb474b2033d3fc6e3401904c'."
+        ]);
+        expect(validator.results[0].check).toEqual('fixityRegistry');
+        expect(validator.results[0].filePath).toEqual('data/first.txt');
+        done();
+    });
+    validator.validate();
+});
+
+// Validates the bag and resolves with the validator's result code.
+function resultCodeAfterValidation(validator) {
+    return new Promise(function(resolve) {
+        validator.on('end', function() {
+            resolve(validator.resultCode());
+        });
+        validator.validate();
+    });
+}
+
+test('resultCode() returns 0 for valid bag', () => {
+    let validator = getBagItValidator("valid_bag");
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(code).toEqual(0);
+    });
+});
+
+test('resultCode() returns 2 when profile is invalid', () => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile = null;
+    validator.on('error', function() {});
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(code).toEqual(2);
+    });
+});
+
+test('resultCode() returns 3 when bag structure is invalid', () => {
+    let validator = getBagItValidator("payload_manifest_lists_tag_files");
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(code).toEqual(3);
+    });
+});
+
+test('resultCode() returns 4 for checksum mismatch', () => {
+    let validator = getBagItValidator("valid_bag");
+    validator.fixityVerifier = new MockFixityVerifier({
+        "sha256:data/first.txt": "0".repeat(64)
+    });
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(code).toEqual(4);
+    });
+});
+
+test('resultCode() returns 4 when bag has both checksum and structure errors', () => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(validator.results.map(r => r.check)).toContain('tags');
+        expect(code).toEqual(4);
+    });
+});
+
+test('resultCode() returns 5 when bag cannot be read', () => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "BagDoesNotExist.tar");
+    validator.on('error', function() {});
+    return resultCodeAfterValidation(validator).then(function(code) {
+        expect(code).toEqual(5);
+    });
+});
+
+test('resultCode() ignores warnings', done => {
+    let validator = getBagItValidator("mixed_case_digests");
+    validator.on('end', function() {
+        expect(validator.warnings.length).toBeGreaterThan(0);
+        expect(validator.resultCode()).toEqual(0);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator matches manifest paths exactly by default', done => {
+    let validator = getBagItValidator("manifest_path_case");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File 'data/First.TXT' in manifest-sha256.txt is missing from bag.",
+            "Payload file data/first.txt not found in manifest-sha256.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags path case differences when requireExactPathCase is true', done => {
+    let validator = getBagItValidator("manifest_path_case");
+    validator.requireExactPathCase = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Path case in manifest-sha256.txt does not match bag: manifest lists 'data/First.TXT', but file is 'data/first.txt'."
+        ]);
+        expect(validator.results[0].check).toEqual('pathCase');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator matches paths byte for byte by default', done => {
+    // The bag has data/cafe\u0301.txt, with e followed by a combining
+    // accent, and the manifest lists data/caf\u00e9.txt, with a single
+    // composed character. They look the same, but they're not the same
+    // bytes.
+    let validator = getBagItValidator("decomposed_unicode_paths.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File 'data/caf\u00e9.txt' in manifest-sha256.txt is missing from bag.",
+            "Payload file data/cafe\u0301.txt not found in manifest-sha256.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator matches NFC and NFD paths when normalizeUnicode is true', done => {
+    let validator = getBagItValidator("decomposed_unicode_paths.tar");
+    validator.normalizeUnicode = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts versioned bag whose payload matches its change manifests', done => {
+    let validator = getBagItValidator("versioned_bag");
+    validator.checkChangeManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let history = validator._replayChanges();
+        expect(history.versions).toEqual(2);
+        expect(history.files).toEqual({
+            "data/first.txt": "changes-2.txt",
+            "data/docs/second.txt": "changes-2.txt"
+        });
+        expect(history.removed).toEqual({ "data/old.txt": "changes-2.txt" });
+        expect(history.problems).toEqual([]);
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags versioned bag whose payload does not match its change manifests', done => {
+    let validator = getBagItValidator("versioned_bag_inconsistent");
+    validator.checkChangeManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload file data/docs/second.txt was not added or modified by any change manifest.",
+            "changes-2.txt removes data/old.txt, but it is still in the bag."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts deleted.txt whose paths were removed by change manifests', done => {
+    let validator = getBagItValidator("versioned_bag_tombstones");
+    validator.checkChangeManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags inconsistent paths in deleted.txt', done => {
+    let validator = getBagItValidator("versioned_bag_bad_tombstones");
+    validator.checkChangeManifests = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "deleted.txt lists data/first.txt as deleted, but it is still in the bag.",
+            "deleted.txt lists data/never.txt as deleted, but no change manifest removes it from an earlier version.",
+            "Line 4 of deleted.txt should be a payload path, like data/file.txt."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['tombstones', 'tombstones', 'tombstones']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator resolves payload paths through the blob map of a content-addressed bag', done => {
+    let validator = getBagItValidator("content_addressed");
+    validator.contentAddressed = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/copy-of-first.txt",
+            "data/docs/second.txt",
+            "data/first.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags a corrupt blob in a content-addressed bag', done => {
+    let validator = getBagItValidator("content_addressed_corrupt_blob");
+    validator.contentAddressed = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let blobPath = "blobs/2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5";
+        expect(validator.errors[0]).toEqual(`Blob ${blobPath} does not match its content address. Its contents may be corrupt.`);
+        expect(validator.errors[1]).toMatch(/^Bad sha256 digest for 'data\/docs\/second.txt'/);
+        expect(validator.errors.length).toEqual(2);
+        expect(validator.results.map(r => r.check)).toEqual(['blobMap', 'checksums']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores the blob map unless contentAddressed is true', done => {
+    let validator = getBagItValidator("content_addressed");
+    validator.on('end', function() {
+        expect(validator.errors).toContain("File 'data/first.txt' in manifest-sha256.txt is missing from bag.");
+        expect(validator.payloadFiles()).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores change manifests by default', done => {
+    let validator = getBagItValidator("versioned_bag_inconsistent");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator requires change manifests when checkChangeManifests is true', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.checkChangeManifests = true;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag has no change manifests. Versioned bags should have changes-1.txt, changes-2.txt, etc."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('_replayChanges() reports changes that cannot be applied', () => {
+    let validator = getBagItValidator("valid_bag");
+    validator._tagFileBytes = {
+        "changes-1.txt": [Buffer.from("added data/a.txt\nremoved data/b.txt\n")],
+        "changes-3.txt": [Buffer.from("added data/a.txt\nrenamed data/a.txt\n")]
+    };
+    let history = validator._replayChanges();
+    expect(history.versions).toEqual(3);
+    expect(history.files).toEqual({ "data/a.txt": "changes-3.txt" });
+    expect(history.problems).toEqual([
+        ["data/b.txt", "changes-1.txt says data/b.txt was removed, but it does not exist in the previous version."],
+        ["changes-3.txt", "Change manifests skip from version 1 to changes-3.txt."],
+        ["data/a.txt", "changes-3.txt adds data/a.txt, which already exists in the previous version."],
+        ["changes-3.txt", "Line 2 of changes-3.txt should be 'added', 'modified', or 'removed', followed by a file path."]
+    ]);
+});
+
+test('Validator warns about weak manifest algorithms', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.weakAlgorithmSeverity = 'warning';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([
+            "manifest-md5.txt uses md5, which is weaker than the recommended minimum of sha256."
+        ]);
+        expect(validator.results[0].check).toEqual('weakAlgorithm');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects weak manifest algorithms when severity is error', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.weakAlgorithmSeverity = 'error';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "manifest-md5.txt uses md5, which is weaker than the recommended minimum of sha256."
+        ]);
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator allows weak manifest algorithms by default', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator matches allowed tag values without regard to case when caseInsensitive is true', done => {
+    let validator = getBagItValidator("valid_bag");
+    let tagDef = validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0];
+    tagDef.values = ['EXAMPLE UNIVERSITY'];
+    tagDef.caseInsensitive = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator matches allowed tag values exactly by default', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0].values = ['EXAMPLE UNIVERSITY'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Source-Organization' in bag-info.txt contains illegal value 'Example University'. [Allowed: EXAMPLE UNIVERSITY]"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags tag values that do not match the tag pattern', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.getTagsFromFile("bag-info.txt", "Bagging-Date")[0].pattern = '\\d{4}/\\d{2}/\\d{2}';
+    validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0].pattern = 'Example .+';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Value '2021-07-13' for tag 'Bagging-Date' in bag-info.txt does not match required pattern '\\d{4}/\\d{2}/\\d{2}'."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects profile with invalid tag pattern before reading the bag', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.getTagsFromFile("bag-info.txt", "Bagging-Date")[0].pattern = '[0-9';
+    validator.on('error', function() {});
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "BagItProfile: Tag Bagging-Date in bag-info.txt has a pattern that is not a valid regular expression: [0-9"
+        ]);
+        expect(Object.keys(validator.files)).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags tag values that do not match the tag format', done => {
+    let validator = getBagItValidator("contact_emails");
+    validator.profile.getTagsFromFile("bag-info.txt", "Contact-Email")[0].format = 'email';
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Contact-Email' in bag-info.txt has value 'curator@example', which is not a valid email address."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects repeated tags that are not repeatable', done => {
+    let validator = getBagItValidator("contact_emails");
+    validator.profile.getTagsFromFile("bag-info.txt", "Contact-Email")[0].repeatable = false;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Contact-Email' appears 2 times in bag-info.txt, but the profile allows it only once."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator checks every value of a tag that is not repeatable', done => {
+    let validator = getBagItValidator("contact_emails");
+    let tagDef = validator.profile.getTagsFromFile("bag-info.txt", "Contact-Email")[0];
+    tagDef.repeatable = false;
+    tagDef.format = 'email';
+    tagDef.values = ['archivist@example.edu', 'curator@example'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Contact-Email' appears 2 times in bag-info.txt, but the profile allows it only once.",
+            "Tag 'Contact-Email' in bag-info.txt contains illegal value 'curator@example.edu'. [Allowed: archivist@example.edu, curator@example]",
+            "Tag 'Contact-Email' in bag-info.txt has value 'curator@example', which is not a valid email address."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator skips checks listed in skipChecks', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good");
+    validator.skipChecks = ['serialization'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.results.length).toEqual(1);
+        expect(validator.results[0].severity).toEqual('skipped');
+        expect(validator.results[0].check).toEqual('serialization');
+        expect(validator.results[0].message).toEqual('Skipped serialization check.');
+        expect(validator.resultCsv()).toEqual("severity,check,filePath,message\r\nskipped,serialization,,Skipped serialization check.\r\n");
+        expect(validator.resultCode()).toEqual(0);
+        expect(validator.generateReportTagFile()).toMatch(/\nChecks-Skipped: serialization\n$/);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator suppresses errors from skipped checks', done => {
+    let validator = getBagItValidator("payload_manifest_lists_tag_files");
+    validator.skipChecks = ['manifestEntries', 'payloadOxum'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.results.map(r => r.severity)).toEqual(['skipped', 'skipped']);
+        done();
+    });
+    validator.validate();
+});
+
+test('validateStructure() skips checksums', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    let expected = [
+        "Payload manifests list different numbers of files: manifest-md5.txt has 4, manifest-sha256.txt has 5.",
+        "File 'data/file-not-in-bag' in manifest-sha256.txt is missing from bag.",
+        "File 'custom_tags/tag_file_xyz.pdf' in tagmanifest-md5.txt is missing from bag.",
+        "File 'custom_tags/tag_file_xyz.pdf' in tagmanifest-sha256.txt is missing from bag.",
+        "manifest-sha256.txt lists data/file-not-in-bag, but manifest-md5.txt does not.",
+        "Tag 'Access' in aptrust-info.txt contains illegal value 'acksess'. [Allowed: Consortia, Institution, Restricted]",
+        "Tag 'Storage-Option' in aptrust-info.txt contains illegal value 'Cardboard-Box'. [Allowed: Standard, Glacier-OH, Glacier-OR, Glacier-VA, Glacier-Deep-OH, Glacier-Deep-OR, Glacier-Deep-VA, Wasabi-VA, Wasabi-OR]",
+        "Value for tag 'Title' in aptrust-info.txt is missing."
+    ];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual(expected);
+        expect(validator.skipChecks).toEqual([]);
+        expect(validator.results.filter(r => r.severity == 'skipped').map(r => r.check)).toEqual(['checksums']);
+        expect(validator.payloadFiles().every(f => Object.keys(f.checksums).length == 0)).toBe(true);
+        done();
+    });
+    validator.validateStructure();
+});
+
+test('validate() after validateStructure() and reset() verifies checksums', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    let badDigest = "Bad sha256 digest for 'data/datastream-descMetadata': manifest says 'This-checksum-is-bad-on-purpose.-The-validator-should-catch-it!!', file digest is 'cf9cbce80062932e10ee9cd70ec05ebc24019deddfea4e54b8788decd28b4bc7'.";
+    validator.once('end', function() {
+        expect(validator.errors).not.toContain(badDigest);
+        validator.reset();
+        validator.once('end', function() {
+            expect(validator.errors).toContain(badDigest);
+            expect(validator.results.filter(r => r.severity == 'skipped')).toEqual([]);
+            done();
+        });
+        validator.validate();
+    });
+    validator.validateStructure();
+});
+
+test('validateStructure() does not read payload files in a directory', done => {
+    let validator = getBagItValidator("valid_bag");
+    let pipedFiles = [];
+    let readFile = validator._readFile;
+    validator._readFile = function(bagItFile, readStream) {
+        readStream.on('data', () => pipedFiles.push(bagItFile.relDestPath));
+        readFile.call(validator, bagItFile, readStream);
+    };
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.payloadFiles().length).toEqual(2);
+        expect(pipedFiles.filter(f => f.startsWith('data/'))).toEqual([]);
+        done();
+    });
+    validator.validateStructure();
+});
+
+function validateThen(validator) {
+    return new Promise(function(resolve) {
+        validator.on('end', resolve);
+        validator.validate();
+    });
+}
+
+test('diffResults() reports a new checksum failure', () => {
+    let previous = getBagItValidator("valid_bag");
+    let current = getBagItValidator("valid_bag");
+    let hashCompleted = current._hashCompleted;
+    current._hashCompleted = function(bagItFile, cbData) {
+        if (bagItFile.relDestPath == 'data/first.txt') {
+            bagItFile.checksums[cbData.algorithm] = 'not-the-real-digest';
+        }
+        hashCompleted.call(current, bagItFile, cbData);
+    };
+    return validateThen(previous).then(function() {
+        return validateThen(current);
+    }).then(function() {
+        let diff = Validator.diffResults(previous.protoResult(), current.protoResult());
+        expect(diff.errorsAdded.length).toEqual(1);
+        expect(diff.errorsAdded[0].check).toEqual('checksums');
+        expect(diff.errorsAdded[0].filePath).toEqual('data/first.txt');
+        expect(diff.errorsRemoved).toEqual([]);
+        expect(diff.warningsAdded).toEqual([]);
+        expect(diff.warningsRemoved).toEqual([]);
+        expect(diff.filesAdded).toEqual([]);
+        expect(diff.filesRemoved).toEqual([]);
+        expect(diff.filesResized).toEqual([]);
+        expect(diff.payloadFileCountDelta).toEqual(0);
+        expect(diff.payloadByteCountDelta).toEqual(0);
+
+        let reverse = Validator.diffResults(current.protoResult(), previous.protoResult());
+        expect(reverse.errorsAdded).toEqual([]);
+        expect(reverse.errorsRemoved.map(r => r.filePath)).toEqual(['data/first.txt']);
+    });
+});
+
+test('diffResults() reports file changes', () => {
+    let previous = {
+        payloadFileCount: 2,
+        payloadByteCount: 30,
+        results: [],
+        files: [{ path: 'data/a.txt', size: 10 }, { path: 'data/b.txt', size: 20 }]
+    };
+    let current = {
+        payloadFileCount: 2,
+        payloadByteCount: 45,
+        results: [{ severity: 'warning', check: 'mimeTypes', filePath: 'data/c.txt', message: 'Odd type' }],
+        files: [{ path: 'data/a.txt', size: 15 }, { path: 'data/c.txt', size: 30 }]
+    };
+    let diff = Validator.diffResults(previous, current);
+    expect(diff.filesAdded).toEqual(['data/c.txt']);
+    expect(diff.filesRemoved).toEqual(['data/b.txt']);
+    expect(diff.filesResized).toEqual([{ path: 'data/a.txt', previousSize: 10, currentSize: 15 }]);
+    expect(diff.warningsAdded.map(r => r.message)).toEqual(['Odd type']);
+    expect(diff.payloadFileCountDelta).toEqual(0);
+    expect(diff.payloadByteCountDelta).toEqual(15);
+});
+
+test('generateReceipt() describes a valid tarred bag', () => {
+    let validator = getBagItValidator("payload_first.tar");
+    let tarBytes = fs.readFileSync(validator.pathToBag);
+    let expectedDigest = crypto.createHash('sha256').update(tarBytes).digest('hex');
+    return validateThen(validator).then(function() {
+        expect(validator.errors).toEqual([]);
+        return validator.generateReceipt();
+    }).then(function(json) {
+        let receipt = JSON.parse(json);
+        expect(Object.keys(receipt)).toEqual(['bagName', 'payloadOxum', 'manifestAlgorithms', 'tagManifestAlgorithms', 'archiveSha256', 'generatedAt']);
+        expect(receipt.bagName).toEqual('payload_first');
+        expect(receipt.payloadOxum).toEqual('41.2');
+        expect(receipt.manifestAlgorithms).toEqual(['sha256']);
+        expect(receipt.tagManifestAlgorithms).toEqual([]);
+        expect(receipt.archiveSha256).toEqual(expectedDigest);
+        expect(receipt.generatedAt).toMatch(/^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ$/);
+    });
+});
+
+test('generateReceipt() signs the receipt', () => {
+    let validator = getBagItValidator("valid_bag");
+    validator.receiptSigner = function(unsigned) {
+        return Promise.resolve(crypto.createHash('sha256').update('test-key' + unsigned).digest('hex'));
+    };
+    return validateThen(validator).then(function() {
+        return validator.generateReceipt();
+    }).then(function(json) {
+        let receipt = JSON.parse(json);
+        expect(receipt.archiveSha256).toEqual('');
+        let signature = receipt.signature;
+        delete receipt.signature;
+        let expected = crypto.createHash('sha256').update('test-key' + JSON.stringify(receipt)).digest('hex');
+        expect(signature).toEqual(expected);
+    });
+});
+
+test('generateReceipt() rejects invalid bags', () => {
+    let validator = getBagItValidator("open_bag");
+    return validateThen(validator).then(function() {
+        expect(validator.errors.length).toBeGreaterThan(0);
+        return validator.generateReceipt();
+    }).then(function() {
+        throw new Error('Expected generateReceipt() to reject.');
+    }, function(err) {
+        expect(err.message).toEqual('Cannot generate a receipt for invalid bag open_bag.');
+    });
+});
+
+test('Validator treats split manifests as one manifest', done => {
+    let validator = getBagItValidator("split_manifest");
+    validator.profile.manifestsRequired = ['sha256'];
+    validator.profile.tagManifestsRequired = ['sha256'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.manifestAlgorithmsFoundInBag).toEqual(['sha256']);
+        expect(validator.payloadManifests().map(m => m.relDestPath)).toEqual(['manifest-sha256.txt']);
+        expect(validator.payloadManifests()[0].keyValueCollection.sortedKeys()).toEqual([
+            "data/docs/second.txt",
+            "data/first.txt"
+        ]);
+        expect(validator.tagManifests().map(m => m.relDestPath)).toEqual(['tagmanifest-sha256.txt']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator catches bad checksums in split manifests', done => {
+    let validator = getBagItValidator("split_manifest");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bad sha256 digest for 'data/first.txt': manifest says '65a4afb6b2e4d34598a1ee6965b029f8bb785751cb474b2033d3fc6e3401904c', file digest is 'not-the-real-digest'."
+        ]);
+        done();
+    });
+    let hashCompleted = validator._hashCompleted;
+    validator._hashCompleted = function(bagItFile, cbData) {
+        if (bagItFile.relDestPath == 'data/first.txt') {
+            bagItFile.checksums[cbData.algorithm] = 'not-the-real-digest';
+        }
+        hashCompleted.call(validator, bagItFile, cbData);
+    };
+    validator.validate();
+});
+
+test('Validator emits stage and fileProcessed events', done => {
+    let validator = getBagItValidator("valid_bag");
+    let stages = [];
+    let processed = [];
+    validator.on('stage', stage => stages.push(stage));
+    validator.on('fileProcessed', function(bagItFile, bytesProcessed) {
+        processed.push([bagItFile.relDestPath, bytesProcessed]);
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(stages).toEqual(['profile', 'serialization', 'scan', 'read', 'contents']);
+        expect(processed.map(p => p[0]).sort()).toEqual(Object.keys(validator.files).sort());
+        let totalBytes = Object.values(validator.files).reduce((sum, f) => sum + f.size, 0);
+        expect(processed[processed.length - 1][1]).toEqual(totalBytes);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator emits fileProcessed when skipping checksums', done => {
+    let validator = getBagItValidator("valid_bag");
+    let processed = [];
+    validator.on('fileProcessed', bagItFile => processed.push(bagItFile.relDestPath));
+    validator.on('end', function() {
+        expect(processed.sort()).toEqual(Object.keys(validator.files).sort());
+        done();
+    });
+    validator.validateStructure();
+});
+
+test('Validator compares payload with inventory', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.inventory = ['data/first.txt', 'data/docs/third.txt'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Inventory lists data/docs/third.txt, which is missing from bag.",
+            "Payload file data/docs/second.txt is not in the inventory."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['inventory', 'inventory']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts payload that matches inventory', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.inventory = ['data/docs/second.txt', 'data/first.txt'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags paths deeper than maxPathDepth', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.maxPathDepth = 1;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "File path data/docs/second.txt is 2 directories deep, which exceeds the limit of 1."
+        ]);
+        expect(validator.results[0].check).toEqual('pathDepth');
+        expect(validator.results[0].filePath).toEqual('data/docs/second.txt');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts paths within maxPathDepth', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.maxPathDepth = 2;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+function cancelAfterFirstChecksum(validator, done) {
+    let endCount = 0;
+    let filesStarted = 0;
+    validator.on('task', function(taskDesc) {
+        if (taskDesc.op == 'checksum') {
+            filesStarted++;
+            validator.cancel();
+        }
+    });
+    validator.on('end', function() {
+        endCount++;
+        expect(validator.errors).toEqual(['Validation was cancelled.']);
+        expect(validator.results[0].check).toEqual('cancelled');
+        // Make sure the validator stopped reading and didn't end twice.
+        setTimeout(function() {
+            expect(endCount).toEqual(1);
+            expect(filesStarted).toEqual(1);
+            validator.cancel();
+            expect(validator.errors.length).toEqual(1);
+            done();
+        }, 200);
+    });
+    validator.validate();
+}
+
+test('cancel() stops validation of a tarred bag', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good.tar");
+    cancelAfterFirstChecksum(validator, done);
+});
+
+test('cancel() stops validation of a directory bag', done => {
+    let validator = getBagItValidator("valid_bag");
+    cancelAfterFirstChecksum(validator, done);
+});
+
+test('cancel() has no effect after validation ends', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.on('end', function() {
+        validator.cancel();
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('listContents() lists files in a directory bag', () => {
+    let bagPath = path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag");
+    let validator = new Validator(bagPath, null);
+    return validator.listContents().then(function(files) {
+        expect(files.map(f => f.relDestPath)).toEqual([
+            "bag-info.txt",
+            "bagit.txt",
+            "data/docs/second.txt",
+            "data/first.txt",
+            "manifest-sha256.txt"
+        ]);
+        expect(files.map(f => f.fileType)).toEqual([
+            Constants.TAG_FILE,
+            Constants.TAG_FILE,
+            Constants.PAYLOAD_FILE,
+            Constants.PAYLOAD_FILE,
+            Constants.PAYLOAD_MANIFEST
+        ]);
+        expect(files[3].size).toEqual(20);
+        expect(files[3].absSourcePath).toEqual(path.join(bagPath, "data", "first.txt"));
+        expect(files.every(f => Object.keys(f.checksums).length == 0)).toBe(true);
+        expect(validator.errors).toEqual([]);
+        expect(validator.files).toEqual({});
+    });
+});
+
+test('listContents() lists files in a tarred bag', () => {
+    let bagPath = path.join(__dirname, "..", "test", "bags", "bagit", "windows_unfriendly.tar");
+    let validator = new Validator(bagPath, null);
+    return validator.listContents().then(function(files) {
+        expect(files.map(f => f.relDestPath)).toEqual([
+            "bag-info.txt",
+            "bagit.txt",
+            "data/CON.txt",
+            "data/docs./second.txt",
+            "data/first.txt",
+            "data/what?.txt",
+            "manifest-sha256.txt"
+        ]);
+        expect(files[4].size).toEqual(20);
+        expect(files[4].fileType).toEqual(Constants.PAYLOAD_FILE);
+        expect(files[4].absSourcePath).toEqual('');
+    });
+});
+
+test('Validator rejects serialized bag whose content does not match its extension', done => {
+    let validator = getBagItValidator("zip_named_tar.tar");
+    let errorMessage = null;
+    validator.on('error', function(err) {
+        errorMessage = err;
+    });
+    validator.on('end', function() {
+        let expected = "Extension/content mismatch: zip_named_tar.tar has extension .tar, but its content is in zip format.";
+        expect(validator.errors).toEqual([expected]);
+        expect(errorMessage).toEqual(expected);
+        expect(validator.results[0].check).toEqual('contentType');
+        done();
+    });
+    validator.validate();
+});
+
+test('_sniffFormat() identifies tar files', () => {
+    let validator = getBagItValidator("windows_unfriendly.tar");
+    expect(validator._sniffFormat()).toEqual('tar');
+    expect(validator._validateContentType()).toBe(true);
+});
+
+function getEqualsDelimitedValidator(tagDelimiter) {
+    let validator = getBagItValidator("equals_delimited_tags");
+    validator.profile.tagDelimiter = tagDelimiter;
+    validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0].required = true;
+    return validator;
+}
+
+test('Validator parses tags with the profile tag delimiter', done => {
+    let validator = getEqualsDelimitedValidator('=');
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        let bagInfo = validator.files["bag-info.txt"].keyValueCollection;
+        expect(bagInfo.first("Source-Organization")).toEqual("Example University");
+        expect(bagInfo.first("External-Description")).toEqual("Tags in this file use = as the delimiter.");
+        expect(validator.files["bagit.txt"].keyValueCollection.first("BagIt-Version")).toEqual("1.0");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator cannot read tags with a non-standard delimiter by default', done => {
+    let validator = getEqualsDelimitedValidator(':');
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        let parseError = "expected a tag name followed by ':' and a value";
+        expect(validator.errors).toEqual([
+            `Required tag file bag-info.txt failed to parse at line 1: ${parseError}`,
+            `Required tag file bag-info.txt failed to parse at line 2: ${parseError}`,
+            `Required tag file bag-info.txt failed to parse at line 3: ${parseError}`,
+            `Required tag file bag-info.txt failed to parse at line 4: ${parseError}`,
+            "Required tag Source-Organization is missing from bag-info.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+// Returns true if value is a string, number, or boolean.
+function isScalar(value) {
+    return ['string', 'number', 'boolean'].includes(typeof value);
+}
+
+test('protoResult() returns flat, serializable validation summary', done => {
+    let validator = getBagItValidator("payload_manifest_lists_tag_files");
+    validator.on('end', function() {
+        let result = validator.protoResult();
+        expect(result.bagName).toEqual("payload_manifest_lists_tag_files");
+        expect(result.valid).toBe(false);
+        expect(result.resultCode).toEqual(3);
+        expect(result.errors).toEqual(validator.errors);
+        expect(result.warnings).toEqual([]);
+        expect(result.payloadFileCount).toEqual(2);
+        expect(result.payloadByteCount).toEqual(41);
+        expect(result.tagFileCount).toEqual(2);
+        expect(result.manifestCount).toEqual(1);
+        expect(result.tagManifestCount).toEqual(0);
+        expect(result.results.length).toEqual(2);
+        expect(result.files.map(f => [f.path, f.status])).toEqual([
+            ["bag-info.txt", "error"],
+            ["bagit.txt", "error"],
+            ["data/docs/second.txt", "ok"],
+            ["data/first.txt", "ok"],
+            ["manifest-sha256.txt", "ok"]
+        ]);
+
+        // Fields must be scalars or lists of scalars or flat objects.
+        for (let value of Object.values(result)) {
+            if (Array.isArray(value)) {
+                for (let item of value) {
+                    expect(isScalar(item) || Object.values(item).every(isScalar)).toBe(true);
+                }
+            } else {
+                expect(isScalar(value)).toBe(true);
+            }
+        }
+
+        // Round trip
+        let copy = JSON.parse(JSON.stringify(result));
+        expect(copy).toEqual(result);
+        done();
+    });
+    validator.validate();
+});
+
+test('report() summarizes validation results for scripts', done => {
+    let validator = getBagItValidator("md5_and_sha256");
+    validator.on('end', function() {
+        let report = validator.report();
+        expect(report.bagPath).toEqual(validator.pathToBag);
+        expect(report.profileName).toEqual(validator.profile.name);
+        expect(report.valid).toBe(true);
+        expect(report.errors).toEqual([]);
+        expect(report.payloadByteCount).toEqual(41);
+        expect(report.payloadFileCount).toEqual(2);
+        expect(report.algorithms).toEqual(['md5', 'sha256']);
+        expect(report.elapsedMs).toBeGreaterThanOrEqual(0);
+
+        // Round trip
+        let copy = JSON.parse(JSON.stringify(report));
+        expect(copy).toEqual(report);
+        done();
+    });
+    validator.validate();
+});
+
+test('report() includes structured errors', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_bad.tar");
+    validator.on('end', function() {
+        let report = validator.report();
+        expect(report.valid).toBe(false);
+        expect(report.errors.map(e => e.message)).toEqual(validator.errors);
+        expect(report.errors.find(e => e.check == 'checksums')).toEqual({
+            severity: 'error',
+            check: 'checksums',
+            filePath: 'data/datastream-descMetadata',
+            message: validator.errors[1],
+            expected: 'This-checksum-is-bad-on-purpose.-The-validator-should-catch-it!!',
+            actual: 'cf9cbce80062932e10ee9cd70ec05ebc24019deddfea4e54b8788decd28b4bc7',
+            actualSize: validator.files['data/datastream-descMetadata'].size
+        });
+        done();
+    });
+    validator.validate();
+});
+
+// FailingReader reads a directory like FileSystemReader, except that
+// the stream for failPath returns a few bytes and then fails, the
+// way a file on a bad disk might.
+class FailingReader extends FileSystemReader {
+    constructor(pathToDirectory, failPath) {
+        super(pathToDirectory);
+        this.failPath = failPath;
+    }
+    emit(eventName, entry) {
+        if (eventName == 'entry' && entry.stream && entry.relPath == this.failPath) {
+            entry.stream.destroy();
+            let failingStream = new PassThrough();
+            setImmediate(function() {
+                failingStream.write("First");
+                failingStream.emit('error', new Error("EIO: i/o error, read"));
+            });
+            entry = { relPath: entry.relPath, fileStat: entry.fileStat, stream: failingStream };
+        }
+        return super.emit(eventName, entry);
+    }
+}
+
+test('Validator attributes read errors to the file and keeps going', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.getNewReader = function() {
+        return new FailingReader(validator.pathToBag, path.join("data", "first.txt"));
+    };
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Read error in data/first.txt: Error: EIO: i/o error, read"
+        ]);
+        expect(validator.results[0].check).toEqual('readError');
+        expect(validator.results[0].filePath).toEqual('data/first.txt');
+        expect(validator.files["data/first.txt"].checksums).toEqual({});
+        expect(validator.files["data/docs/second.txt"].checksums.sha256).toEqual("2b0e855c0292e8c43dc1570a285d3db75dfb694b4f2e052debe946e057196fa5");
+        expect(validator.resultCode()).toEqual(5);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator warns when manifests do not match Manifest-Algorithm tag', done => {
+    let validator = getBagItValidator("manifest_algorithm_mismatch");
+    validator.checkManifestAlgorithmTag = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([
+            "bag-info.txt declares Manifest-Algorithm md5, but the bag has no manifest-md5.txt.",
+            "Bag has manifest-sha512.txt, but bag-info.txt does not declare sha512 in Manifest-Algorithm."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['manifestAlgorithmTag', 'manifestAlgorithmTag']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores Manifest-Algorithm tag by default', done => {
+    let validator = getBagItValidator("manifest_algorithm_mismatch");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('remediationPlan() lists steps to fix missing manifest and tag', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.manifestsRequired = ['sha256', 'md5'];
+    let contactEmail = validator.profile.getTagsFromFile("bag-info.txt", "Contact-Email")[0];
+    contactEmail.required = true;
+    contactEmail.defaultValue = "curator@example.edu";
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag is missing required manifest manifest-md5.txt",
+            "Required tag Contact-Email is missing from bag-info.txt"
+        ]);
+        expect(validator.remediationPlan()).toEqual([
+            { action: 'generateManifest', filePath: 'manifest-md5.txt', algorithm: 'md5' },
+            { action: 'addTag', filePath: 'bag-info.txt', tagName: 'Contact-Email', value: 'curator@example.edu' }
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('remediationPlan() says to serialize bag', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.sample_good");
+    validator.on('error', function() {});
+    validator.on('end', function() {
+        expect(validator.remediationPlan()).toEqual([
+            { action: 'serialize', format: 'application/tar' }
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('remediationPlan() says to review problems it cannot fix', done => {
+    let validator = getBagItValidator("payload_manifest_lists_tag_files");
+    validator.on('end', function() {
+        expect(validator.remediationPlan()).toEqual([
+            {
+                action: 'review',
+                check: 'manifestEntries',
+                filePath: 'bagit.txt',
+                message: "Payload manifest manifest-sha256.txt lists bagit.txt, which is a tag file, not a payload file."
+            },
+            {
+                action: 'review',
+                check: 'manifestEntries',
+                filePath: 'bag-info.txt',
+                message: "Payload manifest manifest-sha256.txt lists bag-info.txt, which is a tag file, not a payload file."
+            }
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('remediationPlan() is empty for valid bag', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.on('end', function() {
+        expect(validator.remediationPlan()).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports parse errors in required tag files', done => {
+    let validator = getBagItValidator("malformed_bag_info");
+    validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0].required = true;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Required tag file bag-info.txt failed to parse at line 2: expected a tag name followed by ':' and a value"
+        ]);
+        expect(validator.results[0].check).toEqual('tagFileParse');
+        expect(validator.results[0].filePath).toEqual('bag-info.txt');
+        // Tags on the lines that did parse are still validated.
+        expect(validator.files["bag-info.txt"].keyValueCollection.first("Contact-Name")).toEqual("Jane Curator");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores parse errors in tag files with no required tags', done => {
+    let validator = getBagItValidator("malformed_bag_info");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('tagValues() returns all values of a tag', done => {
+    let validator = getBagItValidator("contact_emails");
+    validator.on('end', function() {
+        expect(validator.tagValues("bag-info.txt", "Contact-Email")).toEqual(["curator@example.edu", "curator@example"]);
+        expect(validator.tagValues("bag-info.txt", "No-Such-Tag")).toEqual([]);
+        expect(validator.tagValues("no-such-file.txt", "Contact-Email")).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+// MockSignatureVerifier accepts a signature if it's the sha256 digest
+// of the key followed by the manifest bytes. The signature in the
+// signed_bag fixture was made with the key 'test-key'.
+class MockSignatureVerifier extends SignatureVerifier {
+    constructor(key) {
+        super();
+        this.key = key;
+        this.calls = [];
+    }
+    verify(manifestPath, manifestBytes, signatureBytes) {
+        this.calls.push(manifestPath);
+        if (this.key == null) {
+            return Promise.reject(new Error('No key in keyring'));
+        }
+        let hash = crypto.createHash('sha256');
+        hash.update(this.key);
+        hash.update(manifestBytes);
+        return Promise.resolve(hash.digest('hex') == signatureBytes.toString().trim());
+    }
+}
+
+test('Validator accepts manifest with valid detached signature', done => {
+    let validator = getBagItValidator("signed_bag");
+    let verifier = new MockSignatureVerifier('test-key');
+    validator.signatureVerifier = verifier;
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(verifier.calls).toEqual(["tagmanifest-sha256.txt"]);
+        expect(validator.files["tagmanifest-sha256.txt.asc"].isTagFile()).toEqual(true);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects manifest with bad detached signature', done => {
+    let validator = getBagItValidator("signed_bag");
+    validator.signatureVerifier = new MockSignatureVerifier('other-key');
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Signature tagmanifest-sha256.txt.asc does not verify against tagmanifest-sha256.txt."
+        ]);
+        expect(validator.results[0].check).toEqual('signature');
+        expect(validator.results[0].filePath).toEqual('tagmanifest-sha256.txt.asc');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reports signatures it cannot verify', done => {
+    let validator = getBagItValidator("signed_bag");
+    validator.signatureVerifier = new MockSignatureVerifier(null);
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Could not verify signature tagmanifest-sha256.txt.asc: Error: No key in keyring"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores signatures when there is no signatureVerifier', done => {
+    let validator = getBagItValidator("signed_bag");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator._signatureBytes).toEqual({});
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags payload files whose MIME type is not allowed', done => {
+    let validator = getBagItValidator("mime_types");
+    validator.allowedPayloadMimeTypes = ['application/pdf', 'image/tiff'];
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload file data/setup.exe has type application/octet-stream, which is not allowed. [Allowed: application/pdf, image/tiff]"
+        ]);
+        expect(validator.results[0].check).toEqual('mimeType');
+        expect(validator.results[0].filePath).toEqual('data/setup.exe');
+        expect(validator._payloadMimeTypes).toEqual({
+            "data/report.pdf": "application/pdf",
+            "data/scan.tif": "image/tiff",
+            "data/setup.exe": "application/octet-stream"
+        });
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator allows all MIME types by default', done => {
+    let validator = getBagItValidator("mime_types");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator._payloadMimeTypes).toEqual({});
+        done();
+    });
+    validator.validate();
+});
+
+test('detectMimeType()', () => {
+    expect(Validator.detectMimeType(Buffer.from('%PDF-1.7\n'))).toEqual('application/pdf');
+    expect(Validator.detectMimeType(Buffer.from('4d4d002a00000008', 'hex'))).toEqual('image/tiff');
+    expect(Validator.detectMimeType(Buffer.from('Plain old text.\r\n'))).toEqual('text/plain');
+    expect(Validator.detectMimeType(Buffer.from(''))).toEqual('text/plain');
+    expect(Validator.detectMimeType(Buffer.from('4d5a900003', 'hex'))).toEqual('application/octet-stream');
+});
+
+test('Validator entry count matches classified files in good bag', done => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator._regularFileEntries).toEqual(16);
+        expect(Object.keys(validator.files).length).toEqual(16);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator flags entry count mismatch in tar with duplicate entries', done => {
+    let validator = getBagItValidator("duplicate_entry.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag contains 6 regular files, but the validator processed 5 (2 payload files, 2 tag files, 1 manifests, 0 tag manifests). Some entries may be duplicated or corrupt.",
+            "Archive contains 2 entries for data/first.txt. Only the last one was validated."
+        ]);
+        expect(validator.results[0].check).toEqual('entryCount');
+        expect(validator.results[1].check).toEqual('duplicateEntries');
+        expect(validator.results[1].filePath).toEqual('data/first.txt');
+        done();
+    });
+    validator.validate();
+});
+
+function getCompressedBagValidator() {
+    let validator = getBagItValidator("compressed_bag.tar.gz");
+    validator.profile.acceptSerialization = ['application/tar+gzip'];
+    return validator;
+}
+
+test('compressionRatio() for compressed bag', done => {
+    let validator = getCompressedBagValidator();
+    expect(validator.bagName).toEqual("compressed_bag");
+    expect(validator.readingFromTar()).toBe(true);
+    expect(validator.readingFromCompressedTar()).toBe(true);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([]);
+        expect(validator.payloadFiles().length).toEqual(2);
+        // The tar file is 10240 bytes. Gzipped, it's 460.
+        expect(validator.compressionRatio()).toEqual(10240 / 460);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reads gzipped tar bag with .tgz extension', done => {
+    let validator = getBagItValidator("compressed_bag.tgz");
+    validator.profile.acceptSerialization = ['application/tar+gzip'];
+    expect(validator.bagName).toEqual("compressed_bag");
+    expect(validator.readingFromCompressedTar()).toBe(true);
+    expect(validator.getNewReader() instanceof TarReader).toBe(true);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/docs/second.txt",
+            "data/first.txt"
+        ]);
+        expect(validator.compressionRatio()).toEqual(10240 / 460);
+        done();
+    });
+    validator.validate();
+});
+
+function getStreamValidator(bagName) {
+    let validator = new Validator('-', new BagItProfile());
+    let bagPath = path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+    validator.inputStream = fs.createReadStream(bagPath).pipe(new PassThrough());
+    return validator;
+}
+
+test('reset() lets a validator validate another bag with the same settings', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    let badBagErrors = [];
+    validator.skipChecks = ['payloadOxum'];
+    validator.on('end', function() {
+        if (validator.bagName == "incomplete_md5_manifest") {
+            badBagErrors = validator.errors.slice();
+            validator.reset(path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag"));
+            expect(validator.bagName).toEqual("valid_bag");
+            expect(validator.errors).toEqual([]);
+            expect(validator.results).toEqual([]);
+            expect(validator.files).toEqual({});
+            expect(validator.skipChecks).toEqual(['payloadOxum']);
+            validator.validate();
+            return;
+        }
+        expect(badBagErrors.length).toEqual(2);
+        expect(validator.errors).toEqual([]);
+        expect(validator.payloadFiles().length).toEqual(2);
+        done();
+    });
+    validator.validate();
+});
+
+test('reset() refuses to reset a validator during validation', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.on('end', function() {
+        expect(() => validator.reset()).not.toThrow();
+        done();
+    });
+    validator.validate();
+    expect(() => validator.reset()).toThrow("Cannot reset validator while it is validating");
+});
+
+test('validateAndExtract() validates and extracts a good bag', done => {
+    let destDir = fs.mkdtempSync(path.join(os.tmpdir(), 'validator-extract-'));
+    let validator = getBagItValidator("payload_first.tar");
+    validator.validateAndExtract(destDir).then(function(valid) {
+        expect(valid).toBe(true);
+        expect(validator.errors).toEqual([]);
+        let bagDir = path.join(destDir, "payload_first");
+        for (let relPath of Object.keys(validator.files)) {
+            let extracted = fs.readFileSync(path.join(bagDir, relPath));
+            let digest = crypto.createHash('sha256').update(extracted).digest('hex');
+            expect(digest).toEqual(validator.files[relPath].checksums.sha256);
+        }
+        Util.deleteRecursive(destDir);
+        done();
+    });
+});
+
+test('validateAndExtract() refuses to extract paths outside the bag', done => {
+    let destDir = fs.mkdtempSync(path.join(os.tmpdir(), 'validator-extract-'));
+    let validator = getBagItValidator("reserved_path_collision.tar");
+    validator.validateAndExtract(destDir).then(function(valid) {
+        let bagDir = path.join(destDir, "reserved_path_collision");
+        expect(valid).toBe(false);
+        expect(validator.results.filter(r => r.check == 'extract').map(r => r.message)).toEqual([
+            `Refused to extract data/../bagit.txt, which would be written outside ${bagDir}.`,
+            `Refused to extract data/docs/../../../escaped.txt, which would be written outside ${bagDir}.`
+        ]);
+        expect(fs.existsSync(path.join(destDir, "escaped.txt"))).toBe(false);
+        expect(fs.readFileSync(path.join(bagDir, "bagit.txt"), 'utf8')).toMatch(/^BagIt-Version/);
+        Util.deleteRecursive(destDir);
+        done();
+    });
+});
+
+test('validateAndExtract() rejects bags that are not serialized', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.validateAndExtract(os.tmpdir()).catch(function(err) {
+        expect(err.message).toMatch(/can only extract tarred or zipped bags/);
+        done();
+    });
+});
+
+test('validateAndExtract() rejects a truncated tar file', done => {
+    let tarFile = truncatedTar("payload_first.tar");
+    let destDir = fs.mkdtempSync(path.join(os.tmpdir(), 'validator-extract-'));
+    let validator = new Validator(tarFile, new BagItProfile());
+    validator.validateAndExtract(destDir).catch(function(err) {
+        expect(err).toBeInstanceOf(Error);
+        Util.deleteRecursive(path.dirname(tarFile));
+        Util.deleteRecursive(destDir);
+        done();
+    });
+});
+
+test('Validator warns about tag file encodings other than UTF-8', done => {
+    let validator = getBagItValidator("latin1_declared_utf8_tags");
+    validator.on('end', function() {
+        expect(validator.warnings).toEqual([
+            "bagit.txt declares Tag-File-Character-Encoding ISO-8859-1. The BagIt spec recommends UTF-8."
+        ]);
+        expect(validator.results.filter(r => r.severity == 'warning').map(r => r.check)).toEqual(['tagFileEncoding']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects tag file encodings other than UTF-8 when nonUtf8EncodingSeverity is error', done => {
+    let validator = getBagItValidator("latin1_declared_utf8_tags");
+    validator.nonUtf8EncodingSeverity = 'error';
+    validator.on('end', function() {
+        expect(validator.errors[0]).toEqual("bagit.txt declares Tag-File-Character-Encoding ISO-8859-1. The BagIt spec recommends UTF-8.");
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator allows tag file encodings other than UTF-8 when nonUtf8EncodingSeverity is empty', done => {
+    let validator = getBagItValidator("latin1_declared_utf8_tags");
+    validator.nonUtf8EncodingSeverity = '';
+    validator.on('end', function() {
+        expect(validator.warnings).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator enforces the allowed values of Tag-File-Character-Encoding', done => {
+    let validator = getBagItValidator("latin1_declared_utf8_tags");
+    validator.profile.getTagsFromFile("bagit.txt", "Tag-File-Character-Encoding")[0].values = ['UTF-8'];
+    validator.on('end', function() {
+        expect(validator.errors).toContain("Tag 'Tag-File-Character-Encoding' in bagit.txt contains illegal value 'ISO-8859-1'. [Allowed: UTF-8]");
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator parses tag files in the declared encoding', done => {
+    let validator = getBagItValidator("latin1_tags");
+    let tagDef = validator.profile.getTagsFromFile("bag-info.txt", "Source-Organization")[0];
+    tagDef.values = ['Université Example'];
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.files['bag-info.txt'].keyValueCollection.first('Source-Organization')).toEqual('Université Example');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator requires BagIt-Version even if the profile does not', done => {
+    let validator = getBagItValidator("bagit_txt_no_version");
+    validator.profile.getTagsFromFile("bagit.txt", "BagIt-Version")[0].required = false;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "bagit.txt does not declare BagIt-Version, which the BagIt spec requires."
+        ]);
+        expect(validator.results[0].check).toEqual('bagItVersion');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator requires Tag-File-Character-Encoding in bagit.txt', done => {
+    let validator = getBagItValidator("bagit_txt_no_encoding");
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Required tag Tag-File-Character-Encoding is missing from bagit.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator requires Tag-File-Character-Encoding even if the profile does not', done => {
+    let validator = getBagItValidator("bagit_txt_no_encoding");
+    validator.profile.getTagsFromFile("bagit.txt", "Tag-File-Character-Encoding")[0].required = false;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "bagit.txt does not declare Tag-File-Character-Encoding, which the BagIt spec requires."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reads tarred bag from a stream', done => {
+    let validator = getStreamValidator("payload_first.tar");
+    expect(validator.readingFromStream()).toBe(true);
+    expect(validator.readingFromArchive()).toBe(true);
+    expect(validator.getNewReader() instanceof TarReader).toBe(true);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.bagName).toEqual("payload_first");
+        expect(validator.manifestAlgorithmsFoundInBag).toEqual(['sha256']);
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/docs/second.txt",
+            "data/first.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator reads gzipped tarred bag from a stream', done => {
+    let validator = getStreamValidator("compressed_bag.tar.gz");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.bagName).toEqual("compressed_bag");
+        expect(validator.payloadFiles().map(f => f.relDestPath).sort()).toEqual([
+            "data/docs/second.txt",
+            "data/first.txt"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('validateStreamingTo() writes passing and failing files', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    let passed = '';
+    let failed = '';
+    let passStream = new PassThrough();
+    let failStream = new PassThrough();
+    passStream.on('data', chunk => passed += chunk);
+    failStream.on('data', chunk => failed += chunk);
+    validator.validateStreamingTo(passStream, failStream).then(isValid => {
+        expect(isValid).toBe(false);
+        expect(passed).toEqual("data/first.txt\n");
+        expect(failed).toEqual("data/docs/second.txt\n");
+        done();
+    });
+});
+
+// truncatedTar copies the first 1000 bytes of a tarred test bag into a
+// temp directory, and returns the path to the copy. The copy ends partway
+// through the first entry, so the tar reader can't finish it.
+function truncatedTar(bagName) {
+    let tarFile = path.join(__dirname, "..", "test", "bags", "bagit", bagName);
+    let data = fs.readFileSync(tarFile);
+    let copy = path.join(fs.mkdtempSync(path.join(os.tmpdir(), 'validator-truncated-')), bagName);
+    fs.writeFileSync(copy, data.slice(0, 1000));
+    return copy;
+}
+
+test('validateStreamingTo() rejects when it cannot read the bag', done => {
+    let tarFile = truncatedTar("payload_first.tar");
+    let validator = new Validator(tarFile, new BagItProfile());
+    validator.validateStreamingTo(new PassThrough(), new PassThrough()).catch(function(err) {
+        expect(err).toBeInstanceOf(Error);
+        expect(validator.listenerCount('fileProcessed')).toEqual(0);
+        Util.deleteRecursive(path.dirname(tarFile));
+        done();
+    });
+});
+
+test('validateStreamingTo() stops writing when validation ends', done => {
+    let validator = getBagItValidator("incomplete_md5_manifest");
+    let passed = '';
+    let passStream = new PassThrough();
+    passStream.on('data', chunk => passed += chunk);
+    validator.validateStreamingTo(passStream, new PassThrough()).then(function() {
+        expect(validator.listenerCount('fileProcessed')).toEqual(0);
+        validator.reset(path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag"));
+        validator.once('end', function() {
+            expect(passed).toEqual("data/first.txt\n");
+            done();
+        });
+        validator.validate();
+    });
+});
+
+test('Validator reads from STDIN when pathToBag is -', () => {
+    let validator = new Validator('-', new BagItProfile());
+    expect(validator.readingFromStream()).toBe(true);
+    expect(validator.getNewReader().inputStream).toBe(process.stdin);
+});
+
+test('Validator catches bad checksums in a stream', done => {
+    let validator = getStreamValidator("payload_first.tar");
+    let original = validator.inputStream;
+    // Alter one payload file's contents on the way through, without
+    // changing its length.
+    validator.inputStream = original.pipe(new Transform({
+        transform(chunk, encoding, callback) {
+            callback(null, Buffer.from(chunk.toString('latin1').replace('First payload', 'Worst payload'), 'latin1'));
+        }
+    }));
+    validator.on('end', function() {
+        expect(validator.errors.some(e => e.includes('data/first.txt'))).toBe(true);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects payload paths that resolve outside the payload directory', done => {
+    let validator = getBagItValidator("reserved_path_collision.tar");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Payload file data/../bagit.txt resolves to bagit.txt, which is reserved for bag metadata.",
+            "Payload file data/docs/../../../escaped.txt resolves to ../escaped.txt, which is outside the payload directory."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['payloadPaths', 'payloadPaths']);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator warns about high compression ratio', done => {
+    let validator = getCompressedBagValidator();
+    validator.compressionRatioWarning = 20;
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.warnings).toEqual([
+            "Bag has compression ratio 22.3, which is higher than the warning threshold of 20. It may be padded with filler."
+        ]);
+        expect(validator.results[0].check).toEqual('compressionRatio');
+        done();
+    });
+    validator.validate();
+});
+
+test('compressionRatio() is null for uncompressed bags', () => {
+    let validator = getValidator("aptrust_2.2.json", "aptrust", "example.edu.tagsample_good.tar");
+    expect(validator.readingFromCompressedTar()).toBe(false);
+    expect(validator.compressionRatio()).toBeNull();
+});
+
+test('Validator explains manifest misplaced in payload directory', done => {
+    let validator = getBagItValidator("misplaced_manifest");
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Bag is missing required manifest manifest-sha256.txt"
+        ]);
+        expect(validator.warnings).toEqual([
+            "Found manifest-sha256.txt inside data/ at data/manifest-sha256.txt. It must be at the bag root."
+        ]);
+        expect(validator.results.map(r => r.check)).toEqual(['requiredManifests', 'misplacedManifest']);
+        expect(validator.results[1].filePath).toEqual('data/manifest-sha256.txt');
+        done();
+    });
+    validator.validate();
+});
+
+function addRightsStatementTag(profile) {
+    profile.tags.push(new TagDefinition({
+        tagFile: "bag-info.txt",
+        tagName: "Rights-Statement",
+        requiredWhen: { "Access": "Restricted" }
+    }));
+}
+
+test('Validator enforces conditional tag requirement when condition is met', done => {
+    let validator = getBagItValidator("restricted_access");
+    addRightsStatementTag(validator.profile);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Required tag Rights-Statement is missing from bag-info.txt. It is required when Access is 'Restricted'."
+        ]);
+        expect(validator.remediationPlan()).toEqual([
+            { action: 'addTag', filePath: 'bag-info.txt', tagName: 'Rights-Statement' }
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator skips conditional tag requirement when condition is not met', done => {
+    let validator = getBagItValidator("valid_bag");
+    addRightsStatementTag(validator.profile);
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        expect(validator.remediationPlan()).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator accepts Source-Organization that matches the profile', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.requireSourceOrganizationMatch = true;
+    validator.profile.bagItProfileInfo.sourceOrganization = "Example University";
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects Source-Organization that does not match the profile', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.requireSourceOrganizationMatch = true;
+    validator.profile.bagItProfileInfo.sourceOrganization = "example.edu";
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Source-Organization 'Example University' in bag-info.txt does not match the profile's source organization 'example.edu'."
+        ]);
+        expect(validator.results[0].check).toEqual('sourceOrganization');
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator ignores Source-Organization mismatch unless the profile requires a match', done => {
+    let validator = getBagItValidator("valid_bag");
+    validator.profile.bagItProfileInfo.sourceOrganization = "example.edu";
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+function addDispositionTags(profile) {
+    profile.tags.push(new TagDefinition({
+        tagFile: "bag-info.txt",
+        tagName: "Disposition",
+        required: true,
+        values: ["Retain", "Destroy", "Transfer"]
+    }));
+    profile.tags.push(new TagDefinition({
+        tagFile: "bag-info.txt",
+        tagName: "Retention-Period",
+        requiredWhen: { "Disposition": "Retain" }
+    }));
+}
+
+test('Validator accepts Retain disposition with a retention period', done => {
+    let validator = getBagItValidator("disposition_retain");
+    addDispositionTags(validator.profile);
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator requires a retention period for Retain disposition', done => {
+    let validator = getBagItValidator("disposition_retain_no_period");
+    addDispositionTags(validator.profile);
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Required tag Retention-Period is missing from bag-info.txt. It is required when Disposition is 'Retain'."
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('Validator rejects disposition values not in the profile', done => {
+    let validator = getBagItValidator("disposition_invalid");
+    addDispositionTags(validator.profile);
+    validator.on('end', function() {
+        expect(validator.errors).toEqual([
+            "Tag 'Disposition' in bag-info.txt contains illegal value 'Shred'. [Allowed: Retain, Destroy, Transfer]"
+        ]);
+        done();
+    });
+    validator.validate();
+});
+
+test('payloadMerkleRoot() is stable and changes when a file changes', done => {
+    let first = getBagItValidator("valid_bag");
+    let second = getBagItValidator("md5_and_sha256");
+    validateThen(first).then(() => validateThen(second)).then(() => {
+        let root = first.payloadMerkleRoot('sha256');
+        expect(root).toMatch(/^[0-9a-f]{64}$/);
+        expect(first.payloadMerkleRoot('sha256')).toEqual(root);
+        // Same payload in a different bag.
+        expect(second.payloadMerkleRoot('sha256')).toEqual(root);
+
+        let tree = first.payloadMerkleTree('sha256');
+        expect(tree.map(level => level.length)).toEqual([2, 1]);
+        let leaves = first.manifestContent('sha256').split('\n').filter(line => line != '').map(line =>
+            crypto.createHash('sha256').update(line).digest('hex'));
+        expect(tree[0]).toEqual(leaves);
+        expect(tree[1][0]).toEqual(crypto.createHash('sha256').update(
+            Buffer.concat(leaves.map(leaf => Buffer.from(leaf, 'hex')))).digest('hex'));
+
+        // Change one file's digest.
+        second.payloadFiles()[0].checksums['sha256'] = '0'.repeat(64);
+        expect(second.payloadMerkleRoot('sha256')).not.toEqual(root);
+        expect(() => { first.payloadMerkleRoot('sha1') }).toThrow("Validator has no sha1 checksum for data/");
+        done();
+    });
+});
+
+test('writeManifest() writes computed checksums in manifest format', done => {
+    let validator = getBagItValidator("open_bag");
+    let expected = fs.readFileSync(path.join(__dirname, "..", "test", "bags", "bagit", "valid_bag", "manifest-sha256.txt")).toString();
+    validator.on('error', function(err) {
+        // Force failure & stop test.
+        expect(err).toBeNull();
+        done();
+    });
+    validator.on('end', function() {
+        expect(validator.errors).toEqual(["Bag is missing required manifest manifest-sha256.txt"]);
+        expect(validator.manifestContent('sha256')).toEqual(expected);
+        expect(() => { validator.manifestContent('md5') }).toThrow("Validator has no md5 checksum for data/");
+
+        // Write the manifest into a copy of the bag, which should
+        // then be valid.
+        let bagDir = Util.tmpFilePath();
+        fs.mkdirSync(path.join(bagDir, "data", "docs"), { recursive: true });
+        for (let relPath of ["bagit.txt", "bag-info.txt", "data/first.txt", "data/docs/second.txt"]) {
+            fs.copyFileSync(path.join(validator.pathToBag, relPath), path.join(bagDir, relPath));
+        }
+        let out = fs.createWriteStream(path.join(bagDir, "manifest-sha256.txt"));
+        validator.writeManifest(out, 'sha256').then(function() {
+            out.end();
+            out.on('finish', function() {
+                expect(fs.readFileSync(path.join(bagDir, "manifest-sha256.txt")).toString()).toEqual(expected);
+                let revalidator = new Validator(bagDir, new BagItProfile());
+                revalidator.on('end', function() {
+                    expect(revalidator.errors).toEqual([]);
+                    Util.deleteRecursive(bagDir);
+                    done();
+                });
+                revalidator.validate();
+            });
+        });
+    });
+    validator.validate();
+});
@@ -0,0 +1,49 @@
+/**
+ * ValueResolver is the base class for objects that look up the allowed
+ * values of a tag from an external authority, such as a controlled
+ * vocabulary service for rights statements. This lets a
+ * {@link BagItProfile} say that a tag's values come from a vocabulary
+ * without having to embed the whole vocabulary in the profile.
+ *
+ * The {@link Validator} consults its valueResolver for each
+ * {@link TagDefinition} whose vocabularyBacked property is true. It
+ * resolves the values once, before it starts reading the bag, and
+ * caches them for the rest of the validation run. If there is no
+ * resolver, or if the resolver fails, the validator falls back to the
+ * static values in the TagDefinition.
+ *
+ * Subclasses must implement allowedValues().
+ *
+ * @example
+ *
+ * class RightsResolver extends ValueResolver {
+ *     allowedValues(tagName) {
+ *         return fetchRightsStatements(); // Promise<string[]>
+ *     }
+ * }
+ * validator.valueResolver = new RightsResolver();
+ *
+ */
+class ValueResolver {
+    constructor() {
+
+    }
+
+    /**
+     * allowedValues returns a Promise that resolves to the list of
+     * values allowed for the specified tag. The promise should reject
+     * with an Error if the values could not be retrieved.
+     *
+     * Subclasses MUST override this method and must not call super().
+     *
+     * @param {string} tagName - The name of the tag whose allowed values
+     * you want to look up. E.g. 'Rights-Statement'.
+     *
+     * @returns {Promise<string[]>}
+     */
+    allowedValues(tagName) {
+        throw new Error('This method must be implemented in the subclass.');
+    }
+}
+
+module.exports.ValueResolver = ValueResolver;
@@ -0,0 +1,6 @@
+const { ValueResolver } = require('./value_resolver');
+
+test('allowedValues() must be implemented in subclass', () => {
+    let resolver = new ValueResolver();
+    expect(() => { resolver.allowedValues('Rights-Statement') }).toThrow('This method must be implemented in the subclass.');
+});
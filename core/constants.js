@@ -48,7 +48,7 @@ const Constants =  {
      * This is the list of digest algorithms that the bagger and
      * validator understand. The bagger can produce manifests and
      * tag manifests using these algorithms, and the validator can
-     * validate them.
+     * validate them. The list is ordered from weakest to strongest.
      *
      * @type {string[]}
      */
@@ -101,6 +101,16 @@ const Constants =  {
      * @type {RegExp}
      */
     RE_TAG_MANIFEST: new RegExp('^tagmanifest-(\\w+)\\.txt$'),
+    /**
+     * This regular expression matches the names of the parts of a
+     * payload or tag manifest that has been split into several files,
+     * such as manifest-sha256.000.txt and manifest-sha256.001.txt.
+     * The captures are the manifest type ('manifest' or 'tagmanifest'),
+     * the checksum algorithm, and the part number.
+     *
+     * @type {RegExp}
+     */
+    RE_SPLIT_MANIFEST: new RegExp('^(manifest|tagmanifest)-(\\w+)\\.(\\d+)\\.txt$'),
     /**
      * This maps serialization formats found in BagItProfiles
      * to file extension patterns. We can use this to identify
@@ -16,6 +16,16 @@ test('RE_TAG_MANIFEST', () => {
     expect('data/tagmanifest-sha256.txt').not.toMatch(Constants.RE_TAG_MANIFEST);
 });
 
+test('RE_SPLIT_MANIFEST', () => {
+    expect('manifest-sha256.000.txt').toMatch(Constants.RE_SPLIT_MANIFEST);
+    expect('tagmanifest-md5.12.txt').toMatch(Constants.RE_SPLIT_MANIFEST);
+    expect('manifest-sha256.001.txt'.match(Constants.RE_SPLIT_MANIFEST).slice(1)).toEqual(['manifest', 'sha256', '001']);
+
+    expect('manifest-sha256.txt').not.toMatch(Constants.RE_SPLIT_MANIFEST);
+    expect('manifest-sha256.old.txt').not.toMatch(Constants.RE_SPLIT_MANIFEST);
+    expect('data/manifest-sha256.000.txt').not.toMatch(Constants.RE_SPLIT_MANIFEST);
+});
+
 test('Serialization format patterns', () => {
     expect('/path/to/file.tar').toMatch(Constants.SERIALIZATION_FORMATS['application/tar']);
     expect('/path/to/file.zip').toMatch(Constants.SERIALIZATION_FORMATS['application/zip']);
@@ -258,6 +258,27 @@ class Util {
         return `${hs.toFixed(2)} ${sizes[i]}`;
     }
 
+    /**
+     * fromHumanSize converts a human-readable size, like the ones
+     * returned by {@link Util.toHumanSize}, back to a number of bytes.
+     * E.g. "2.50 MB" converts to 2621440. Units are case-insensitive,
+     * and a plain number is treated as bytes. Returns NaN if str is not
+     * a size this can parse.
+     *
+     * @param {string} str - The human-readable size to convert.
+     * @returns {number} - The number of bytes.
+     */
+    static fromHumanSize(str) {
+        var match = String(str).trim().match(/^(\d+(\.\d+)?)\s*(bytes|b|kb|mb|gb|tb)?$/i);
+        if (!match) {
+            return NaN;
+        }
+        var units = ['bytes', 'kb', 'mb', 'gb', 'tb'];
+        var unit = (match[3] || 'bytes').toLowerCase();
+        var power = unit == 'b' ? 0 : units.indexOf(unit);
+        return Math.round(Number(match[1]) * (1024 ** power));
+    }
+
     /**
       * Truncates a string at len characters, and appends '..." to the end.
       *
@@ -453,10 +474,17 @@ class Util {
      * extension or no extension, this will return the basename unaltered.
      *
      * @param {string} filepath - Path to the bag.
+     *
+     * @param {RegExp} [extensions] - Optional pattern matching the
+     * extensions to strip, in place of the list above. The pattern
+     * should be anchored to the end of the name with $.
     */
-    static bagNameFromPath(filepath) {
+    static bagNameFromPath(filepath, extensions) {
         var bagName = path.basename(filepath);
-        return bagName.replace(/\.tar$|\.tar\.gz$|\.t?gz$|\.tar\.Z$|\.tar\.bz$|\.tar\.bz2$|\.bz$|\.bz2$|\.zip$|\.zipx$|\.rar$|\.7z$|\.s7z$|\.par$|\.par2$/, '');
+        if (!extensions) {
+            extensions = /\.tar$|\.tar\.gz$|\.t?gz$|\.tar\.Z$|\.tar\.bz$|\.tar\.bz2$|\.bz$|\.bz2$|\.zip$|\.zipx$|\.rar$|\.7z$|\.s7z$|\.par$|\.par2$/;
+        }
+        return bagName.replace(extensions, '');
     }
 
     /**
@@ -134,6 +134,17 @@ test('Util.toHumanSize()', () => {
     expect(Util.toHumanSize(34567893456789)).toEqual("31.44 TB");
 });
 
+test('Util.fromHumanSize()', () => {
+    expect(Util.fromHumanSize("2.50 MB")).toEqual(2621440);
+    expect(Util.fromHumanSize("24.00 Bytes")).toEqual(24);
+    expect(Util.fromHumanSize("1 kb")).toEqual(1024);
+    expect(Util.fromHumanSize("3 GB")).toEqual(3221225472);
+    expect(Util.fromHumanSize("512")).toEqual(512);
+    expect(Util.fromHumanSize("100B")).toEqual(100);
+    expect(Util.fromHumanSize("lots")).toBeNaN();
+    expect(Util.fromHumanSize("")).toBeNaN();
+});
+
 test('Util.truncateString()', () => {
     let original = "This string will be truncated to a shorter length.";
     let truncated = "This string..."
@@ -232,6 +243,10 @@ test('Util.bagNameFromPath()', () => {
 
     // Should not trim off unrecognized extension
     expect(Util.bagNameFromPath('/var/tmp/bag_of_photos.123')).toEqual('bag_of_photos.123');
+
+    // Should strip only the extensions the caller asks for
+    expect(Util.bagNameFromPath('/var/tmp/bag_of_photos.zip', /\.zip$/)).toEqual('bag_of_photos');
+    expect(Util.bagNameFromPath('/var/tmp/bag_of_photos.rar', /\.zip$/)).toEqual('bag_of_photos.rar');
 });
 
 test('Util.cast()', () => {
@@ -19,7 +19,8 @@ class ValidationOperation {
     constructor(pathToBag) {
         /**
          * The absolute path to the bag to validate. The path can point to a
-         * file or directory.
+         * file or directory, or it can be '-' to read a tarred bag from
+         * STDIN.
          *
          * @type {string}
          */
@@ -51,7 +52,7 @@ class ValidationOperation {
         this.errors = {};
         if (Util.isEmpty(this.pathToBag)) {
             this.errors['ValidationOperation.pathToBag'] = Context.y18n.__('You must specify the path to the bag you want to validate.');
-        } else if (!fs.existsSync(this.pathToBag)) {
+        } else if (this.pathToBag != '-' && !fs.existsSync(this.pathToBag)) {
             this.errors['ValidationOperation.pathToBag'] = Context.y18n.__('The bag to be validated does not exist at %s', this.pathToBag);
         }
         return Object.keys(this.errors).length == 0;
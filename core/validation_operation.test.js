@@ -32,4 +32,9 @@ test('validate()', () => {
     result = validationOp.validate();
     expect(result).toBe(true);
     expect(validationOp.errors['ValidationOperation.pathToBag']).not.toBeDefined();
+
+    validationOp.pathToBag = '-';
+    result = validationOp.validate();
+    expect(result).toBe(true);
+    expect(validationOp.errors['ValidationOperation.pathToBag']).not.toBeDefined();
 });
@@ -67,6 +67,13 @@ class FileSystemReader extends Plugin {
          * @type {number}
          */
         this.byteCount = 0;
+        /**
+         * This is a private internal variable that tells the reader to
+         * stop emitting events. See {@link FileSystemReader#stop}.
+         *
+         * @type {boolean}
+         */
+        this._stopped = false;
     }
 
     /**
@@ -88,6 +95,18 @@ class FileSystemReader extends Plugin {
         };
     }
 
+    /**
+      * stop stops a read() or list() that is in progress. The reader
+      * emits no more events, not even "end".
+      *
+      */
+    stop() {
+        this._stopped = true;
+        if (this._stream) {
+            this._stream.destroy();
+        }
+    }
+
     /**
       * The read() method recursively lists the contents of a directory
       * and returns an open reader for each file it encounters.
@@ -102,6 +121,7 @@ class FileSystemReader extends Plugin {
     read() {
         var fsReader = this;
         var stream = readdirp(fsReader.pathToDirectory, OPTS);
+        fsReader._stream = stream;
         fsReader.fileCount = 0;
         fsReader.dirCount = 0;
         fsReader.byteCount = 0;
@@ -121,6 +141,9 @@ class FileSystemReader extends Plugin {
          * @type {Error}
          */
         stream.on('error', function(error) {
+            if (fsReader._stopped) {
+                return;
+            }
             fsReader.emit('err', error);
         });
 
@@ -132,6 +155,9 @@ class FileSystemReader extends Plugin {
          * nothing left to read.
          */
         stream.on('end', function() {
+            if (fsReader._stopped) {
+                return;
+            }
             fsReader.emit('end', fsReader.fileCount)
         });
 
@@ -163,6 +189,9 @@ class FileSystemReader extends Plugin {
          * and other attributes.
          */
         stream.on('data', function(entry) {
+            if (fsReader._stopped) {
+                return;
+            }
             // Emit relPath, fs.Stat and readable stream to match what
             // TarReader emits. Caller can get full path
             // by prepending FileSystemReader.pathToDirectory
@@ -217,6 +246,7 @@ class FileSystemReader extends Plugin {
     list() {
         var fsReader = this;
         var stream = readdirp(fsReader.pathToDirectory, OPTS);
+        fsReader._stream = stream;
         fsReader.fileCount = 0;
         fsReader.dirCount = 0;
         fsReader.byteCount = 0;
@@ -229,11 +259,17 @@ class FileSystemReader extends Plugin {
 
         // Same as the error event documented above.
         stream.on('error', function(error) {
+            if (fsReader._stopped) {
+                return;
+            }
             fsReader.emit('err', error);
         });
 
         // Same as the finish event documented above.
         stream.on('end', function() {
+            if (fsReader._stopped) {
+                return;
+            }
             fsReader.emit('end', fsReader.fileCount);
         });
 
@@ -259,6 +295,9 @@ class FileSystemReader extends Plugin {
          * and other attributes.
          */
         stream.on('data', function(entry) {
+            if (fsReader._stopped) {
+                return;
+            }
             // Emit relPath and fs.Stat object to match what
             // TarReader emits. Caller can get full path
             // by prepending FileSystemReader.pathToDirectory
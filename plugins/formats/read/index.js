@@ -2,5 +2,6 @@
 // across Jest, nexe, and Electron.
 const FileSystemReader = require('./file_system_reader');
 const TarReader = require('./tar_reader');
+const ZipReader = require('./zip_reader');
 
-module.exports.Providers = [FileSystemReader, TarReader];
+module.exports.Providers = [FileSystemReader, TarReader, ZipReader];
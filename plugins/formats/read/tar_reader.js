@@ -4,11 +4,20 @@ const { FileStat } = require('../../../util/file/filestat');
 const { PassThrough } = require('stream');
 const { Plugin } = require('../../plugin');
 const tar = require('tar-stream');
+const zlib = require('zlib');
+
+/**
+ * This is the magic number at the start of every gzip stream.
+ *
+ * @type {Buffer}
+ */
+const gzipMagic = Buffer.from('1f8b', 'hex');
 
 /**
   * TarReader provides methods for listing and reading the contents
   * of tar files. This is used by the bag validator to validate tarred
-  * bags without having to untar them first.
+  * bags without having to untar them first. It decompresses tar files
+  * ending in .tar.gz or .tgz as it reads them.
   *
   * Both TarReader and {@link FileSystemReader} implement a common
   * interface and emit a common set of events to provide the bag
@@ -57,6 +66,34 @@ class TarReader extends Plugin {
          * @type {number}
          */
         this.byteCount = 0;
+        /**
+         * tarByteCount is the number of bytes in the tar stream that
+         * this reader has read. For gzipped tar files, this is the
+         * size after decompression.
+         *
+         * @type {number}
+         */
+        this.tarByteCount = 0;
+        /**
+         * inputStream is a readable stream containing tar data. If this
+         * is set, the reader reads from this stream instead of opening
+         * pathToTarFile. Since a stream can be read only once, you can
+         * call read() or list() only once on a reader that has an
+         * inputStream. If the stream starts with the gzip magic number,
+         * the reader decompresses it, so you can pipe a .tar.gz file
+         * straight from a network source without saving it first.
+         *
+         * @type {stream.Readable}
+         * @default null
+         */
+        this.inputStream = null;
+        /**
+         * This is a private internal variable that tells the reader to
+         * stop emitting events. See {@link TarReader#stop}.
+         *
+         * @type {boolean}
+         */
+        this._stopped = false;
     }
 
     /**
@@ -70,7 +107,7 @@ class TarReader extends Plugin {
             name: 'TarReader',
             description: 'Built-in DART tar reader',
             version: '0.1',
-            readsFormats: ['.tar'],
+            readsFormats: ['.tar', '.tar.gz', '.tgz'],
             writesFormats: [],
             implementsProtocols: [],
             talksToRepository: [],
@@ -99,6 +136,9 @@ class TarReader extends Plugin {
         tarReader.byteCount = 0;
 
         extract.on('entry', function(header, stream, next) {
+            if (tarReader._stopped) {
+                return;
+            }
 
             var fileStat = tarReader._headerToFileStat(header);
 
@@ -149,6 +189,9 @@ class TarReader extends Plugin {
          * @type {Error}
          */
         extract.on('error', function(err) {
+            if (tarReader._stopped) {
+                return;
+            }
             tarReader.emit('error', err);
         });
 
@@ -159,11 +202,16 @@ class TarReader extends Plugin {
          * the last entry in the tar file and there's nothing left to read.
          */
         extract.on('finish', function() {
+            if (tarReader._stopped) {
+                return;
+            }
             tarReader.emit('end', tarReader.fileCount + tarReader.dirCount);
         });
 
         // Open the tar file and start reading.
-        fs.createReadStream(tarReader.pathToTarFile).pipe(extract)
+        this._extract = extract;
+        this._tarStream = this._openTarStream();
+        this._tarStream.pipe(extract);
     }
 
     /**
@@ -197,6 +245,9 @@ class TarReader extends Plugin {
          *
          */
         extract.on('entry', function(header, stream, next) {
+            if (tarReader._stopped) {
+                return;
+            }
             var fileStat = tarReader._headerToFileStat(header);
             var relPath = header.name;
             tarReader.emit('entry', { relPath: relPath, fileStat: fileStat });
@@ -220,16 +271,133 @@ class TarReader extends Plugin {
 
         // Same as the error event documented above.
         extract.on('error', function(err) {
+            if (tarReader._stopped) {
+                return;
+            }
             tarReader.emit('error', err);
         });
 
         // Same as the end event documented above.
         extract.on('finish', function() {
+            if (tarReader._stopped) {
+                return;
+            }
             tarReader.emit('end', tarReader.fileCount + tarReader.dirCount);
         });
 
         // Open the tar file and start reading.
-        fs.createReadStream(tarReader.pathToTarFile).pipe(extract)
+        this._extract = extract;
+        this._tarStream = this._openTarStream();
+        this._tarStream.pipe(extract);
+    }
+
+    /**
+      * stop stops a read() or list() that is in progress. The reader
+      * closes the tar file and emits no more events, not even "end".
+      * If the reader has an inputStream, the reader stops reading it,
+      * but does not close it.
+      *
+      */
+    stop() {
+        this._stopped = true;
+        if (this._tarStream) {
+            this._tarStream.unpipe(this._extract);
+        }
+        if (this._fileStream) {
+            this._fileStream.destroy();
+        }
+        if (this._extract) {
+            this._extract.destroy();
+        }
+    }
+
+    /**
+      * Returns a stream of the bytes in the tar file, decompressing
+      * them first if the file is gzipped. If the reader has an
+      * inputStream, this returns that instead. This keeps count of the
+      * bytes in tarByteCount.
+      *
+      * @returns {ReadableStream}
+      *
+      * @private
+      */
+    _openTarStream() {
+        var tarReader = this;
+        if (this.inputStream) {
+            return this._countBytes(this._gunzipIfNeeded(this.inputStream));
+        }
+        var tarStream = fs.createReadStream(this.pathToTarFile);
+        this._fileStream = tarStream;
+        if (/\.(tar\.gz|tgz)$/.test(this.pathToTarFile)) {
+            var gunzip = zlib.createGunzip();
+            gunzip.on('error', function(err) {
+                tarReader.emit('error', err);
+            });
+            tarStream = tarStream.pipe(gunzip);
+        }
+        return this._countBytes(tarStream);
+    }
+
+    /**
+      * Resets tarByteCount and counts the bytes that pass through
+      * tarStream.
+      *
+      * @param {ReadableStream} tarStream - The stream of tar data.
+      *
+      * @returns {ReadableStream}
+      *
+      * @private
+      */
+    _countBytes(tarStream) {
+        var tarReader = this;
+        this.tarByteCount = 0;
+        tarStream.on('data', function(chunk) {
+            tarReader.tarByteCount += chunk.length;
+        });
+        return tarStream;
+    }
+
+    /**
+      * Returns a stream of the tar data in inputStream. This peeks at
+      * the first bytes of inputStream, and if they're the gzip magic
+      * number, decompresses the rest. Streams have no file name, so
+      * this is the only way to tell whether a stream is gzipped.
+      *
+      * @param {ReadableStream} inputStream - A stream of tar or gzipped
+      * tar data.
+      *
+      * @returns {ReadableStream}
+      *
+      * @private
+      */
+    _gunzipIfNeeded(inputStream) {
+        var tarReader = this;
+        var output = new PassThrough();
+        var onEnd = function() {
+            output.end();
+        };
+        var sniff = function() {
+            var head = inputStream.read(gzipMagic.length);
+            if (head === null) {
+                // Wait for more data, or for the end of the stream.
+                return;
+            }
+            inputStream.removeListener('readable', sniff);
+            inputStream.removeListener('end', onEnd);
+            inputStream.unshift(head);
+            if (head.equals(gzipMagic)) {
+                var gunzip = zlib.createGunzip();
+                gunzip.on('error', function(err) {
+                    tarReader.emit('error', err);
+                });
+                inputStream.pipe(gunzip).pipe(output);
+            } else {
+                inputStream.pipe(output);
+            }
+        };
+        inputStream.on('readable', sniff);
+        inputStream.on('end', onEnd);
+        return output;
     }
 
     _headerToFileStat(header) {
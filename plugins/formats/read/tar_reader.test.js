@@ -1,3 +1,4 @@
+const fs = require('fs');
 const path = require('path');
 const { PassThrough } = require('stream');
 const TarReader = require('./tar_reader');
@@ -188,3 +189,38 @@ test('TarReader.list() returns correct stats', done => {
 
     tarReader.list();
 });
+
+test('TarReader.read() decompresses gzipped tar files', done => {
+    var pathToTarFile = path.join(__dirname, "..", "..", "..", "test", "bags", "bagit", "compressed_bag.tar.gz")
+    var tarReader = new TarReader(pathToTarFile);
+    var relPaths = [];
+    tarReader.on('entry', function(entry) {
+        relPaths.push(entry.relPath);
+        entry.stream.pipe(new PassThrough());
+    });
+    tarReader.on('end', function(fileCount) {
+        expect(fileCount).toEqual(5);
+        expect(relPaths).toContain("compressed_bag/data/docs/second.txt");
+        expect(tarReader.tarByteCount).toEqual(10240);
+        done();
+    });
+    tarReader.read();
+});
+
+test('TarReader.read() decompresses gzipped tar streams', done => {
+    var pathToTarFile = path.join(__dirname, "..", "..", "..", "test", "bags", "bagit", "compressed_bag.tar.gz")
+    var tarReader = new TarReader('-');
+    tarReader.inputStream = fs.createReadStream(pathToTarFile).pipe(new PassThrough());
+    var relPaths = [];
+    tarReader.on('entry', function(entry) {
+        relPaths.push(entry.relPath);
+        entry.stream.pipe(new PassThrough());
+    });
+    tarReader.on('end', function(fileCount) {
+        expect(fileCount).toEqual(5);
+        expect(relPaths).toContain("compressed_bag/data/docs/second.txt");
+        expect(tarReader.tarByteCount).toEqual(10240);
+        done();
+    });
+    tarReader.read();
+});
@@ -0,0 +1,428 @@
+const fs = require('fs');
+const { FileStat } = require('../../../util/file/filestat');
+const { PassThrough } = require('stream');
+const { Plugin } = require('../../plugin');
+const zlib = require('zlib');
+
+// Signatures and sizes of the zip records this reader understands.
+// See section 4.3 of https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT
+const END_OF_CENTRAL_DIR_SIG = 0x06054b50;
+const END_OF_CENTRAL_DIR_SIZE = 22;
+const CENTRAL_DIR_HEADER_SIG = 0x02014b50;
+const CENTRAL_DIR_HEADER_SIZE = 46;
+const LOCAL_FILE_HEADER_SIG = 0x04034b50;
+const LOCAL_FILE_HEADER_SIZE = 30;
+const MAX_COMMENT_SIZE = 0xffff;
+const METHOD_STORED = 0;
+const METHOD_DEFLATED = 8;
+const CREATED_ON_UNIX = 3;
+
+/**
+  * ZipReader provides methods for listing and reading the contents
+  * of zip files. This is used by the bag validator to validate zipped
+  * bags without having to unzip them first. It supports entries that
+  * are stored or deflated, which covers zip files created by nearly
+  * all common tools. It does not support zip64 archives or encrypted
+  * entries.
+  *
+  * ZipReader implements the same interface and emits the same events
+  * as {@link TarReader} and {@link FileSystemReader}, to provide the bag
+  * validator with a uniform interface for reading bags packaged in
+  * different formats.
+  *
+  * See the list() and read() functions below for information about
+  * the events they emit.
+ */
+class ZipReader extends Plugin {
+
+    /**
+      * Creates a new ZipReader.
+      *
+      * @param {string} pathToZipFile - This should be the absolute
+      * path to the zip file you want to read.
+     */
+    constructor(pathToZipFile) {
+        super();
+        /**
+         * pathToZipFile is the absolute path to the zip file that
+         * this iterator will read.
+         *
+         * @type {string}
+         */
+        this.pathToZipFile = pathToZipFile;
+        /**
+         * fileCount is the number of files encountered during a read()
+         * or list() operation.
+         *
+         * @type {number}
+         */
+        this.fileCount = 0;
+        /**
+         * dirCount is the number of directories encountered during a
+         * read() or list() operation.
+         *
+         * @type {number}
+         */
+        this.dirCount = 0;
+        /**
+         * byteCount keeps track of the total number of uncompressed
+         * bytes in all files in the zip file.
+         *
+         * @type {number}
+         */
+        this.byteCount = 0;
+        /**
+         * This is a private internal variable that tells the reader to
+         * stop emitting events. See {@link ZipReader#stop}.
+         *
+         * @type {boolean}
+         */
+        this._stopped = false;
+    }
+
+    /**
+     * Returns a {@link PluginDefinition} object describing this plugin.
+     *
+     * @returns {PluginDefinition}
+     */
+    static description() {
+        return {
+            id: '0b8a7c3e-2f4d-4e61-9a55-3c1d7e9b2f60',
+            name: 'ZipReader',
+            description: 'Built-in DART zip reader',
+            version: '0.1',
+            readsFormats: ['.zip'],
+            writesFormats: [],
+            implementsProtocols: [],
+            talksToRepository: [],
+            setsUp: []
+        };
+    }
+
+    /**
+      * stop stops a read() or list() that is in progress. The reader
+      * emits no more events, not even "end".
+      *
+      */
+    stop() {
+        this._stopped = true;
+    }
+
+    /**
+      * The read() method reads the contents of the zip file.
+      * It emits the events "entry", "error" and "end".
+      *
+      * The read() method returns the same information as the
+      * list() method, plus a readable stream from which you can
+      * extract the contents of individual files.
+      *
+      * Note that read() will not advance to the next entry
+      * until you've read the entire stream returned returned by
+      * the "entry" event.
+      *
+      */
+    read() {
+        this._iterate(true);
+    }
+
+    /**
+      * The list() method returns information about the contents
+      * files and directories inside a zip file. Unlike read(), it does
+      * not return a readable stream for any of the files it encounters.
+      *
+      * list() emits the events "entry", "error" and "end".
+      *
+      */
+    list() {
+        this._iterate(false);
+    }
+
+    /**
+      * Returns a readable stream of the uncompressed contents of a
+      * single entry in the zip file. Because zip files have a central
+      * directory, entries can be opened in any order. Callers can use
+      * this with the entries returned by {@link ZipReader#entries} to
+      * read specific files without reading the whole archive.
+      *
+      * @param {object} entry - An entry returned by entries().
+      *
+      * @returns {ReadableStream}
+      */
+    openEntry(entry) {
+        if (entry.isDirectory) {
+            let empty = new PassThrough();
+            empty.end();
+            return empty;
+        }
+        let fd = fs.openSync(this.pathToZipFile, 'r');
+        let header = Buffer.alloc(LOCAL_FILE_HEADER_SIZE);
+        try {
+            fs.readSync(fd, header, 0, LOCAL_FILE_HEADER_SIZE, entry.localHeaderOffset);
+        } finally {
+            fs.closeSync(fd);
+        }
+        if (header.readUInt32LE(0) != LOCAL_FILE_HEADER_SIG) {
+            throw new Error(`Zip file ${this.pathToZipFile} has a bad local header for ${entry.name}`);
+        }
+        let dataStart = entry.localHeaderOffset + LOCAL_FILE_HEADER_SIZE +
+            header.readUInt16LE(26) + header.readUInt16LE(28);
+        if (entry.compressedSize == 0) {
+            let empty = new PassThrough();
+            empty.end();
+            return empty;
+        }
+        let raw = fs.createReadStream(this.pathToZipFile, {
+            start: dataStart,
+            end: dataStart + entry.compressedSize - 1
+        });
+        if (entry.method == METHOD_STORED) {
+            return raw;
+        }
+        let inflate = zlib.createInflateRaw();
+        raw.on('error', function(err) {
+            inflate.emit('error', err);
+        });
+        return raw.pipe(inflate);
+    }
+
+    /**
+      * Returns a list of the entries in the zip file's central
+      * directory, in the order they appear there. Each entry has the
+      * name, method, compressedSize, size, localHeaderOffset, and
+      * isDirectory properties, along with a {@link FileStat} in
+      * fileStat.
+      *
+      * This throws an error if the file is not a zip file, or if it
+      * uses features this reader doesn't support.
+      *
+      * @returns {Array<object>}
+      */
+    entries() {
+        let fd = fs.openSync(this.pathToZipFile, 'r');
+        try {
+            let fileSize = fs.fstatSync(fd).size;
+            let eocd = this._readEndOfCentralDir(fd, fileSize);
+            let entryCount = eocd.readUInt16LE(10);
+            let dirSize = eocd.readUInt32LE(12);
+            let dirOffset = eocd.readUInt32LE(16);
+            if (entryCount == 0xffff || dirSize == 0xffffffff || dirOffset == 0xffffffff) {
+                throw new Error(`Zip file ${this.pathToZipFile} is in zip64 format, which is not supported`);
+            }
+            let dir = Buffer.alloc(dirSize);
+            fs.readSync(fd, dir, 0, dirSize, dirOffset);
+            let entries = [];
+            let offset = 0;
+            for (let i = 0; i < entryCount; i++) {
+                if (offset + CENTRAL_DIR_HEADER_SIZE > dir.length || dir.readUInt32LE(offset) != CENTRAL_DIR_HEADER_SIG) {
+                    throw new Error(`Zip file ${this.pathToZipFile} has a corrupt central directory`);
+                }
+                let entry = this._parseCentralDirHeader(dir, offset);
+                entries.push(entry);
+                offset += CENTRAL_DIR_HEADER_SIZE + entry.headerExtraLength;
+            }
+            return entries;
+        } finally {
+            fs.closeSync(fd);
+        }
+    }
+
+    /**
+      * Iterates through all entries in the zip file, emitting an entry
+      * event for each one. If withStreams is true, each entry includes
+      * a stream of its contents, and the next entry isn't emitted until
+      * that stream ends.
+      *
+      * @param {boolean} withStreams - Whether to include streams.
+      *
+      * @private
+      */
+    _iterate(withStreams) {
+        var zipReader = this;
+        zipReader.fileCount = 0;
+        zipReader.dirCount = 0;
+        zipReader.byteCount = 0;
+        var entries;
+        try {
+            entries = this.entries();
+        } catch (err) {
+            // Emit asynchronously, so callers can attach listeners
+            // after calling read() or list().
+            setImmediate(function() { zipReader.emit('error', err) });
+            return;
+        }
+        var index = 0;
+        var next = function() {
+            if (zipReader._stopped) {
+                return;
+            }
+            if (index >= entries.length) {
+                /**
+                 * @event ZipReader#end
+                 *
+                 * @description This indicates that the iterator has passed
+                 * the last entry in the zip file and there's nothing left to read.
+                 */
+                zipReader.emit('end', zipReader.fileCount + zipReader.dirCount);
+                return;
+            }
+            var entry = entries[index++];
+            if (entry.isDirectory) {
+                zipReader.dirCount += 1;
+            } else {
+                zipReader.fileCount += 1;
+                zipReader.byteCount += entry.size;
+            }
+            if (!withStreams) {
+                zipReader.emit('entry', { relPath: entry.name, fileStat: entry.fileStat });
+                setImmediate(next);
+                return;
+            }
+            var stream;
+            try {
+                stream = zipReader.openEntry(entry);
+            } catch (err) {
+                /**
+                 * @event ZipReader#error
+                 *
+                 * @description Indicates something went wrong while reading the zip file.
+                 *
+                 * @type {Error}
+                 */
+                zipReader.emit('error', err);
+                return;
+            }
+            stream.on('error', function(err) {
+                zipReader.emit('error', err);
+            });
+            stream.on('end', function() {
+                setImmediate(next);
+            });
+            /**
+             * @event ZipReader#entry
+             *
+             * @description The entry event of the read() method includes info
+             * about the file from the zip file's central directory and a
+             * {@link ReadStream} from which you can read the uncompressed
+             * contents of the entry. The entry event of the list() method
+             * does not include the stream. Note that you MUST read the
+             * stream to the end before ZipReader.read() will move to the
+             * next entry.
+             *
+             * @type {object}
+             *
+             * @property {string} relPath - The relative path (within the zip file)
+             * of the entry.
+             *
+             * @property {ReadStream} stream - A stream from which you can read the
+             * contents of the entry.
+             *
+             * @property {FileStat} fileStat - An object containing a subset info similar
+             * to the fs.Stats object, describing the file's size and other attributes.
+             */
+            zipReader.emit('entry', { relPath: entry.name, fileStat: entry.fileStat, stream: stream });
+        };
+        setImmediate(next);
+    }
+
+    /**
+      * Returns the end of central directory record, which is at the end
+      * of the zip file, after an optional comment of up to 64k.
+      *
+      * @param {number} fd - An open file descriptor for the zip file.
+      *
+      * @param {number} fileSize - The size of the zip file.
+      *
+      * @returns {Buffer}
+      *
+      * @private
+      */
+    _readEndOfCentralDir(fd, fileSize) {
+        let tailSize = Math.min(fileSize, END_OF_CENTRAL_DIR_SIZE + MAX_COMMENT_SIZE);
+        let tail = Buffer.alloc(tailSize);
+        fs.readSync(fd, tail, 0, tailSize, fileSize - tailSize);
+        for (let i = tailSize - END_OF_CENTRAL_DIR_SIZE; i >= 0; i--) {
+            if (tail.readUInt32LE(i) == END_OF_CENTRAL_DIR_SIG) {
+                return tail.slice(i, i + END_OF_CENTRAL_DIR_SIZE);
+            }
+        }
+        throw new Error(`${this.pathToZipFile} is not a zip file`);
+    }
+
+    /**
+      * Parses the central directory header at offset in dir.
+      *
+      * @param {Buffer} dir - The zip file's central directory.
+      *
+      * @param {number} offset - The offset of the header within dir.
+      *
+      * @returns {object}
+      *
+      * @private
+      */
+    _parseCentralDirHeader(dir, offset) {
+        let flags = dir.readUInt16LE(offset + 8);
+        let method = dir.readUInt16LE(offset + 10);
+        let nameLength = dir.readUInt16LE(offset + 28);
+        let extraLength = dir.readUInt16LE(offset + 30);
+        let commentLength = dir.readUInt16LE(offset + 32);
+        let name = dir.toString('utf8', offset + CENTRAL_DIR_HEADER_SIZE, offset + CENTRAL_DIR_HEADER_SIZE + nameLength);
+        let isDirectory = name.endsWith('/');
+        if (flags & 0x1) {
+            throw new Error(`Zip file ${this.pathToZipFile} has encrypted entry ${name}, which is not supported`);
+        }
+        if (!isDirectory && method != METHOD_STORED && method != METHOD_DEFLATED) {
+            throw new Error(`Zip file ${this.pathToZipFile} uses unsupported compression method ${method} for ${name}`);
+        }
+        let size = dir.readUInt32LE(offset + 24);
+        return {
+            name: name,
+            method: method,
+            compressedSize: dir.readUInt32LE(offset + 20),
+            size: size,
+            localHeaderOffset: dir.readUInt32LE(offset + 42),
+            isDirectory: isDirectory,
+            headerExtraLength: nameLength + extraLength + commentLength,
+            fileStat: new FileStat({
+                size: size,
+                mode: this._mode(dir, offset, isDirectory),
+                mtimeMs: this._dosDateTime(dir.readUInt16LE(offset + 14), dir.readUInt16LE(offset + 12)),
+                type: isDirectory ? 'directory' : 'file'
+            })
+        };
+    }
+
+    /**
+      * Returns the file mode of a central directory entry. Zip files
+      * created on Unix keep the mode in the high bits of the external
+      * attributes. Others get a reasonable default.
+      *
+      * @private
+      */
+    _mode(dir, offset, isDirectory) {
+        let createdOn = dir.readUInt8(offset + 5);
+        let mode = dir.readUInt32LE(offset + 38) >>> 16;
+        if (createdOn == CREATED_ON_UNIX && mode != 0) {
+            return mode & 0o7777;
+        }
+        return isDirectory ? 0o755 : 0o644;
+    }
+
+    /**
+      * Converts an MS-DOS date and time, which is how zip files store
+      * modification times, to a Date.
+      *
+      * @private
+      */
+    _dosDateTime(date, time) {
+        return new Date(
+            ((date >> 9) & 0x7f) + 1980,
+            ((date >> 5) & 0x0f) - 1,
+            date & 0x1f,
+            (time >> 11) & 0x1f,
+            (time >> 5) & 0x3f,
+            (time & 0x1f) * 2
+        );
+    }
+}
+
+module.exports = ZipReader;
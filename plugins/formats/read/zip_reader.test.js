@@ -0,0 +1,115 @@
+const crypto = require('crypto');
+const path = require('path');
+const { PassThrough } = require('stream');
+const ZipReader = require('./zip_reader');
+
+const pathToZipFile = path.join(__dirname, "..", "..", "..", "test", "bags", "aptrust", "example.edu.sample_good.zip");
+
+/* --------------------------------------------------------------
+
+Items in this zip file:
+
+     0  example.edu.sample_good/
+    55  example.edu.sample_good/bagit.txt
+   223  example.edu.sample_good/bag-info.txt
+    74  example.edu.sample_good/aptrust-info.txt
+   230  example.edu.sample_good/manifest-md5.txt
+     0  example.edu.sample_good/data/
+  4663  example.edu.sample_good/data/datastream-MARC
+  2388  example.edu.sample_good/data/datastream-DC
+   579  example.edu.sample_good/data/datastream-RELS-EXT
+  6191  example.edu.sample_good/data/datastream-descMetadata
+
+ 14403  TOTAL
+
+----------------------------------------------------------------*/
+
+test('ZipReader.read() emits expected events', done => {
+    var streamCount = 0;
+    var zipReader = new ZipReader(pathToZipFile);
+    zipReader.on('entry', function(entry) {
+        expect(entry.relPath).not.toBeNull();
+        expect(entry.fileStat).not.toBeNull();
+        expect(entry.stream).not.toBeNull();
+        streamCount++;
+        entry.stream.pipe(new PassThrough()).resume();
+    });
+    zipReader.on('end', function(fileCount) {
+        expect(streamCount).toEqual(10);
+        expect(fileCount).toEqual(10);
+        expect(zipReader.fileCount).toEqual(8);
+        expect(zipReader.dirCount).toEqual(2);
+        expect(zipReader.byteCount).toEqual(14403);
+        done();
+    });
+    zipReader.read();
+});
+
+test('ZipReader.read() inflates file contents', done => {
+    var digests = {};
+    var zipReader = new ZipReader(pathToZipFile);
+    zipReader.on('entry', function(entry) {
+        if (!entry.fileStat.isFile()) {
+            entry.stream.resume();
+            return;
+        }
+        var hash = crypto.createHash('md5');
+        hash.setEncoding('hex');
+        hash.on('finish', function() {
+            digests[entry.relPath] = hash.read();
+        });
+        entry.stream.pipe(hash);
+    });
+    zipReader.on('end', function() {
+        // These match the digests in the bag's manifest-md5.txt.
+        expect(digests["example.edu.sample_good/data/datastream-DC"]).toEqual("44d85cf4810d6c6fe87750117633e461");
+        expect(digests["example.edu.sample_good/data/datastream-MARC"]).toEqual("93e381dfa9ad0086dbe3b92e0324bae6");
+        done();
+    });
+    zipReader.read();
+});
+
+test('ZipReader.list() returns correct stats', done => {
+    var stats = {};
+    var zipReader = new ZipReader(pathToZipFile);
+    zipReader.on('entry', function(entry) {
+        expect(entry.stream).toBeUndefined();
+        stats[entry.relPath] = entry.fileStat;
+    });
+    zipReader.on('end', function() {
+        expect(Object.keys(stats).length).toEqual(10);
+        let bagInfo = stats["example.edu.sample_good/bag-info.txt"];
+        expect(bagInfo.size).toEqual(223);
+        expect(bagInfo.mode).toEqual(0o644);
+        expect(bagInfo.type).toEqual('file');
+        expect(bagInfo.mtimeMs.getFullYear()).toEqual(2018);
+        let dataDir = stats["example.edu.sample_good/data/"];
+        expect(dataDir.mode).toEqual(0o755);
+        expect(dataDir.type).toEqual('directory');
+        expect(zipReader.byteCount).toEqual(14403);
+        done();
+    });
+    zipReader.list();
+});
+
+test('ZipReader.openEntry() reads entries in any order', done => {
+    var zipReader = new ZipReader(pathToZipFile);
+    var entry = zipReader.entries().find(e => e.name == "example.edu.sample_good/bagit.txt");
+    var chunks = [];
+    var stream = zipReader.openEntry(entry);
+    stream.on('data', function(chunk) { chunks.push(chunk) });
+    stream.on('end', function() {
+        expect(Buffer.concat(chunks).toString()).toMatch(/^BagIt-Version: /);
+        done();
+    });
+});
+
+test('ZipReader emits error for files that are not zip files', done => {
+    var pathToTarFile = path.join(__dirname, "..", "..", "..", "test", "bags", "aptrust", "example.edu.sample_good.tar");
+    var zipReader = new ZipReader(pathToTarFile);
+    zipReader.on('error', function(err) {
+        expect(err.message).toEqual(`${pathToTarFile} is not a zip file`);
+        done();
+    });
+    zipReader.list();
+});
@@ -4,6 +4,7 @@ const path = require('path');
 const { PluginManager } = require('./plugin_manager');
 const TarReader = require('./formats/read/tar_reader');
 const TarWriter = require('./formats/write/tar_writer');
+const ZipReader = require('./formats/read/zip_reader');
 
 var readerDir = path.join(__dirname, "formats", "read");
 var writerDir = path.join(__dirname, "formats", "write");
@@ -44,6 +45,10 @@ test('canRead()', () => {
     expect(tarReaders.length).toEqual(1);
     expect(tarReaders[0]).toEqual(TarReader);
 
+    var zipReaders = PluginManager.canRead('.zip');
+    expect(zipReaders.length).toEqual(1);
+    expect(zipReaders[0]).toEqual(ZipReader);
+
     var noReaders = PluginManager.canRead('your mind');
     expect(noReaders.length).toEqual(0);
 
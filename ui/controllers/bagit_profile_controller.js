@@ -484,6 +484,7 @@ class BagItProfileController extends BaseController {
             throw msg;
         }
         let convertedProfile;
+        let warnings = [];
         let profileType = BagItUtil.guessProfileType(obj);
         switch (profileType) {
         case 'dart':
@@ -497,6 +498,7 @@ class BagItProfileController extends BaseController {
             break;
         case 'bagit_profiles':
             convertedProfile = BagItUtil.profileFromStandardObject(obj);
+            warnings = BagItUtil.profileVersionWarnings(obj);
             break;
         default:
             alert(Context.y18n.__("DART does not recognize this BagIt Profile structure."));
@@ -505,7 +507,7 @@ class BagItProfileController extends BaseController {
             convertedProfile.save();
             let params = new URLSearchParams({
                 id: convertedProfile.id,
-                alertMessage: Context.y18n.__("Imported BagIt profile. Please review the profile to ensure it is accurate.")
+                alertMessage: [Context.y18n.__("Imported BagIt profile. Please review the profile to ensure it is accurate.")].concat(warnings).join(' ')
             });
             return this.redirect('BagItProfile', 'edit', params);
         } else {
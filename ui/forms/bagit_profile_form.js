@@ -23,6 +23,9 @@ const infoFields = {
 class BagItProfileForm extends Form {
 
     constructor(bagItProfile) {
+        // The advanced settings at the end of this list have no
+        // form fields, so they're set only through JSON profiles.
+        // Excluding them keeps parseFromDOM() from clearing them.
         let exclude = [
             "bagItProfileInfo",
             "baseProfileId",
@@ -33,6 +36,21 @@ class BagItProfileForm extends Form {
             "tags",
             "type",
             "userCanDelete",
+            "allowOnlyRequiredManifests",
+            "conformanceLevels",
+            "manifestFormat",
+            "maxBagSize",
+            "maxPayloadSize",
+            "maxTagFileSize",
+            "maxTotalMetadataSize",
+            "minPayloadSize",
+            "requireSortedManifests",
+            "requireSourceOrganizationMatch",
+            "requiredSerialization",
+            "requiredTagOrder",
+            "tagDelimiter",
+            "tagFileAlgorithms",
+            "tagFilesRequired",
         ];
         super('BagItProfile', bagItProfile, exclude);
         this._init();
@@ -7,8 +7,10 @@ const { Form } = require('./form');
 class TagDefinitionForm extends Form {
 
     constructor(tagDefinition) {
-        // On this form, we do include 'required'
-        super('TagDefinition', tagDefinition, ['errors', 'type', 'wasAddedForJob']);
+        // On this form, we do include 'required'. The form can't
+        // edit requiredWhen, so leave it out, or parseFromDOM would
+        // clear it.
+        super('TagDefinition', tagDefinition, ['errors', 'type', 'wasAddedForJob', 'requiredWhen']);
         this._init();
     }
 
@@ -33,6 +35,21 @@ class TagDefinitionForm extends Form {
             Constants.YES_NO,
             this.obj.required,
             false);
+
+        this.fields['repeatable'].choices = Choice.makeList(
+            Constants.YES_NO,
+            this.obj.repeatable,
+            false);
+
+        this.fields['caseInsensitive'].choices = Choice.makeList(
+            Constants.YES_NO,
+            this.obj.caseInsensitive,
+            false);
+
+        this.fields['format'].choices = Choice.makeList(
+            TagDefinition.formats(),
+            this.obj.format,
+            true);
     }
 
 }
@@ -12,11 +12,14 @@ test('create()', () => {
     let expectedFields = [
         'id', 'tagFile', 'tagName', 'required',
         'values', 'defaultValue', 'userValue', 'isBuiltIn',
-        'isUserAddedFile', 'isUserAddedTag', 'help'
+        'isUserAddedFile', 'isUserAddedTag', 'help',
+        'vocabularyBacked', 'format', 'repeatable', 'pattern',
+        'caseInsensitive'
     ];
     let form = new TagDefinitionForm(tagDefinition);
     expect(Object.keys(form.fields).length).toEqual(expectedFields.length);
     for (let fieldName of expectedFields) {
         expect(form.fields[fieldName]).toBeDefined();
     }
+    expect(form.fields['format'].choices.map(c => c.value)).toEqual(['', 'email']);
 });
@@ -88,6 +88,10 @@ class BagValidator extends Worker {
         let result = this.job.validationOp.result;
         result.filepath = this.job.validationOp.pathToBag;
         result.start();
+        if (this.job.validationOp.pathToBag == '-') {
+            // Reading from STDIN. Nothing to stat.
+            return;
+        }
         try {
             let stats = fs.statSync(this.job.validationOp.pathToBag);
             if (stats.isFile()) {